@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app"
+	schedulerapp "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/scheduler"
+	senderapp "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender/templates"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	boltstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/bolt"
+	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	memorybroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/memory"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/lifecycle"
+)
+
+// AllInOneConfig is config of "all-in-one" mode: API, scheduler and sender run in one process
+// with memory or bolt storage and in-process queue, for demos and local development.
+type AllInOneConfig struct {
+	Logger     config.LoggerConf    `mapstructure:"logger"`
+	Storage    config.StorageConf   `mapstructure:"storage"`
+	HTTPServer config.ServerConf    `mapstructure:"http"`
+	GRPCServer config.ServerConf    `mapstructure:"grpc"`
+	Scheduler  config.SchedulerConf `mapstructure:"scheduler"`
+	Jobs       config.JobsConf      `mapstructure:"jobs"`
+	Sender     config.SenderConf    `mapstructure:"sender"`
+	Templates  config.TemplatesConf `mapstructure:"templates"`
+	Queue      QueueConf            `mapstructure:"queue"`
+	Shutdown   config.ShutdownConf  `mapstructure:"shutdown"`
+}
+
+// QueueConf is in-process queue between scheduler and sender.
+type QueueConf struct {
+	Size int `mapstructure:"size" default:"1024"`
+}
+
+func (c *QueueConf) Validate() error {
+	return config.Positive("size", c.Size)
+}
+
+func (c *AllInOneConfig) Defaults() map[string]any {
+	defaults := (&Config{}).Defaults()
+	for key, value := range config.JobsDefaults() {
+		defaults[key] = value
+	}
+
+	return defaults
+}
+
+func (c *AllInOneConfig) Validate() error {
+	var errs []error
+	if c.Storage.Driver == config.StoragePostgres {
+		errs = append(errs, config.Invalid("storage.driver", errors.New("postgres is not supported in all-in-one mode")))
+	}
+	if c.Scheduler.MetricsAddr != "" {
+		errs = append(errs, config.Invalid("scheduler.metricsAddr", errors.New("is not supported in all-in-one mode")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// runAllInOne runs "all-in-one" subcommand, it returns exit code like run.
+func runAllInOne() int {
+	var conf AllInOneConfig
+	if err := config.Load(configFile, &conf); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	logOutput, err := conf.Logger.Open()
+	if err != nil {
+		fmt.Println("cannot open log: " + err.Error())
+		return 1
+	}
+	defer logOutput.Close()
+
+	logg := logger.New(conf.Logger.Level, logOutput)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	group := lifecycle.New(conf.Shutdown.Timeout, func(name string, err error) {
+		if err != nil {
+			logg.Error("%s is stopped: %s", name, err)
+			return
+		}
+		logg.Info("%s is stopped", name)
+	})
+
+	var eventStorage storage.Storage
+	if conf.Storage.Driver == config.StorageBolt {
+		eventStorage = boltstorage.New(conf.Storage.Path)
+	} else {
+		eventStorage = memorystorage.New()
+	}
+
+	if err := eventStorage.Connect(ctx); err != nil {
+		logg.Error("cannot connect to storage: " + err.Error())
+		return 1
+	}
+	group.AddCloser("storage", eventStorage.Close)
+
+	queue := memorybroker.New(conf.Queue.Size)
+	if err := queue.Connect(ctx); err != nil {
+		logg.Error("cannot connect to queue: " + err.Error())
+		group.Close()
+		return 1
+	}
+	group.AddCloser("queue", queue.Close)
+
+	logg.Info("successfully init %s storage and in-process queue", conf.Storage.Driver)
+
+	if err := addServers(ctx, group, logg, app.New(logg, eventStorage), conf.GRPCServer, conf.HTTPServer); err != nil {
+		logg.Error(err.Error())
+		group.Close()
+		return 1
+	}
+
+	scheduler := schedulerapp.New(
+		logg,
+		eventStorage,
+		queue,
+		conf.Scheduler.RunFrequencyInterval,
+		conf.Scheduler.TimeForRemoveOldEvents,
+		schedulerapp.ClaimConfig{
+			InstanceID: fmt.Sprintf("all-in-one-%d", os.Getpid()),
+			Lease:      conf.Scheduler.ClaimLease,
+			BatchSize:  conf.Scheduler.ClaimBatchSize,
+		},
+	)
+
+	runner := jobs.New(conf.Scheduler.HistorySize, func(run jobs.Run) {
+		switch {
+		case run.Skipped:
+			logg.Warning("job %s is skipped, previous run is not finished", run.Job)
+		case run.Error != "":
+			logg.Error("job %s failed in %s: %s", run.Job, run.Duration, run.Error)
+		default:
+			logg.Debug("job %s is done in %s", run.Job, run.Duration)
+		}
+	})
+
+	if err := scheduler.AddJobs(runner, conf.Jobs); err != nil {
+		logg.Error(err.Error())
+		group.Close()
+		return 1
+	}
+
+	group.Add(lifecycle.Component{Name: "jobs", Run: lifecycle.Wait(runner.Run)})
+	group.Add(lifecycle.Component{Name: "reminders", Run: lifecycle.Wait(func(ctx context.Context) {
+		scheduler.WatchReminders(ctx, func() {
+			if err := runner.Trigger(schedulerapp.NotificationsJob); err != nil {
+				logg.Error(err.Error())
+			}
+		})
+	})})
+
+	renderer := templates.New(conf.Templates.Path, conf.Sender.Locale)
+	if err := renderer.Load(); err != nil {
+		logg.Error("cannot load templates: " + err.Error())
+		group.Close()
+		return 1
+	}
+
+	if conf.Templates.ReloadInterval > 0 {
+		go renderer.Watch(ctx, conf.Templates.ReloadInterval, func(err error) {
+			logg.Error("cannot reload templates: " + err.Error())
+		})
+	}
+
+	// time zone is checked by config.Load.
+	location, _ := time.LoadLocation(conf.Sender.Timezone)
+
+	sender := senderapp.New(logg, queue, conf.Sender.Threads, renderer, eventStorage, senderapp.Defaults{
+		Channel:  conf.Sender.Channel,
+		Locale:   conf.Sender.Locale,
+		Location: location,
+	})
+
+	group.Add(lifecycle.Component{Name: "consumer", Run: sender.Consume})
+	group.Add(lifecycle.Component{Name: "outbox", Run: lifecycle.Wait(func(ctx context.Context) {
+		sender.RunOutbox(ctx, conf.Sender.FlushInterval)
+	})})
+
+	logg.Info("calendar is running in all-in-one mode...")
+
+	if err := group.Run(ctx); err != nil {
+		logg.Error("calendar is stopped with error: %s", err)
+		return 1
+	}
+
+	logg.Info("calendar is stopped")
+	return 0
+}
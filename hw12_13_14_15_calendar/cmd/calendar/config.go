@@ -3,64 +3,51 @@ package main
 import (
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/spf13/viper"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
 )
 
-// При желании конфигурацию можно вынести в internal/config.
+// Общие секции описаны в internal/config, здесь только их набор для сервиса.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger     LoggerConf     `mapstructure:"logger"`
-	Storage    StorageConf    `mapstructure:"storage"`
-	DB         DBConf         `mapstructure:"db"`
-	HTTPServer HTTPServerConf `mapstructure:"http"`
-	GRPCServer GRPCServerConf `mapstructure:"grpc"`
+	Logger     config.LoggerConf   `mapstructure:"logger"`
+	Storage    config.StorageConf  `mapstructure:"storage"`
+	DB         config.DBConf       `mapstructure:"db"`
+	HTTPServer config.ServerConf   `mapstructure:"http"`
+	GRPCServer config.ServerConf   `mapstructure:"grpc"`
+	Shutdown   config.ShutdownConf `mapstructure:"shutdown"`
 }
 
-type LoggerConf struct {
-	Level string `mapstructure:"level"`
-	Path  string `mapstructure:"path"`
-}
-
-type StorageConf struct {
-	Driver         string `mapstructure:"driver"`
-	MigrationsPath string `mapstructure:"migrations_path"`
-}
-
-type DBConf struct {
-	DBHost     string `mapstructure:"host"`
-	DBPort     int    `mapstructure:"port"`
-	DBName     string `mapstructure:"name"`
-	DBUsername string `mapstructure:"username"`
-	DBPassword string `mapstructure:"password"`
-}
-
-type HTTPServerConf struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
+func (c *Config) Defaults() map[string]any {
+	return map[string]any{
+		"http.host": "localhost",
+		"http.port": 8080,
+		"grpc.host": "localhost",
+		"grpc.port": 8081,
+	}
 }
 
-type GRPCServerConf struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
+func (c *Config) Validate() error {
+	return config.CheckStorage(c.Storage, c.DB)
 }
 
 func NewConfig() *Config {
-	v := viper.New()
-	v.SetConfigFile(configFile)
-	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
-	if err := v.ReadInConfig(); err != nil {
-		fmt.Printf("couldn't load config: %s", err)
+	conf, err := LoadConfig()
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
-	var config Config
-	if err := v.Unmarshal(&config); err != nil {
-		fmt.Printf("couldn't read config: %s", err)
+
+	return conf
+}
+
+// LoadConfig reads and validates config file, it is used on start and on reload.
+func LoadConfig() (*Config, error) {
+	var conf Config
+	if err := config.Load(configFile, &conf); err != nil {
+		return nil, err
 	}
 
-	return &config
+	return &conf, nil
 }
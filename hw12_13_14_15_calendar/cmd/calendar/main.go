@@ -2,23 +2,22 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
-	"time"
+	_ "time/tzdata" // time zones of user preferences for images without system tzdata
 
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app"
+	internalconfig "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/http"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	boltstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/bolt"
 	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/lifecycle"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
 )
 
 var configFile string
@@ -35,76 +34,122 @@ func main() {
 		return
 	}
 
-	// init context
-	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-	defer cancel()
+	if flag.Arg(0) == "config" {
+		if err := internalconfig.Command(os.Stdout, flag.Args()[1:], configFile, &Config{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
+	if flag.Arg(0) == "all-in-one" {
+		os.Exit(runAllInOne())
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	os.Exit(run())
+}
+
+// run returns exit code, so deferred closing is done before exit.
+func run() int {
 	config := NewConfig()
+	watcher := reload.New(configFile, configCheckInterval)
 
-	logg := logger.New(config.Logger.Level, os.Stdout)
+	logOutput, err := config.Logger.Open()
+	if err != nil {
+		fmt.Println("cannot open log: " + err.Error())
+		return 1
+	}
+	defer logOutput.Close()
+
+	logg := logger.New(config.Logger.Level, logOutput)
 
-	var eventStorage storage.EventStorage
-	if config.Storage.Driver == "postgres" {
-		connectionString := fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			config.DB.DBHost, config.DB.DBPort, config.DB.DBUsername, config.DB.DBPassword, config.DB.DBName,
-		)
+	// signals are handled by the group, ctx stops background watchers on return.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		eventStorage = sqlstorage.New(connectionString, config.Storage.MigrationsPath)
-		err := eventStorage.Connect(ctx)
+	group := lifecycle.New(config.Shutdown.Timeout, func(name string, err error) {
 		if err != nil {
-			logg.Error("cannot connect to DB server: " + err.Error())
-			cancel()
-			os.Exit(1) //nolint:gocritic
+			logg.Error("%s is stopped: %s", name, err)
+			return
 		}
-		defer eventStorage.Close()
-	} else {
+		logg.Info("%s is stopped", name)
+	})
+
+	var eventStorage storage.Storage
+	switch config.Storage.Driver {
+	case internalconfig.StoragePostgres:
+		eventStorage = sqlstorage.New(sqlstorage.NewConfig(config.DB, config.Storage))
+	case internalconfig.StorageBolt:
+		eventStorage = boltstorage.New(config.Storage.Path)
+	case internalconfig.StorageMemory:
 		eventStorage = memorystorage.New()
 	}
 
+	if err := eventStorage.Connect(ctx); err != nil {
+		logg.Error("cannot connect to storage: " + err.Error())
+		return 1
+	}
+	// storage is added first, so it is closed after servers are drained.
+	group.AddCloser("storage", eventStorage.Close)
+
 	logg.Info(fmt.Sprintf("successfully init %s storage", config.Storage.Driver))
 
 	calendar := app.New(logg, eventStorage)
 
-	httpServer := internalhttp.NewServer(config.HTTPServer.Host, config.HTTPServer.Port, logg, calendar)
-	grpcServer := grpc.NewServer(config.GRPCServer.Host, config.GRPCServer.Port, logg, calendar)
-
-	go func() {
-		<-ctx.Done()
-
-		_, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
-
-		if err := httpServer.Stop(ctx); err != nil {
-			logg.Error("failed to stop http server: " + err.Error())
-		}
+	if err := addServers(ctx, group, logg, calendar, config.GRPCServer, config.HTTPServer); err != nil {
+		logg.Error(err.Error())
+		group.Close()
+		return 1
+	}
 
-		logg.Info("http-server successfully terminated!")
+	group.Add(lifecycle.Component{Name: "config watcher", Run: lifecycle.Wait(func(ctx context.Context) {
+		watchConfig(ctx, watcher, logg, config)
+	})})
 
-		grpcServer.Stop()
-		logg.Info("grpc-server successfully terminated!")
+	logg.Info("calendar is running...")
 
-		os.Exit(1)
-	}()
+	// blocks until shutdown signal, in-flight requests are drained within shutdown timeout
+	if err := group.Run(ctx); err != nil {
+		logg.Error("calendar is stopped with error: %s", err)
+		return 1
+	}
 
-	logg.Info("calendar is running...")
+	logg.Info("calendar is stopped")
+	return 0
+}
 
-	var wg sync.WaitGroup
+// addServers adds GRPC server and HTTP gateway of the calendar to the group.
+func addServers(
+	ctx context.Context,
+	group *lifecycle.Group,
+	logg *logger.Logger,
+	calendar *app.App,
+	grpcConf internalconfig.ServerConf,
+	httpConf internalconfig.ServerConf,
+) error {
+	grpcTLS, err := newTLSConfig(ctx, logg, "grpc", grpcConf.TLS, "h2")
+	if err != nil {
+		return fmt.Errorf("cannot init grpc TLS: %w", err)
+	}
 
-	wg.Add(2)
+	httpTLS, err := newTLSConfig(ctx, logg, "http", httpConf.TLS, "h2", "http/1.1")
+	if err != nil {
+		return fmt.Errorf("cannot init http TLS: %w", err)
+	}
 
-	go func() {
-		if err := httpServer.Start(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logg.Error("failed to start http server: " + err.Error())
-		}
-	}()
+	grpcServer := grpc.NewServer(grpcConf.Host, grpcConf.Port, logg, calendar, grpcTLS, grpcConf.TrustUserHeader)
+	httpServer := internalhttp.NewServer(httpConf.Host, httpConf.Port, logg, grpcServer, httpTLS, httpConf.TrustUserHeader)
 
-	go func() {
-		if err := grpcServer.Start(ctx); err != nil {
-			logg.Error("failed to start grpc server: " + err.Error())
-		}
-	}()
+	group.Add(lifecycle.Component{Name: "grpc-server", Run: grpcServer.Start, Stop: grpcServer.Stop})
+	group.Add(lifecycle.Component{Name: "http-server", Run: httpServer.Start, Stop: httpServer.Stop})
 
-	wg.Wait()
+	return nil
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
+	sqlstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+var errNotPostgres = errors.New("migrations are used only by postgres storage")
+
+// migrate runs "migrate" subcommand with embedded migrations or migrations from storage.migrationsPath.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return sqlstorage.ErrMigrateUsage
+	}
+
+	conf, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if conf.Storage.Driver != config.StoragePostgres {
+		return errNotPostgres
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return sqlstorage.ErrMigrateUsage
+		}
+		dir := conf.Storage.MigrationsPath
+		if dir == "" {
+			// new migration is added to the repo and embedded on the next build.
+			dir = "migrations"
+		}
+		return sqlstorage.CreateMigration(dir, args[1])
+	}
+
+	// goose reports applied migrations and status with the standard logger.
+	log.SetFlags(0)
+	log.SetOutput(os.Stdout)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	storageConf := sqlstorage.NewConfig(conf.DB, conf.Storage)
+	storageConf.AutoMigrate = false
+	st := sqlstorage.New(storageConf)
+	if err := st.Connect(ctx); err != nil {
+		return err
+	}
+	defer st.Close()
+
+	return st.Migrate(ctx, args[0], args[1:]...)
+}
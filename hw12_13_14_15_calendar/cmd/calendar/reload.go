@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
+)
+
+// configCheckInterval is how often config file is checked for changes.
+const configCheckInterval = 5 * time.Second
+
+// watchConfig reloads config on SIGHUP and on change of the file. Log level is applied at once,
+// other changed settings are reported until restart.
+func watchConfig(ctx context.Context, watcher *reload.Watcher, logg *logger.Logger, running *Config) {
+	watcher.Run(ctx, func() {
+		next, err := LoadConfig()
+		if err != nil {
+			logg.Error("config is not reloaded: %s", err)
+			return
+		}
+
+		var restart []string
+		for _, key := range reload.Changed(running, next) {
+			switch key {
+			case "logger.level":
+				_ = logg.SetLevel(next.Logger.Level)
+				running.Logger.Level = next.Logger.Level
+			default:
+				restart = append(restart, key)
+			}
+		}
+
+		logg.Info("config is reloaded")
+		if len(restart) > 0 {
+			logg.Warning("restart is needed to apply: %s", strings.Join(restart, ", "))
+		}
+	}, func(err error) {
+		logg.Error("cannot check config: %s", err)
+	})
+}
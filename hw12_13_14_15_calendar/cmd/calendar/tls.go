@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/tlsconfig"
+)
+
+// newTLSConfig returns nil when TLS is not configured for the server. Renewed certificates
+// are picked up every certReloadInterval without restart.
+func newTLSConfig(
+	ctx context.Context,
+	logg *logger.Logger,
+	server string,
+	conf config.TLSConf,
+	protocols ...string,
+) (*tls.Config, error) {
+	if conf.CertFile == "" {
+		return nil, nil
+	}
+
+	reloader, err := tlsconfig.New(tlsconfig.Config{
+		CertFile:     conf.CertFile,
+		KeyFile:      conf.KeyFile,
+		ClientCAFile: conf.ClientCAFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.CertReloadInterval > 0 {
+		go reloader.Watch(ctx, conf.CertReloadInterval, func(err error) {
+			logg.Error("cannot reload %s certificates: %s", server, err)
+		})
+	}
+
+	mode := "TLS"
+	if conf.ClientCAFile != "" {
+		mode = "mutual TLS"
+	}
+	logg.Info("%s-server uses %s", server, mode)
+
+	return reloader.TLSConfig(protocols...), nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// eventFlags are fields of event for create and update.
+type eventFlags struct {
+	title       string
+	date        string
+	end         string
+	allDay      bool
+	duration    time.Duration
+	description string
+	user        int64
+	notify      string
+	color       string
+	location    string
+	tags        string
+	calendar    string
+}
+
+func (f *eventFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.title, "title", "", "Title of the event")
+	fs.StringVar(&f.date, "date", "", "Date and time of the event, the first day of all-day event")
+	fs.StringVar(&f.end, "end", "", "End of the event, the last day of all-day event")
+	fs.BoolVar(&f.allDay, "all-day", false, "All-day event, -date and -end are days")
+	fs.DurationVar(&f.duration, "duration", 0, "Duration of the event, e.g. 1h30m, instead of -end")
+	fs.StringVar(&f.description, "description", "", "Description of the event")
+	fs.Int64Var(&f.user, "user", 0, "Owner of the event")
+	fs.StringVar(&f.notify, "notify", "", "Date and time of notification")
+	fs.StringVar(&f.color, "color", "", "Color of the event, #RRGGBB")
+	fs.StringVar(&f.location, "location", "", "Location of the event")
+	fs.StringVar(&f.tags, "tags", "", "Comma separated tags of the event, empty removes all tags")
+	fs.StringVar(&f.calendar, "calendar", "", "ID of the calendar, the default calendar of the user by default")
+}
+
+// apply sets only fields given in command line, so update keeps other fields of the event.
+// Flags are visited in lexical order, so -all-day comes before -date and -date before -duration and -end.
+func (f *eventFlags) apply(fs *flag.FlagSet, e *event, location *time.Location) error {
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch fl.Name {
+		case "title":
+			e.Title = f.title
+		case "all-day":
+			e.AllDay = f.allDay
+			if e.AllDay && e.StartDate == "" {
+				e.StartDate = e.DateTime.Format(dayLayout)
+			}
+		case "date":
+			var date time.Time
+			if date, err = parseDate(f.date, location); err == nil {
+				e.move(date)
+			}
+		case "duration":
+			if e.AllDay {
+				err = fmt.Errorf("%w: all-day event is set by -end", errUsage)
+				return
+			}
+			e.EndTime = e.DateTime.Add(f.duration)
+		case "end":
+			var end time.Time
+			if end, err = parseDate(f.end, location); err != nil {
+				return
+			}
+			if e.AllDay {
+				e.EndDate = end.Format(dayLayout)
+			} else {
+				e.EndTime = end
+			}
+		case "description":
+			e.Description = f.description
+		case "user":
+			e.UserID = f.user
+		case "notify":
+			var notification time.Time
+			if notification, err = parseDate(f.notify, location); err == nil {
+				e.TimeNotification = &notification
+			}
+		case "color":
+			e.Color = f.color
+		case "location":
+			e.Location = f.location
+		case "tags":
+			e.Tags = splitTags(f.tags)
+		case "calendar":
+			e.CalendarID = f.calendar
+		}
+	})
+
+	return err
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	return nil
+}
+
+func runCreate(ctx context.Context, c *cli, args []string) error {
+	var f eventFlags
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	f.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if f.title == "" || f.date == "" {
+		return fmt.Errorf("%w: -title and -date are required", errUsage)
+	}
+
+	var e event
+	if err := f.apply(fs, &e, c.location); err != nil {
+		return err
+	}
+
+	res, err := c.client.CreateEvent(ctx, &pb.EventRequest{Event: e.toPb()})
+	if err != nil {
+		return err
+	}
+
+	return c.printer.Event(c.out, fromPb(res.Event, c.location))
+}
+
+func runUpdate(ctx context.Context, c *cli, args []string) error {
+	var f eventFlags
+	var id string
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	fs.StringVar(&id, "id", "", "ID of the event")
+	f.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if id == "" {
+		return fmt.Errorf("%w: -id is required", errUsage)
+	}
+
+	// update replaces the whole event, so current values are taken for missing flags.
+	res, err := c.client.GetEvent(ctx, &pb.EventIdRequest{Id: id})
+	if err != nil {
+		return err
+	}
+
+	e := fromPb(res.Event, c.location)
+	if err := f.apply(fs, e, c.location); err != nil {
+		return err
+	}
+
+	if _, err := c.client.UpdateEvent(ctx, &pb.EventUpdateRequest{Id: id, Event: e.toPb()}); err != nil {
+		return err
+	}
+
+	return c.printer.Event(c.out, e)
+}
+
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: event ID is required", errUsage)
+	}
+
+	for _, id := range args {
+		if _, err := c.client.DeleteEvent(ctx, &pb.EventIdRequest{Id: id}); err != nil {
+			return fmt.Errorf("event %s: %w", id, err)
+		}
+		fmt.Fprintf(c.errOut, "event %s is deleted\n", id)
+	}
+
+	return nil
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: one event ID is required", errUsage)
+	}
+
+	res, err := c.client.GetEvent(ctx, &pb.EventIdRequest{Id: args[0]})
+	if err != nil {
+		return err
+	}
+
+	return c.printer.Event(c.out, fromPb(res.Event, c.location))
+}
+
+func runList(ctx context.Context, c *cli, args []string) error {
+	var tag string
+	var user int64
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.StringVar(&tag, "tag", "", "Show only events with the tag")
+	fs.Int64Var(&user, "user", 0, "Show only calendars owned by or shared with the user")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("%w: unexpected arguments", errUsage)
+	}
+
+	res, err := c.client.GetEvents(ctx, &pb.EventsRequest{Tag: tag, UserId: user})
+	if err != nil {
+		return err
+	}
+
+	return c.printer.Events(c.out, c.events(res.Events))
+}
+
+type rangeMethod func(
+	client pb.CalendarServiceClient,
+	ctx context.Context,
+	req *pb.RangeRequest,
+	opts ...grpc.CallOption,
+) (*pb.EventsResponse, error)
+
+// runAgenda shows events of the day, week or month that contains the date, today by default.
+func runAgenda(method rangeMethod, start func(time.Time) time.Time) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		var tag string
+		var user int64
+		fs := flag.NewFlagSet("agenda", flag.ContinueOnError)
+		fs.StringVar(&tag, "tag", "", "Show only events with the tag")
+		fs.Int64Var(&user, "user", 0, "Show only calendars owned by or shared with the user")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+
+		date := time.Now().In(c.location)
+		switch fs.NArg() {
+		case 0:
+		case 1:
+			var err error
+			if date, err = parseDate(fs.Arg(0), c.location); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unexpected arguments", errUsage)
+		}
+
+		// all-day events are matched to days in the time zone of -tz, the name of Local is unknown to the server.
+		var timeZone string
+		if c.location != time.Local {
+			timeZone = c.location.String()
+		}
+
+		res, err := method(c.client, ctx, &pb.RangeRequest{
+			DateTime: timestamppb.New(start(date)),
+			Tag:      tag,
+			UserId:   user,
+			TimeZone: timeZone,
+		})
+		if err != nil {
+			return err
+		}
+
+		return c.printer.Events(c.out, c.events(res.Events))
+	}
+}
+
+// runExport writes all events as JSON array, the file can be loaded by import.
+func runExport(ctx context.Context, c *cli, args []string) error {
+	var file string
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.StringVar(&file, "file", "-", "File to write, - is stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	res, err := c.client.GetEvents(ctx, &pb.EventsRequest{})
+	if err != nil {
+		return err
+	}
+	events := c.events(res.Events)
+
+	w := c.out
+	if file != "-" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := writeJSON(w, events); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.errOut, "exported %d events\n", len(events))
+
+	return nil
+}
+
+// runImport creates events from JSON array. With -update events with known ID are updated instead.
+// Failed events are reported and do not stop the import.
+func runImport(ctx context.Context, c *cli, args []string) error {
+	var file string
+	var update bool
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.StringVar(&file, "file", "-", "File to read, - is stdin")
+	fs.BoolVar(&update, "update", false, "Update events that already exist")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	r := c.in
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var events []*event
+	if err := json.NewDecoder(r).Decode(&events); err != nil {
+		return fmt.Errorf("cannot read events: %w", err)
+	}
+
+	imported := 0
+	for i, e := range events {
+		if err := c.importEvent(ctx, e, update); err != nil {
+			fmt.Fprintf(c.errOut, "event #%d %q: %s\n", i+1, e.Title, status.Convert(err).Message())
+			continue
+		}
+		imported++
+	}
+
+	fmt.Fprintf(c.errOut, "imported %d of %d events\n", imported, len(events))
+	if imported < len(events) {
+		return fmt.Errorf("%d events are not imported", len(events)-imported)
+	}
+
+	return nil
+}
+
+func (c *cli) importEvent(ctx context.Context, e *event, update bool) error {
+	if update && e.ID != "" {
+		_, err := c.client.UpdateEvent(ctx, &pb.EventUpdateRequest{Id: e.ID, Event: e.toPb()})
+		if status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+
+	// ID is generated by the service.
+	_, err := c.client.CreateEvent(ctx, &pb.EventRequest{Event: e.toPb()})
+	return err
+}
+
+// events are sorted by date, so tables are read as agenda.
+func (c *cli) events(events []*pb.Event) []*event {
+	res := make([]*event, 0, len(events))
+	for _, e := range events {
+		res = append(res, fromPb(e, c.location))
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].DateTime.Before(res[j].DateTime)
+	})
+
+	return res
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestEventFlagsApply(t *testing.T) {
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)
+	notification := time.Date(2024, 1, 10, 9, 50, 0, 0, moscow)
+
+	tests := []struct {
+		name  string
+		event event
+		args  []string
+		want  event
+		err   error
+	}{
+		{
+			name: "create",
+			args: []string{
+				"-title", "Standup", "-date", "2024-01-10 10:00", "-duration", "15m",
+				"-notify", "2024-01-10 09:50", "-tags", "work, team,,", "-user", "7",
+			},
+			want: event{
+				Title:            "Standup",
+				DateTime:         start,
+				EndTime:          start.Add(15 * time.Minute),
+				TimeNotification: &notification,
+				Tags:             []string{"work", "team"},
+				UserID:           7,
+			},
+		},
+		{
+			name:  "update keeps other fields and duration",
+			event: event{Title: "Standup", DateTime: start, EndTime: start.Add(time.Hour), Tags: []string{"work"}},
+			args:  []string{"-date", "2024-01-11 12:00"},
+			want: event{
+				Title:    "Standup",
+				DateTime: start.Add(26 * time.Hour),
+				EndTime:  start.Add(27 * time.Hour),
+				Tags:     []string{"work"},
+			},
+		},
+		{
+			name:  "end after date",
+			event: event{DateTime: start, EndTime: start.Add(time.Hour)},
+			args:  []string{"-end", "2024-01-10 12:00", "-date", "2024-01-10 11:00"},
+			want:  event{DateTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)},
+		},
+		{
+			name:  "empty tags remove all tags",
+			event: event{Title: "Standup", Tags: []string{"work"}},
+			args:  []string{"-tags", "", "-color", "#00ff00", "-location", "Room 1"},
+			want:  event{Title: "Standup", Color: "#00ff00", Location: "Room 1"},
+		},
+		{
+			name: "all-day",
+			args: []string{"-all-day", "-date", "2024-01-10", "-end", "2024-01-12"},
+			want: event{
+				AllDay:    true,
+				DateTime:  time.Date(2024, 1, 10, 0, 0, 0, 0, moscow),
+				StartDate: "2024-01-10",
+				EndDate:   "2024-01-12",
+			},
+		},
+		{
+			name: "all-day with duration",
+			args: []string{"-all-day", "-date", "2024-01-10", "-duration", "1h"},
+			err:  errUsage,
+		},
+		{
+			name: "wrong date",
+			args: []string{"-date", "10.01.2024"},
+			err:  errWrongDate,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var f eventFlags
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			f.register(fs)
+			require.NoError(t, parseFlags(fs, tc.args))
+
+			e := tc.event
+			err := f.apply(fs, &e, moscow)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, e)
+		})
+	}
+}
+
+// fakeClient keeps events in memory, only methods used by commands are implemented.
+type fakeClient struct {
+	pb.CalendarServiceClient
+	events []*pb.Event
+}
+
+func (c *fakeClient) GetEvents(context.Context, *pb.EventsRequest, ...grpc.CallOption) (*pb.EventsResponse, error) {
+	return &pb.EventsResponse{Events: c.events}, nil
+}
+
+func (c *fakeClient) CreateEvent(
+	_ context.Context,
+	req *pb.EventRequest,
+	_ ...grpc.CallOption,
+) (*pb.EventResponse, error) {
+	if req.Event.Title == "" {
+		return nil, status.Error(codes.InvalidArgument, "title is required")
+	}
+
+	event := proto.Clone(req.Event).(*pb.Event)
+	event.Id = strconv.Itoa(len(c.events) + 1)
+	c.events = append(c.events, event)
+
+	return &pb.EventResponse{Event: event}, nil
+}
+
+func (c *fakeClient) UpdateEvent(
+	_ context.Context,
+	req *pb.EventUpdateRequest,
+	_ ...grpc.CallOption,
+) (*emptypb.Empty, error) {
+	for i, event := range c.events {
+		if event.Id == req.Id {
+			c.events[i] = proto.Clone(req.Event).(*pb.Event)
+			c.events[i].Id = req.Id
+			return &emptypb.Empty{}, nil
+		}
+	}
+
+	return nil, status.Error(codes.NotFound, "event not found")
+}
+
+func newTestCli(client pb.CalendarServiceClient, in string) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	var out, errOut bytes.Buffer
+	return &cli{
+		client:   client,
+		printer:  tablePrinter{},
+		location: moscow,
+		in:       strings.NewReader(in),
+		out:      &out,
+		errOut:   &errOut,
+	}, &out, &errOut
+}
+
+// withoutIDs clears IDs that are generated on import.
+func withoutIDs(events []*event) []*event {
+	for _, e := range events {
+		e.ID = ""
+	}
+
+	return events
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)
+	notification := start.Add(-10 * time.Minute)
+
+	source := &fakeClient{}
+	for _, e := range []*event{
+		{
+			Title:            "Standup",
+			DateTime:         start,
+			EndTime:          start.Add(15 * time.Minute),
+			Description:      "Daily",
+			UserID:           7,
+			TimeNotification: &notification,
+			Color:            "#00ff00",
+			Location:         "Room 1",
+			Tags:             []string{"team", "work"},
+		},
+		{Title: "Vacation", AllDay: true, StartDate: "2024-01-08", EndDate: "2024-01-09", UserID: 7},
+	} {
+		_, err := source.CreateEvent(ctx, &pb.EventRequest{Event: e.toPb()})
+		require.NoError(t, err)
+	}
+
+	c, exported, _ := newTestCli(source, "")
+	require.NoError(t, runExport(ctx, c, nil))
+
+	target := &fakeClient{}
+	c, _, errOut := newTestCli(target, exported.String())
+	require.NoError(t, runImport(ctx, c, nil))
+	require.Contains(t, errOut.String(), "imported 2 of 2 events")
+	require.Equal(t, withoutIDs(c.events(source.events)), withoutIDs(c.events(target.events)))
+
+	// with -update known events are replaced, unknown ones are created
+	source.events[0].Title = "Changed"
+	source.events = source.events[:1]
+	c, _, _ = newTestCli(source, exported.String())
+	require.NoError(t, runImport(ctx, c, []string{"-update"}))
+	require.Len(t, source.events, 2)
+	require.Equal(t, "Standup", source.events[0].Title)
+	require.Equal(t, "Vacation", source.events[1].Title)
+
+	// failed events are reported and the rest is imported
+	c, _, errOut = newTestCli(&fakeClient{}, `[{"title": ""}, {"title": "Standup", "user_id": 7}]`)
+	require.EqualError(t, runImport(ctx, c, nil), "1 events are not imported")
+	require.Contains(t, errOut.String(), `event #1 "": title is required`)
+	require.Contains(t, errOut.String(), "imported 1 of 2 events")
+}
+
+func TestImportLegacyDuration(t *testing.T) {
+	target := &fakeClient{}
+	c, _, _ := newTestCli(target, `[{"title": "Old", "date_time": "2024-01-10T10:00:00Z", "duration": 5400}]`)
+	require.NoError(t, runImport(context.Background(), c, nil))
+
+	require.Len(t, target.events, 1)
+	require.Equal(t, int64(5400), target.events[0].Duration)
+	require.Nil(t, target.events[0].EndTime)
+}
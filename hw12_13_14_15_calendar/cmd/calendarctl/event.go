@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errWrongDate = errors.New("wrong date")
+
+// dayLayout is the format of days of all-day events.
+const dayLayout = "2006-01-02"
+
+// dateLayouts are tried in order, dates without zone are in the time zone of -tz.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// event is the format of output and of import/export files, the same as in REST API.
+//
+//nolint:tagliatelle
+type event struct {
+	ID               string     `json:"id,omitempty" yaml:"id,omitempty"`
+	CalendarID       string     `json:"calendar_id,omitempty" yaml:"calendar_id,omitempty"`
+	Title            string     `json:"title" yaml:"title"`
+	DateTime         time.Time  `json:"date_time" yaml:"date_time"`
+	EndTime          time.Time  `json:"end_time" yaml:"end_time"`
+	AllDay           bool       `json:"all_day,omitempty" yaml:"all_day,omitempty"`
+	StartDate        string     `json:"start_date,omitempty" yaml:"start_date,omitempty"`
+	EndDate          string     `json:"end_date,omitempty" yaml:"end_date,omitempty"`
+	Description      string     `json:"description,omitempty" yaml:"description,omitempty"`
+	UserID           int64      `json:"user_id" yaml:"user_id"`
+	TimeNotification *time.Time `json:"time_notification,omitempty" yaml:"time_notification,omitempty"`
+	Color            string     `json:"color,omitempty" yaml:"color,omitempty"`
+	Location         string     `json:"location,omitempty" yaml:"location,omitempty"`
+	Tags             []string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Duration in seconds is only read from files exported by older versions.
+	Duration int64 `json:"duration,omitempty" yaml:"duration,omitempty"`
+}
+
+func fromPb(e *pb.Event, location *time.Location) *event {
+	res := &event{
+		ID:          e.Id,
+		CalendarID:  e.CalendarId,
+		Title:       e.Title,
+		AllDay:      e.AllDay,
+		StartDate:   e.StartDate,
+		EndDate:     e.EndDate,
+		Description: e.Description,
+		UserID:      e.UserId,
+		Color:       e.Color,
+		Location:    e.Location,
+		Tags:        e.Tags,
+	}
+	if e.DateTime != nil {
+		res.DateTime = e.DateTime.AsTime().In(location)
+	}
+	if e.EndTime != nil {
+		res.EndTime = e.EndTime.AsTime().In(location)
+	}
+	// days of all-day events are the same everywhere, so they start at the local midnight.
+	if e.AllDay {
+		if start, err := time.ParseInLocation(dayLayout, e.StartDate, location); err == nil {
+			res.DateTime = start
+		}
+		if end, err := time.ParseInLocation(dayLayout, e.EndDate, location); err == nil {
+			res.EndTime = end.AddDate(0, 0, 1)
+		}
+	}
+	if e.TimeNotification != nil {
+		notification := e.TimeNotification.AsTime().In(location)
+		res.TimeNotification = &notification
+	}
+
+	return res
+}
+
+func (e *event) toPb() *pb.Event {
+	res := &pb.Event{
+		Id:          e.ID,
+		CalendarId:  e.CalendarID,
+		Title:       e.Title,
+		Duration:    e.Duration,
+		AllDay:      e.AllDay,
+		StartDate:   e.StartDate,
+		EndDate:     e.EndDate,
+		Description: e.Description,
+		UserId:      e.UserID,
+		Color:       e.Color,
+		Location:    e.Location,
+		Tags:        e.Tags,
+	}
+	if !e.DateTime.IsZero() {
+		res.DateTime = timestamppb.New(e.DateTime)
+	}
+	if !e.EndTime.IsZero() {
+		res.EndTime = timestamppb.New(e.EndTime)
+	}
+	if e.TimeNotification != nil && !e.TimeNotification.IsZero() {
+		res.TimeNotification = timestamppb.New(*e.TimeNotification)
+	}
+
+	return res
+}
+
+func parseDate(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w %q", errWrongDate, value)
+}
+
+// move sets the start of the event and keeps its duration.
+func (e *event) move(start time.Time) {
+	if e.AllDay {
+		if e.EndDate != "" {
+			from, errFrom := time.Parse(dayLayout, e.StartDate)
+			to, errTo := time.Parse(dayLayout, e.EndDate)
+			if errFrom == nil && errTo == nil {
+				e.EndDate = start.AddDate(0, 0, int(to.Sub(from).Hours()/24)).Format(dayLayout)
+			}
+		}
+		e.StartDate = start.Format(dayLayout)
+	} else if !e.EndTime.IsZero() {
+		e.EndTime = start.Add(e.EndTime.Sub(e.DateTime))
+	}
+
+	e.DateTime = start
+}
+
+// length returns the duration of the event, all-day events take whole days.
+func (e *event) length() string {
+	if e.AllDay {
+		start, errStart := time.Parse(dayLayout, e.StartDate)
+		end, errEnd := time.Parse(dayLayout, e.EndDate)
+		if errStart == nil && errEnd == nil {
+			return fmt.Sprintf("%dd", int(end.Sub(start).Hours()/24)+1)
+		}
+	}
+
+	if e.EndTime.IsZero() {
+		return (time.Duration(e.Duration) * time.Second).String()
+	}
+
+	return e.EndTime.Sub(e.DateTime).String()
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart is Monday.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return dayStart(t).AddDate(0, 0, -offset)
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var moscow = time.FixedZone("MSK", 3*60*60)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "2024-01-10T10:00:00Z", want: time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC)},
+		{value: "2024-01-10T10:00:00+05:00", want: time.Date(2024, 1, 10, 5, 0, 0, 0, time.UTC)},
+		{value: "2024-01-10 10:00", want: time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)},
+		{value: "2024-01-10T10:00", want: time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)},
+		{value: "2024-01-10", want: time.Date(2024, 1, 10, 0, 0, 0, 0, moscow)},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.value, func(t *testing.T) {
+			date, err := parseDate(tc.value, moscow)
+			require.NoError(t, err)
+			require.True(t, tc.want.Equal(date), "got %s", date)
+		})
+	}
+
+	for _, value := range []string{"", "10.01.2024", "2024-01-10 10", "tomorrow"} {
+		_, err := parseDate(value, moscow)
+		require.ErrorIs(t, err, errWrongDate, value)
+	}
+}
+
+func TestEventMove(t *testing.T) {
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)
+
+	e := &event{DateTime: start, EndTime: start.Add(90 * time.Minute)}
+	e.move(start.AddDate(0, 0, 1))
+	require.Equal(t, start.AddDate(0, 0, 1), e.DateTime)
+	require.Equal(t, 90*time.Minute, e.EndTime.Sub(e.DateTime))
+
+	// days of all-day events are moved together
+	e = &event{AllDay: true, DateTime: start, StartDate: "2024-01-10", EndDate: "2024-01-12"}
+	e.move(time.Date(2024, 2, 28, 0, 0, 0, 0, moscow))
+	require.Equal(t, "2024-02-28", e.StartDate)
+	require.Equal(t, "2024-03-01", e.EndDate)
+}
+
+func TestEventLength(t *testing.T) {
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)
+
+	tests := []struct {
+		name  string
+		event event
+		want  string
+	}{
+		{name: "end time", event: event{DateTime: start, EndTime: start.Add(15 * time.Minute)}, want: "15m0s"},
+		{name: "all-day", event: event{AllDay: true, StartDate: "2024-01-10", EndDate: "2024-01-12"}, want: "3d"},
+		{name: "legacy duration", event: event{DateTime: start, Duration: 5400}, want: "1h30m0s"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.event.length())
+		})
+	}
+}
+
+func TestRangeStarts(t *testing.T) {
+	wednesday := time.Date(2024, 1, 10, 15, 30, 0, 0, moscow)
+	sunday := time.Date(2024, 1, 14, 23, 59, 0, 0, moscow)
+	monday := time.Date(2024, 1, 8, 0, 0, 0, 0, moscow)
+
+	require.Equal(t, time.Date(2024, 1, 10, 0, 0, 0, 0, moscow), dayStart(wednesday))
+	require.Equal(t, monday, weekStart(wednesday))
+	require.Equal(t, monday, weekStart(sunday))
+	require.Equal(t, monday, weekStart(monday))
+	require.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, moscow), monthStart(sunday))
+}
+
+func TestEventPb(t *testing.T) {
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)
+	notification := start.Add(-10 * time.Minute)
+
+	e := &event{
+		ID:               "1",
+		Title:            "Standup",
+		DateTime:         start,
+		EndTime:          start.Add(15 * time.Minute),
+		UserID:           7,
+		TimeNotification: &notification,
+		Tags:             []string{"work"},
+	}
+	require.Equal(t, e, fromPb(e.toPb(), moscow))
+
+	// all-day events start at the local midnight of their first day
+	allDay := fromPb((&event{AllDay: true, StartDate: "2024-01-10", EndDate: "2024-01-11"}).toPb(), moscow)
+	require.Equal(t, time.Date(2024, 1, 10, 0, 0, 0, 0, moscow), allDay.DateTime)
+	require.Equal(t, time.Date(2024, 1, 12, 0, 0, 0, 0, moscow), allDay.EndTime)
+}
@@ -0,0 +1,226 @@
+// Command calendarctl is a command-line client of CalendarService, it talks to the gRPC API.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/identity"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var errUsage = errors.New("wrong usage")
+
+// Options are common for all commands. Every flag has env variable, flags win.
+type Options struct {
+	Addr     string
+	Timeout  time.Duration
+	Output   string
+	Timezone string
+	// User is sent in the header for servers that trust it, the client certificate may name the user instead.
+	User int64
+	// TLS is used when CA or client certificate is set.
+	CACert string
+	Cert   string
+	Key    string
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"create": {
+		"create -title T -date D [-end D | -duration 1h] [-all-day] [-description S] [-user N] [-notify D] " +
+			"[-color #RRGGBB] [-location S] [-tags A,B] [-calendar ID]",
+		runCreate,
+	},
+	"update": {
+		"update -id ID [-title T] [-date D] [-end D | -duration 1h] [-all-day] [-description S] [-user N] " +
+			"[-notify D] [-color #RRGGBB] [-location S] [-tags A,B] [-calendar ID]",
+		runUpdate,
+	},
+	"delete": {"delete ID...", runDelete},
+	"get":    {"get ID", runGet},
+	"list":   {"list [-tag T] [-user N]", runList},
+	"day":    {"day [-tag T] [-user N] [DATE]", runAgenda(pb.CalendarServiceClient.GetEventsForDay, dayStart)},
+	"week":   {"week [-tag T] [-user N] [DATE]", runAgenda(pb.CalendarServiceClient.GetEventsForWeek, weekStart)},
+	"month":  {"month [-tag T] [-user N] [DATE]", runAgenda(pb.CalendarServiceClient.GetEventsForMonth, monthStart)},
+	"export": {"export [-file F]", runExport},
+	"import": {"import [-file F] [-update]", runImport},
+}
+
+// cli is the state shared by commands.
+type cli struct {
+	client   pb.CalendarServiceClient
+	printer  printer
+	location *time.Location
+	in       io.Reader
+	out      io.Writer
+	errOut   io.Writer
+}
+
+func main() {
+	options := Options{
+		Addr:     env("CALENDARCTL_ADDR", "localhost:8081"),
+		Output:   env("CALENDARCTL_OUTPUT", formatTable),
+		Timezone: env("CALENDARCTL_TIMEZONE", "Local"),
+		CACert:   env("CALENDARCTL_CACERT", ""),
+		Cert:     env("CALENDARCTL_CERT", ""),
+		Key:      env("CALENDARCTL_KEY", ""),
+	}
+	timeout, err := time.ParseDuration(env("CALENDARCTL_TIMEOUT", "10s"))
+	if err != nil {
+		fail(fmt.Errorf("CALENDARCTL_TIMEOUT: %w", err))
+	}
+	if options.User, err = strconv.ParseInt(env("CALENDARCTL_USER", "0"), 10, 64); err != nil {
+		fail(fmt.Errorf("CALENDARCTL_USER: %w", err))
+	}
+
+	flag.StringVar(&options.Addr, "addr", options.Addr, "gRPC address of calendar, env CALENDARCTL_ADDR")
+	flag.DurationVar(&options.Timeout, "timeout", timeout, "Timeout of the command, env CALENDARCTL_TIMEOUT")
+	flag.StringVar(&options.Output, "o", options.Output, "Output format: table|json|yaml, env CALENDARCTL_OUTPUT")
+	flag.StringVar(&options.Timezone, "tz", options.Timezone, "Time zone of dates, env CALENDARCTL_TIMEZONE")
+	flag.Int64Var(&options.User, "as", options.User, "User the commands are run as, env CALENDARCTL_USER")
+	flag.StringVar(&options.CACert, "cacert", options.CACert, "CA of the server certificate, env CALENDARCTL_CACERT")
+	flag.StringVar(&options.Cert, "cert", options.Cert, "Client certificate for mutual TLS, env CALENDARCTL_CERT")
+	flag.StringVar(&options.Key, "key", options.Key, "Key of client certificate, env CALENDARCTL_KEY")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	cmd, found := commands[flag.Arg(0)]
+	if !found {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(options, cmd, flag.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "%s\nusage: calendarctl [flags] %s\n", err, cmd.usage)
+			os.Exit(2)
+		}
+		fail(err)
+	}
+}
+
+func run(options Options, cmd command, args []string) error {
+	printer, err := newPrinter(options.Output)
+	if err != nil {
+		return err
+	}
+
+	location, err := time.LoadLocation(options.Timezone)
+	if err != nil {
+		return fmt.Errorf("unknown time zone: %w", err)
+	}
+
+	creds, err := transportCredentials(options)
+	if err != nil {
+		return err
+	}
+
+	conn, err := grpc.Dial(options.Addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+	defer cancel()
+	if options.User != 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, identity.UserHeader, strconv.FormatInt(options.User, 10))
+	}
+
+	return cmd.run(ctx, &cli{
+		client:   pb.NewCalendarServiceClient(conn),
+		printer:  printer,
+		location: location,
+		in:       os.Stdin,
+		out:      os.Stdout,
+		errOut:   os.Stderr,
+	}, args)
+}
+
+func transportCredentials(options Options) (credentials.TransportCredentials, error) {
+	if options.CACert == "" && options.Cert == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	// system roots are used without -cacert.
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.CACert != "" {
+		pem, err := os.ReadFile(options.CACert)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", options.CACert)
+		}
+	}
+
+	if options.Cert != "" {
+		certificate, err := tls.LoadX509KeyPair(options.Cert, options.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+func env(name, fallback string) string {
+	if value, found := os.LookupEnv(name); found {
+		return value
+	}
+
+	return fallback
+}
+
+// fail prints message of gRPC status without technical prefix.
+func fail(err error) {
+	if st, ok := status.FromError(err); ok {
+		fmt.Fprintf(os.Stderr, "error: %s: %s\n", st.Code(), st.Message())
+	} else {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	}
+	os.Exit(1)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: calendarctl [flags] <command> [args]\n\ncommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "  version\n\n"+
+		"dates are RFC 3339, \"2006-01-02 15:04\" or \"2006-01-02\" in the time zone of -tz\n\nflags:\n")
+	flag.PrintDefaults()
+}
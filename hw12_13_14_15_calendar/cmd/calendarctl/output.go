@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+var errUnknownFormat = errors.New("unknown output format")
+
+// printer writes results of commands to stdout. Notes for humans are written to stderr,
+// so json and yaml output may be piped to other tools.
+type printer interface {
+	Event(w io.Writer, e *event) error
+	Events(w io.Writer, events []*event) error
+}
+
+func newPrinter(format string) (printer, error) {
+	switch format {
+	case formatTable:
+		return tablePrinter{}, nil
+	case formatJSON:
+		return jsonPrinter{}, nil
+	case formatYAML:
+		return yamlPrinter{}, nil
+	default:
+		return nil, fmt.Errorf("%w %q, use table, json or yaml", errUnknownFormat, format)
+	}
+}
+
+type tablePrinter struct{}
+
+func (p tablePrinter) Event(w io.Writer, e *event) error {
+	return p.Events(w, []*event{e})
+}
+
+func (tablePrinter) Events(w io.Writer, events []*event) error {
+	if len(events) == 0 {
+		_, err := fmt.Fprintln(w, "no events")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDATE\tTIME\tDURATION\tUSER\tTITLE\tTAGS")
+	for _, e := range events {
+		clock := e.DateTime.Format("15:04")
+		if e.AllDay {
+			clock = "all day"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.ID,
+			e.DateTime.Format("Mon 2006-01-02"),
+			clock,
+			e.length(),
+			e.UserID,
+			e.Title,
+			strings.Join(e.Tags, ","),
+		)
+	}
+
+	return tw.Flush()
+}
+
+type jsonPrinter struct{}
+
+func (jsonPrinter) Event(w io.Writer, e *event) error {
+	return writeJSON(w, e)
+}
+
+func (jsonPrinter) Events(w io.Writer, events []*event) error {
+	if events == nil {
+		events = []*event{}
+	}
+
+	return writeJSON(w, events)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+type yamlPrinter struct{}
+
+func (yamlPrinter) Event(w io.Writer, e *event) error {
+	return writeYAML(w, e)
+}
+
+func (yamlPrinter) Events(w io.Writer, events []*event) error {
+	if events == nil {
+		events = []*event{}
+	}
+
+	return writeYAML(w, events)
+}
+
+func writeYAML(w io.Writer, v any) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
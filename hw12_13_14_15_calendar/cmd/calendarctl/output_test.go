@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func testEvents() []*event {
+	start := time.Date(2024, 1, 10, 10, 0, 0, 0, moscow)
+	return []*event{
+		{
+			ID:       "1",
+			Title:    "Standup",
+			DateTime: start,
+			EndTime:  start.Add(15 * time.Minute),
+			UserID:   7,
+			Tags:     []string{"team", "work"},
+		},
+		{
+			ID:        "2",
+			Title:     "Vacation",
+			AllDay:    true,
+			DateTime:  time.Date(2024, 1, 11, 0, 0, 0, 0, moscow),
+			EndTime:   time.Date(2024, 1, 14, 0, 0, 0, 0, moscow),
+			StartDate: "2024-01-11",
+			EndDate:   "2024-01-13",
+			UserID:    7,
+		},
+	}
+}
+
+func TestNewPrinter(t *testing.T) {
+	for format, want := range map[string]printer{
+		formatTable: tablePrinter{},
+		formatJSON:  jsonPrinter{},
+		formatYAML:  yamlPrinter{},
+	} {
+		p, err := newPrinter(format)
+		require.NoError(t, err)
+		require.Equal(t, want, p)
+	}
+
+	_, err := newPrinter("xml")
+	require.ErrorIs(t, err, errUnknownFormat)
+}
+
+func TestTablePrinter(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, tablePrinter{}.Events(&out, testEvents()))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"ID", "DATE", "TIME", "DURATION", "USER", "TITLE", "TAGS"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"1", "Wed", "2024-01-10", "10:00", "15m0s", "7", "Standup", "team,work"},
+		strings.Fields(lines[1]))
+	require.Equal(t, []string{"2", "Thu", "2024-01-11", "all", "day", "3d", "7", "Vacation"}, strings.Fields(lines[2]))
+
+	out.Reset()
+	require.NoError(t, tablePrinter{}.Events(&out, nil))
+	require.Equal(t, "no events\n", out.String())
+}
+
+func TestJSONPrinter(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, jsonPrinter{}.Events(&out, testEvents()))
+
+	// output is the format of import files
+	var events []*event
+	require.NoError(t, json.Unmarshal(out.Bytes(), &events))
+	require.Len(t, events, 2)
+	require.Equal(t, "Standup", events[0].Title)
+	require.True(t, testEvents()[0].DateTime.Equal(events[0].DateTime))
+	require.Equal(t, "2024-01-13", events[1].EndDate)
+	require.Contains(t, out.String(), `"date_time": "2024-01-10T10:00:00+03:00"`)
+
+	out.Reset()
+	require.NoError(t, jsonPrinter{}.Event(&out, testEvents()[0]))
+	require.True(t, strings.HasPrefix(out.String(), "{\n  \"id\": \"1\""), out.String())
+
+	out.Reset()
+	require.NoError(t, jsonPrinter{}.Events(&out, nil))
+	require.Equal(t, "[]\n", out.String())
+}
+
+func TestYAMLPrinter(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, yamlPrinter{}.Events(&out, testEvents()))
+
+	var events []map[string]any
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &events))
+	require.Len(t, events, 2)
+	require.Equal(t, "Standup", events[0]["title"])
+	require.Equal(t, []any{"team", "work"}, events[0]["tags"])
+	require.Equal(t, true, events[1]["all_day"])
+
+	out.Reset()
+	require.NoError(t, yamlPrinter{}.Events(&out, nil))
+	require.Equal(t, "[]\n", out.String())
+}
@@ -1,79 +1,54 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strings"
-	"time"
 
-	"github.com/spf13/viper"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
 )
 
-// При желании конфигурацию можно вынести в internal/config.
+// Общие секции описаны в internal/config, здесь только их набор для сервиса.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger    LoggerConf    `mapstructure:"logger"`
-	Storage   StorageConf   `mapstructure:"storage"`
-	DB        DBConf        `mapstructure:"db"`
-	Scheduler SchedulerConf `mapstructure:"scheduler"`
-	Rmq       RMQConf       `mapstructure:"rmq"`
+	Logger    config.LoggerConf    `mapstructure:"logger"`
+	Storage   config.StorageConf   `mapstructure:"storage"`
+	DB        config.DBConf        `mapstructure:"db"`
+	Scheduler config.SchedulerConf `mapstructure:"scheduler"`
+	Jobs      config.JobsConf      `mapstructure:"jobs"`
+	Broker    config.BrokerConf    `mapstructure:"broker"`
+	Rmq       config.RMQConf       `mapstructure:"rmq"`
+	Shutdown  config.ShutdownConf  `mapstructure:"shutdown"`
 }
 
-type LoggerConf struct {
-	Level string `mapstructure:"level"`
-	Path  string `mapstructure:"path"`
+func (c *Config) Defaults() map[string]any {
+	return config.JobsDefaults()
 }
 
-type StorageConf struct {
-	Driver         string `mapstructure:"driver"`
-	MigrationsPath string `mapstructure:"migrations_path"`
-}
-
-type DBConf struct {
-	DBHost     string `mapstructure:"host"`
-	DBPort     int    `mapstructure:"port"`
-	DBName     string `mapstructure:"name"`
-	DBUsername string `mapstructure:"username"`
-	DBPassword string `mapstructure:"password"`
-}
-
-type SchedulerConf struct {
-	RunFrequencyInterval   time.Duration `mapstructure:"runFrequencyInterval"`
-	TimeForRemoveOldEvents time.Duration `mapstructure:"timeForRemoveOldEvents"`
-}
-
-type ExchangeConf struct {
-	Name       string `mapstructure:"name"`
-	Type       string `mapstructure:"type"`
-	QueueName  string `mapstructure:"queueName"`
-	BindingKey string `mapstructure:"bindingKey"`
-}
-
-type RMQConf struct {
-	URI             string        `mapstructure:"uri"`
-	ConsumerTag     string        `mapstructure:"consumerTag"`
-	MaxElapsedTime  string        `mapstructure:"maxElapsedTime"`
-	InitialInterval string        `mapstructure:"initialInterval"`
-	Multiplier      int           `mapstructure:"multiplier"`
-	MaxInterval     time.Duration `mapstructure:"maxInterval"`
-	Exchange        ExchangeConf
+func (c *Config) Validate() error {
+	return errors.Join(
+		config.CheckStorage(c.Storage, c.DB),
+		config.CheckBroker(c.Broker, c.Rmq),
+	)
 }
 
 func NewConfig() *Config {
-	v := viper.New()
-	v.SetConfigFile(configFile)
-	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-
-	if err := v.ReadInConfig(); err != nil {
-		fmt.Printf("couldn't load config: %s", err)
+	conf, err := LoadConfig()
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
-	var config Config
-	if err := v.Unmarshal(&config); err != nil {
-		fmt.Printf("couldn't read config: %s", err)
+
+	return conf
+}
+
+// LoadConfig reads and validates config file, it is used on start and on reload.
+func LoadConfig() (*Config, error) {
+	var conf Config
+	if err := config.Load(configFile, &conf); err != nil {
+		return nil, err
 	}
 
-	return &config
+	return &conf, nil
 }
@@ -3,19 +3,28 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for images without system tzdata
 
-	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/scheduler"
+	schedulerapp "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/scheduler"
+	internalconfig "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	boltstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/bolt"
 	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	filebroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/file"
+	memorybroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/memory"
+	rmqbroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/rmq"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/lifecycle"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/rmq"
 )
 
@@ -33,81 +42,189 @@ func main() {
 		return
 	}
 
-	// init context
-	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGTSTP)
-	defer cancel()
+	if flag.Arg(0) == "config" {
+		if err := internalconfig.Command(os.Stdout, flag.Args()[1:], configFile, &Config{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	os.Exit(run())
+}
 
+// run returns exit code, so deferred closing is done before exit.
+func run() int {
 	config := NewConfig()
+	watcher := reload.New(configFile, configCheckInterval)
 
-	logg := logger.New(config.Logger.Level, os.Stdout)
+	logOutput, err := config.Logger.Open()
+	if err != nil {
+		fmt.Println("cannot open log: " + err.Error())
+		return 1
+	}
+	defer logOutput.Close()
 
-	var eventStorage storage.EventStorage
-	if config.Storage.Driver == "postgres" {
-		connectionString := fmt.Sprintf(
-			"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-			config.DB.DBHost, config.DB.DBPort, config.DB.DBUsername, config.DB.DBPassword, config.DB.DBName,
-		)
+	logg := logger.New(config.Logger.Level, logOutput)
+
+	// signals are handled by the group.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		eventStorage = sqlstorage.New(connectionString, config.Storage.MigrationsPath)
-		err := eventStorage.Connect(ctx)
+	group := lifecycle.New(config.Shutdown.Timeout, func(name string, err error) {
 		if err != nil {
-			logg.Error("cannot connect to DB server: " + err.Error())
-			cancel()
-			os.Exit(1) //nolint:gocritic
+			logg.Error("%s is stopped: %s", name, err)
+			return
 		}
-		defer eventStorage.Close()
-	} else {
+		logg.Info("%s is stopped", name)
+	})
+
+	var eventStorage storage.Storage
+	switch config.Storage.Driver {
+	case internalconfig.StoragePostgres:
+		eventStorage = sqlstorage.New(sqlstorage.NewConfig(config.DB, config.Storage))
+	case internalconfig.StorageBolt:
+		eventStorage = boltstorage.New(config.Storage.Path)
+	case internalconfig.StorageMemory:
 		eventStorage = memorystorage.New()
 	}
 
+	if err := eventStorage.Connect(ctx); err != nil {
+		logg.Error("cannot connect to storage: " + err.Error())
+		return 1
+	}
+	// storage is added first, so it is closed last.
+	group.AddCloser("storage", eventStorage.Close)
+
 	logg.Info(fmt.Sprintf("successfully init %s storage", config.Storage.Driver))
 
-	rmqInstance := rmq.NewRmq(
-		config.Rmq.ConsumerTag,
-		config.Rmq.URI,
-		config.Rmq.Exchange.Name,
-		config.Rmq.Exchange.Type,
-		config.Rmq.Exchange.QueueName,
-		config.Rmq.Exchange.BindingKey,
-		config.Rmq.MaxInterval,
+	messageBroker := newBroker(config)
+
+	if err := messageBroker.Connect(ctx); err != nil {
+		logg.Error("cannot connect to message broker: " + err.Error())
+		group.Close()
+		return 1
+	}
+	group.AddCloser("message broker", messageBroker.Close)
+
+	logg.Info(fmt.Sprintf("successfully init %s broker", config.Broker.Driver))
+
+	scheduler := schedulerapp.New(
+		logg,
+		eventStorage,
+		messageBroker,
+		config.Scheduler.RunFrequencyInterval,
+		config.Scheduler.TimeForRemoveOldEvents,
+		schedulerapp.ClaimConfig{
+			InstanceID: instanceID(config.Scheduler.InstanceID),
+			Lease:      config.Scheduler.ClaimLease,
+			BatchSize:  config.Scheduler.ClaimBatchSize,
+		},
 	)
 
-	err := rmqInstance.Connect()
-	if err != nil {
-		logg.Error("cannot connect to AMQP server: " + err.Error())
-		cancel()
-		os.Exit(1)
+	runner := jobs.New(config.Scheduler.HistorySize, func(run jobs.Run) {
+		switch {
+		case run.Skipped:
+			logg.Warning("job %s is skipped, previous run is not finished", run.Job)
+		case run.Error != "":
+			logg.Error("job %s failed in %s: %s", run.Job, run.Duration, run.Error)
+		default:
+			logg.Debug("job %s is done in %s", run.Job, run.Duration)
+		}
+	})
+
+	if err := scheduler.AddJobs(runner, config.Jobs); err != nil {
+		logg.Error(err.Error())
+		group.Close()
+		return 1
 	}
 
-	var wg sync.WaitGroup
+	expvar.Publish("jobs", runner.Metrics())
+	if config.Scheduler.MetricsAddr != "" {
+		server := metricsServer(config.Scheduler.MetricsAddr)
+		logg.Info("metrics are served on %s/debug/vars", config.Scheduler.MetricsAddr)
+		group.Add(lifecycle.Component{
+			Name: "metrics server",
+			Run: func(context.Context) error {
+				if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+					return err
+				}
+				return nil
+			},
+			Stop: server.Shutdown,
+		})
+	}
 
-	go func() {
-		<-ctx.Done()
+	// runs of jobs are cancelled and waited for before the broker is closed
+	group.Add(lifecycle.Component{Name: "jobs", Run: lifecycle.Wait(runner.Run)})
+
+	// due reminders trigger the job at once, its schedule is only a safety net
+	group.Add(lifecycle.Component{Name: "reminders", Run: lifecycle.Wait(func(ctx context.Context) {
+		scheduler.WatchReminders(ctx, func() {
+			if err := runner.Trigger(schedulerapp.NotificationsJob); err != nil {
+				logg.Error(err.Error())
+			}
+		})
+	})})
+
+	group.Add(lifecycle.Component{Name: "config watcher", Run: lifecycle.Wait(func(ctx context.Context) {
+		watchConfig(ctx, watcher, logg, scheduler, runner, config)
+	})})
+
+	if err := group.Run(ctx); err != nil {
+		logg.Error("scheduler is stopped with error: %s", err)
+		return 1
+	}
 
-		_, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+	logg.Info("scheduler is stopped")
+	return 0
+}
 
-		if err := rmqInstance.Shutdown(); err != nil && !errors.Is(err, rmq.ErrChannelClosed) {
-			logg.Error("failed to shutdown RMQ server: " + err.Error())
-		}
+// metricsServer exposes expvar with job stats on /debug/vars.
+func metricsServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           expvar.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
 
-		logg.Info("RMQ server successfully terminated!")
+// instanceID identifies scheduler replica in claimed events. Hostname is unique for every pod.
+func instanceID(configured string) string {
+	if configured != "" {
+		return configured
+	}
 
-		wg.Done()
-	}()
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "scheduler"
+	}
 
-	scheduler := scheduler.New(
-		logg,
-		eventStorage,
-		rmqInstance,
-		config.Scheduler.RunFrequencyInterval,
-		config.Scheduler.TimeForRemoveOldEvents,
-	)
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
 
-	wg.Add(1)
-	go func() {
-		scheduler.NotificationSender(ctx)
-	}()
-	wg.Wait()
+func newBroker(config *Config) broker.Broker {
+	switch config.Broker.Driver {
+	case internalconfig.BrokerMemory:
+		return memorybroker.New(config.Broker.Size)
+	case internalconfig.BrokerFile:
+		return filebroker.New(config.Broker.Path)
+	default:
+		client := rmq.NewRmq(
+			config.Rmq.ConsumerTag,
+			config.Rmq.URI,
+			config.Rmq.Exchange.Name,
+			config.Rmq.Exchange.Type,
+			config.Rmq.Exchange.QueueName,
+			config.Rmq.Exchange.BindingKey,
+			config.Rmq.MaxInterval,
+		)
+		client.MaxElapsedTime = config.Rmq.MaxElapsedTime
+		client.InitialInterval = config.Rmq.InitialInterval
+		client.Multiplier = config.Rmq.Multiplier
+		client.PublishTimeout = config.Rmq.PublishTimeout
+		client.Mandatory = config.Rmq.Mandatory
+
+		return rmqbroker.New(client)
+	}
 }
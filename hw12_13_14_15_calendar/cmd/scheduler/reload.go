@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/scheduler"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
+)
+
+// configCheckInterval is how often config file is checked for changes.
+const configCheckInterval = 5 * time.Second
+
+// watchConfig reloads config on SIGHUP and on change of the file. Log level, intervals of scheduler
+// and settings of jobs are applied at once, other changed settings are reported until restart.
+func watchConfig(
+	ctx context.Context,
+	watcher *reload.Watcher,
+	logg *logger.Logger,
+	s *scheduler.Scheduler,
+	runner *jobs.Runner,
+	running *Config,
+) {
+	watcher.Run(ctx, func() {
+		next, err := LoadConfig()
+		if err != nil {
+			logg.Error("config is not reloaded: %s", err)
+			return
+		}
+
+		// broken schedule rejects the whole config, nothing is applied.
+		list, err := s.Jobs(next.Jobs)
+		if err != nil {
+			logg.Error("config is not reloaded: %s", err)
+			return
+		}
+
+		var restart []string
+		changedJobs := make(map[string]bool)
+		for _, key := range reload.Changed(running, next) {
+			switch {
+			case key == "logger.level":
+				_ = logg.SetLevel(next.Logger.Level)
+			case key == "scheduler.runFrequencyInterval", key == "scheduler.timeForRemoveOldEvents":
+				s.SetIntervals(next.Scheduler.RunFrequencyInterval, next.Scheduler.TimeForRemoveOldEvents)
+			case strings.HasPrefix(key, "jobs."):
+				changedJobs[strings.Split(key, ".")[1]] = true
+			default:
+				restart = append(restart, key)
+			}
+		}
+
+		for _, job := range list {
+			if !changedJobs[job.Name] {
+				continue
+			}
+			if err := runner.Update(job); err != nil {
+				logg.Error("cannot update job %s: %s", job.Name, err)
+				continue
+			}
+			logg.Info("job %s is rescheduled: %v", job.Name, job.Schedule)
+		}
+
+		running.Logger.Level = next.Logger.Level
+		running.Scheduler.RunFrequencyInterval = next.Scheduler.RunFrequencyInterval
+		running.Scheduler.TimeForRemoveOldEvents = next.Scheduler.TimeForRemoveOldEvents
+		running.Jobs = next.Jobs
+
+		logg.Info("config is reloaded")
+		if len(restart) > 0 {
+			logg.Warning("restart is needed to apply: %s", strings.Join(restart, ", "))
+		}
+	}, func(err error) {
+		logg.Error("cannot check config: %s", err)
+	})
+}
@@ -2,16 +2,26 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
+	"fmt"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for images without system tzdata
 
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender/templates"
+	internalconfig "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	boltstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/bolt"
+	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	sqlstorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	filebroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/file"
+	memorybroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/memory"
+	rmqbroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/rmq"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/lifecycle"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/rmq"
 )
 
@@ -29,57 +39,134 @@ func main() {
 		return
 	}
 
-	// init context
-	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGTSTP)
-	defer cancel()
+	if flag.Arg(0) == "config" {
+		if err := internalconfig.Command(os.Stdout, flag.Args()[1:], configFile, &Config{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
+	os.Exit(run())
+}
+
+// run returns exit code, so deferred closing is done before exit.
+func run() int {
 	config := NewConfig()
+	watcher := reload.New(configFile, configCheckInterval)
+
+	logOutput, err := config.Logger.Open()
+	if err != nil {
+		fmt.Println("cannot open log: " + err.Error())
+		return 1
+	}
+	defer logOutput.Close()
 
-	logg := logger.New(config.Logger.Level, os.Stdout)
+	logg := logger.New(config.Logger.Level, logOutput)
 
-	rmqInstance := rmq.NewRmq(
-		config.Rmq.ConsumerTag,
-		config.Rmq.URI,
-		config.Rmq.Exchange.Name,
-		config.Rmq.Exchange.Type,
-		config.Rmq.Exchange.QueueName,
-		config.Rmq.Exchange.BindingKey,
-		config.Rmq.MaxInterval,
-	)
+	// signals are handled by the group, ctx stops background watchers on return.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	err := rmqInstance.Connect()
-	if err != nil {
-		logg.Error("cannot connect to AMQP server: " + err.Error())
-		return
+	group := lifecycle.New(config.Shutdown.Timeout, func(name string, err error) {
+		if err != nil {
+			logg.Error("%s is stopped: %s", name, err)
+			return
+		}
+		logg.Info("%s is stopped", name)
+	})
+
+	var senderStorage storage.Storage
+	switch config.Storage.Driver {
+	case internalconfig.StoragePostgres:
+		senderStorage = sqlstorage.New(sqlstorage.NewConfig(config.DB, config.Storage))
+	case internalconfig.StorageBolt:
+		senderStorage = boltstorage.New(config.Storage.Path)
+	case internalconfig.StorageMemory:
+		senderStorage = memorystorage.New()
 	}
 
-	var wg sync.WaitGroup
+	if err := senderStorage.Connect(ctx); err != nil {
+		logg.Error("cannot connect to storage: " + err.Error())
+		return 1
+	}
+	// storage is added first, so it is closed last.
+	group.AddCloser("storage", senderStorage.Close)
 
-	go func() {
-		<-ctx.Done()
+	logg.Info(fmt.Sprintf("successfully init %s storage", config.Storage.Driver))
 
-		_, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+	messageBroker := newBroker(config)
 
-		if err := rmqInstance.Shutdown(); err != nil && !errors.Is(err, rmq.ErrChannelClosed) {
-			logg.Error("failed to shutdown RMQ server: " + err.Error())
-		}
+	if err := messageBroker.Connect(ctx); err != nil {
+		logg.Error("cannot connect to message broker: " + err.Error())
+		group.Close()
+		return 1
+	}
+	group.AddCloser("message broker", messageBroker.Close)
 
-		logg.Info("RMQ server successfully terminated!")
+	logg.Info(fmt.Sprintf("successfully init %s broker", config.Broker.Driver))
 
-		wg.Done()
-	}()
+	// time zone is checked by LoadConfig.
+	location, _ := time.LoadLocation(config.Sender.Timezone)
 
-	sender := sender.New(logg, rmqInstance, config.Sender.Threads)
+	// broken templates are found on start, not on the first notification
+	renderer := templates.New(config.Templates.Path, config.Sender.Locale)
+	if err := renderer.Load(); err != nil {
+		logg.Error("cannot load templates: " + err.Error())
+		group.Close()
+		return 1
+	}
 
-	wg.Add(1)
-	go func() {
-		err := sender.Consume(ctx)
-		if err != nil {
-			wg.Done()
-			logg.Error("cannot init conumer for AMQP server: %s", err.Error())
-		}
-	}()
-	wg.Wait()
+	if config.Templates.ReloadInterval > 0 {
+		go renderer.Watch(ctx, config.Templates.ReloadInterval, func(err error) {
+			logg.Error("cannot reload templates: " + err.Error())
+		})
+	}
+
+	sender := sender.New(logg, messageBroker, config.Sender.Threads, renderer, senderStorage, sender.Defaults{
+		Channel:  config.Sender.Channel,
+		Locale:   config.Sender.Locale,
+		Location: location,
+	})
+
+	// consumer waits for its workers, so messages in work are finished before the broker is closed
+	group.Add(lifecycle.Component{Name: "consumer", Run: sender.Consume})
+	group.Add(lifecycle.Component{Name: "outbox", Run: lifecycle.Wait(func(ctx context.Context) {
+		sender.RunOutbox(ctx, config.Sender.FlushInterval)
+	})})
+	group.Add(lifecycle.Component{Name: "config watcher", Run: lifecycle.Wait(func(ctx context.Context) {
+		watchConfig(ctx, watcher, logg, sender, config)
+	})})
+
+	if err := group.Run(ctx); err != nil {
+		logg.Error("sender is stopped with error: %s", err)
+		return 1
+	}
+
+	logg.Info("sender is stopped")
+	return 0
+}
+
+func newBroker(config *Config) broker.Broker {
+	switch config.Broker.Driver {
+	case internalconfig.BrokerMemory:
+		return memorybroker.New(config.Broker.Size)
+	case internalconfig.BrokerFile:
+		return filebroker.New(config.Broker.Path)
+	default:
+		client := rmq.NewRmq(
+			config.Rmq.ConsumerTag,
+			config.Rmq.URI,
+			config.Rmq.Exchange.Name,
+			config.Rmq.Exchange.Type,
+			config.Rmq.Exchange.QueueName,
+			config.Rmq.Exchange.BindingKey,
+			config.Rmq.MaxInterval,
+		)
+		client.MaxElapsedTime = config.Rmq.MaxElapsedTime
+		client.InitialInterval = config.Rmq.InitialInterval
+		client.Multiplier = config.Rmq.Multiplier
+
+		return rmqbroker.New(client)
+	}
 }
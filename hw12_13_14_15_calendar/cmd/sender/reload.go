@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
+)
+
+// configCheckInterval is how often config file is checked for changes.
+const configCheckInterval = 5 * time.Second
+
+// watchConfig reloads config on SIGHUP and on change of the file. Log level and [sender] settings
+// are applied at once, other changed settings are reported until restart.
+func watchConfig(ctx context.Context, watcher *reload.Watcher, logg *logger.Logger, s *sender.Sender, running *Config) {
+	watcher.Run(ctx, func() {
+		next, err := LoadConfig()
+		if err != nil {
+			logg.Error("config is not reloaded: %s", err)
+			return
+		}
+
+		var restart []string
+		defaultsChanged := false
+		for _, key := range reload.Changed(running, next) {
+			switch key {
+			case "logger.level":
+				_ = logg.SetLevel(next.Logger.Level)
+			case "sender.threads":
+				s.SetThreads(next.Sender.Threads)
+			case "sender.flushInterval":
+				s.SetFlushInterval(next.Sender.FlushInterval)
+			case "sender.channel", "sender.locale", "sender.timezone":
+				defaultsChanged = true
+			default:
+				restart = append(restart, key)
+			}
+		}
+
+		if defaultsChanged {
+			// time zone is checked by LoadConfig.
+			location, _ := time.LoadLocation(next.Sender.Timezone)
+			s.SetDefaults(sender.Defaults{
+				Channel:  next.Sender.Channel,
+				Locale:   next.Sender.Locale,
+				Location: location,
+			})
+		}
+
+		running.Logger.Level = next.Logger.Level
+		running.Sender = next.Sender
+
+		logg.Info("config is reloaded")
+		if len(restart) > 0 {
+			logg.Warning("restart is needed to apply: %s", strings.Join(restart, ", "))
+		}
+	}, func(err error) {
+		logg.Error("cannot check config: %s", err)
+	})
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+// RoleOwner is the access of the owner to its calendar, it is never shared.
+const RoleOwner storage.Role = "owner"
+
+var (
+	ErrUnauthenticated  = errors.New("user is not authenticated")
+	ErrAnotherUser      = errors.New("user can not act on behalf of another user")
+	ErrNotCalendarOwner = errors.New("only the owner can manage the calendar")
+)
+
+// CurrentUser returns the user the request is made by. It is taken from the client certificate
+// or from the header of a trusted client, see identity.UserHeader, users of request bodies are never trusted.
+func CurrentUser(ctx context.Context) (int64, error) {
+	if id := ClientIdentity(ctx); id != nil && id.UserID != 0 {
+		return id.UserID, nil
+	}
+
+	return 0, apperror.Wrap(apperror.CodeUnauthenticated, ErrUnauthenticated)
+}
+
+// actAs sets the current user to the user field of the request, another user is forbidden.
+func actAs(ctx context.Context, userID *int64) error {
+	current, err := CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if *userID != 0 && *userID != current {
+		return apperror.Wrap(apperror.CodeForbidden, ErrAnotherUser)
+	}
+
+	*userID = current
+	return nil
+}
+
+// calendarRole returns access of the user to the calendar, the empty role means no access at all.
+func (a *App) calendarRole(ctx context.Context, calendar *storage.Calendar, userID int64) (storage.Role, error) {
+	if calendar.OwnerID == userID {
+		return RoleOwner, nil
+	}
+
+	shares, err := a.storage.GetShares(ctx, calendar.ID)
+	if err != nil {
+		return "", storageError(err)
+	}
+	for _, share := range shares {
+		if share.UserID == userID {
+			return share.Role, nil
+		}
+	}
+
+	return "", nil
+}
+
+// writeCalendar checks that the user owns the calendar or may change its events.
+func (a *App) writeCalendar(ctx context.Context, calendarID uuid.UUID, userID int64) error {
+	calendar, err := a.storage.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	role, err := a.calendarRole(ctx, calendar, userID)
+	if err != nil {
+		return err
+	}
+	if role != RoleOwner && role != storage.RoleWrite {
+		return apperror.Wrap(apperror.CodeForbidden, ErrCalendarReadOnly)
+	}
+
+	return nil
+}
+
+// ownCalendar returns the calendar when the current user owns it.
+func (a *App) ownCalendar(ctx context.Context, calendarID uuid.UUID) (*storage.Calendar, error) {
+	userID, err := CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := a.storage.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if calendar.OwnerID != userID {
+		return nil, apperror.Wrap(apperror.CodeForbidden, ErrNotCalendarOwner)
+	}
+
+	return calendar, nil
+}
+
+// visibleEvent applies the role of the current user to the event, events of calendars
+// without access are not found.
+func (a *App) visibleEvent(ctx context.Context, event *storage.Event) (*storage.Event, error) {
+	userID, err := CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := a.storage.GetCalendar(ctx, event.CalendarID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	role, err := a.calendarRole(ctx, calendar, userID)
+	switch {
+	case err != nil:
+		return nil, err
+	case role == "":
+		return nil, storageError(storage.ErrEventNotFound)
+	case role == storage.RoleFreeBusy:
+		return busyEvent(event), nil
+	}
+
+	return event, nil
+}
+
+// busyEvent keeps only the time of the event, it is shown with free/busy role.
+func busyEvent(event *storage.Event) *storage.Event {
+	return &storage.Event{
+		ID:         event.ID,
+		CalendarID: event.CalendarID,
+		Title:      BusyTitle,
+		DateTime:   event.DateTime,
+		EndTime:    event.EndTime,
+		AllDay:     event.AllDay,
+		UserID:     event.UserID,
+	}
+}
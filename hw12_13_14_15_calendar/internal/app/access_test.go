@@ -0,0 +1,190 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/identity"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	owner    int64 = 1
+	freeBusy int64 = 2
+	reader   int64 = 3
+	writer   int64 = 4
+	stranger int64 = 5
+)
+
+func as(userID int64) context.Context {
+	return identity.NewContext(context.Background(), &identity.Identity{UserID: userID})
+}
+
+// newSharedCalendar creates the calendar of the owner shared with every role and the event in it.
+func newSharedCalendar(t *testing.T) (*App, *storage.Calendar, *storage.Event) {
+	t.Helper()
+
+	a := New(logger.New("ERROR", io.Discard), memorystorage.New())
+
+	calendar := &storage.Calendar{Name: "Team"}
+	require.NoError(t, a.CreateCalendar(as(owner), calendar))
+	require.Equal(t, owner, calendar.OwnerID)
+
+	for userID, role := range map[int64]storage.Role{
+		freeBusy: storage.RoleFreeBusy,
+		reader:   storage.RoleRead,
+		writer:   storage.RoleWrite,
+	} {
+		share := &storage.Share{CalendarID: calendar.ID, UserID: userID, Role: role}
+		require.NoError(t, a.ShareCalendar(as(owner), share))
+	}
+
+	event := newEvent(calendar.ID)
+	event.Description = "Quarterly plans"
+	require.NoError(t, a.CreateEvent(as(owner), event))
+
+	return a, calendar, event
+}
+
+func newEvent(calendarID uuid.UUID) *storage.Event {
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	return &storage.Event{
+		CalendarID: calendarID,
+		Title:      "Planning",
+		DateTime:   start,
+		EndTime:    start.Add(time.Hour),
+	}
+}
+
+func requireCode(t *testing.T, code apperror.Code, err error) {
+	t.Helper()
+
+	require.Error(t, err)
+	require.Equal(t, code, apperror.CodeOf(err), err.Error())
+}
+
+func TestCalendarManagedByOwner(t *testing.T) {
+	for _, userID := range []int64{freeBusy, reader, writer, stranger} {
+		a, calendar, _ := newSharedCalendar(t)
+		ctx := as(userID)
+
+		err := a.UpdateCalendar(ctx, &storage.Calendar{ID: calendar.ID, Name: "Mine"})
+		requireCode(t, apperror.CodeForbidden, err)
+		require.Equal(t, codes.PermissionDenied, apperror.CodeOf(err).GRPCCode())
+
+		err = a.ShareCalendar(ctx, &storage.Share{CalendarID: calendar.ID, UserID: userID, Role: storage.RoleWrite})
+		requireCode(t, apperror.CodeForbidden, err)
+		requireCode(t, apperror.CodeForbidden, a.UnshareCalendar(ctx, calendar.ID, reader))
+		requireCode(t, apperror.CodeForbidden, a.DeleteCalendar(ctx, calendar.ID))
+
+		_, err = a.GetShares(ctx, calendar.ID)
+		requireCode(t, apperror.CodeForbidden, err)
+	}
+
+	a, calendar, _ := newSharedCalendar(t)
+	require.NoError(t, a.UpdateCalendar(as(owner), &storage.Calendar{ID: calendar.ID, Name: "Team A"}))
+	require.NoError(t, a.UnshareCalendar(as(owner), calendar.ID, reader))
+	require.NoError(t, a.DeleteCalendar(as(owner), calendar.ID))
+}
+
+func TestEventsChangedWithWriteRole(t *testing.T) {
+	for _, userID := range []int64{freeBusy, reader, stranger} {
+		a, calendar, event := newSharedCalendar(t)
+		ctx := as(userID)
+
+		requireCode(t, apperror.CodeForbidden, a.CreateEvent(ctx, newEvent(calendar.ID)))
+		requireCode(t, apperror.CodeForbidden, a.DeleteEvent(ctx, event.ID))
+
+		changed := *event
+		changed.Title = "Changed"
+		requireCode(t, apperror.CodeForbidden, a.UpdateEvent(ctx, event.ID, &changed))
+
+		// the event can not be moved out of the calendar to the one of the user
+		own, err := a.GetCalendars(ctx, 0)
+		require.NoError(t, err)
+		changed.CalendarID = own[0].ID
+		if own[0].ID == calendar.ID {
+			changed.CalendarID = own[1].ID
+		}
+		requireCode(t, apperror.CodeForbidden, a.UpdateEvent(ctx, event.ID, &changed))
+
+		// and the event of the user can not be moved into the calendar
+		mine := newEvent(uuid.Nil)
+		require.NoError(t, a.CreateEvent(ctx, mine))
+		mine.CalendarID = calendar.ID
+		requireCode(t, apperror.CodeForbidden, a.UpdateEvent(ctx, mine.ID, mine))
+	}
+
+	a, calendar, event := newSharedCalendar(t)
+	ctx := as(writer)
+
+	changed := *event
+	changed.Title = "Changed"
+	changed.UserID = 0
+	require.NoError(t, a.UpdateEvent(ctx, event.ID, &changed))
+	require.Equal(t, owner, changed.UserID, "the author is kept")
+	require.Equal(t, calendar.ID, changed.CalendarID)
+
+	require.NoError(t, a.CreateEvent(ctx, newEvent(calendar.ID)))
+	require.NoError(t, a.DeleteEvent(ctx, event.ID))
+}
+
+func TestEventVisibleByRole(t *testing.T) {
+	a, _, event := newSharedCalendar(t)
+
+	for _, userID := range []int64{owner, reader, writer} {
+		res, err := a.GetEvent(as(userID), event.ID)
+		require.NoError(t, err)
+		require.Equal(t, event.Title, res.Title)
+		require.Equal(t, event.Description, res.Description)
+	}
+
+	res, err := a.GetEvent(as(freeBusy), event.ID)
+	require.NoError(t, err)
+	require.Equal(t, BusyTitle, res.Title)
+	require.Empty(t, res.Description)
+
+	res, err = a.GetEventByDate(as(freeBusy), event.DateTime)
+	require.NoError(t, err)
+	require.Equal(t, BusyTitle, res.Title)
+
+	_, err = a.GetEvent(as(stranger), event.ID)
+	requireCode(t, apperror.CodeNotFound, err)
+	_, err = a.GetEventByDate(as(stranger), event.DateTime)
+	requireCode(t, apperror.CodeNotFound, err)
+
+	// the event of the stranger at the same time is found instead of the hidden one
+	own := newEvent(uuid.Nil)
+	own.DateTime = event.DateTime
+	require.NoError(t, a.CreateEvent(as(stranger), own))
+	res, err = a.GetEventByDate(as(stranger), event.DateTime)
+	require.NoError(t, err)
+	require.Equal(t, own.ID, res.ID)
+}
+
+func TestCurrentUser(t *testing.T) {
+	a := New(logger.New("ERROR", io.Discard), memorystorage.New())
+
+	_, err := a.GetEvents(context.Background(), storage.EventFilter{UserID: owner})
+	requireCode(t, apperror.CodeUnauthenticated, err)
+	requireCode(t, apperror.CodeUnauthenticated, a.CreateEvent(context.Background(), newEvent(uuid.Nil)))
+
+	event := newEvent(uuid.Nil)
+	event.UserID = stranger
+	requireCode(t, apperror.CodeForbidden, a.CreateEvent(as(owner), event))
+
+	_, err = a.GetEvents(as(owner), storage.EventFilter{UserID: stranger})
+	requireCode(t, apperror.CodeForbidden, err)
+
+	event.UserID = 0
+	require.NoError(t, a.CreateEvent(as(owner), event))
+	require.Equal(t, owner, event.UserID)
+}
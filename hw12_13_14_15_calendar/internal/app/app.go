@@ -2,15 +2,18 @@ package app
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/identity"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
 	"github.com/google/uuid"
 )
 
 type App struct {
 	logger  Logger
-	storage storage.EventStorage
+	storage storage.Storage
 }
 
 type Logger interface {
@@ -20,45 +23,221 @@ type Logger interface {
 	Error(msg string, a ...any)
 }
 
-func New(logger Logger, storage storage.EventStorage) *App {
+func New(logger Logger, storage storage.Storage) *App {
 	return &App{
 		logger:  logger,
 		storage: storage,
 	}
 }
 
+// ClientIdentity returns the client verified by mutual TLS, nil for anonymous clients.
+// Servers put it to the context of every request, so it may be used for authorization.
+func ClientIdentity(ctx context.Context) *identity.Identity {
+	return identity.FromContext(ctx)
+}
+
 func (a *App) CreateEvent(ctx context.Context, event *storage.Event) error {
-	return a.storage.CreateEvent(ctx, event)
+	if err := actAs(ctx, &event.UserID); err != nil {
+		return err
+	}
+
+	fillEndTime(event)
+	if err := validateEvent(event); err != nil {
+		return err
+	}
+	event.Tags = storage.SortTags(event.Tags)
+
+	if err := a.placeEvent(ctx, event); err != nil {
+		return err
+	}
+
+	if err := a.storage.CreateEvent(ctx, event); err != nil {
+		return storageError(err)
+	}
+
+	a.logger.Debug("event %s is created by %s", event.ID, ClientIdentity(ctx))
+	return nil
 }
 
+// UpdateEvent changes the event when the current user may change events of its calendar and of the calendar
+// it is moved to. The event without calendar stays in its calendar, the author of the event is kept.
 func (a *App) UpdateEvent(ctx context.Context, eventID uuid.UUID, event *storage.Event) error {
-	return a.storage.UpdateEvent(ctx, eventID, event)
+	userID, err := CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := a.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		return storageError(err)
+	}
+	if err := a.writeCalendar(ctx, current.CalendarID, userID); err != nil {
+		return err
+	}
+
+	fillEndTime(event)
+	if err := validateEvent(event); err != nil {
+		return err
+	}
+	event.Tags = storage.SortTags(event.Tags)
+	event.UserID = current.UserID
+
+	switch event.CalendarID {
+	case uuid.Nil:
+		event.CalendarID = current.CalendarID
+	case current.CalendarID:
+	default:
+		if err := a.writeCalendar(ctx, event.CalendarID, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := a.storage.UpdateEvent(ctx, eventID, event); err != nil {
+		return storageError(err)
+	}
+
+	a.logger.Debug("event %s is updated by %s", eventID, ClientIdentity(ctx))
+	return nil
 }
 
+// DeleteEvent deletes the event when the current user may change events of its calendar.
 func (a *App) DeleteEvent(ctx context.Context, eventID uuid.UUID) error {
-	return a.storage.DeleteEvent(ctx, eventID)
+	userID, err := CurrentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	event, err := a.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		return storageError(err)
+	}
+	if err := a.writeCalendar(ctx, event.CalendarID, userID); err != nil {
+		return err
+	}
+
+	if err := a.storage.DeleteEvent(ctx, eventID); err != nil {
+		return storageError(err)
+	}
+
+	a.logger.Debug("event %s is deleted by %s", eventID, ClientIdentity(ctx))
+	return nil
 }
 
-func (a *App) GetEvents(ctx context.Context) ([]*storage.Event, error) {
-	return a.storage.GetEvents(ctx)
+// GetEvents returns events of calendars owned by or shared with the current user.
+func (a *App) GetEvents(ctx context.Context, filter storage.EventFilter) ([]*storage.Event, error) {
+	if err := actAs(ctx, &filter.UserID); err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEvents(ctx, filter)
+	return a.visibleEvents(ctx, filter, events, err)
 }
 
 func (a *App) GetEvent(ctx context.Context, eventID uuid.UUID) (*storage.Event, error) {
-	return a.storage.GetEvent(ctx, eventID)
+	event, err := a.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return a.visibleEvent(ctx, event)
 }
 
+// GetEventByDate looks for the event only in calendars the current user can see,
+// so events of other users at the same time do not hide it.
 func (a *App) GetEventByDate(ctx context.Context, eventDatetime time.Time) (*storage.Event, error) {
-	return a.storage.GetEventByDate(ctx, eventDatetime)
+	var filter storage.EventFilter
+	if err := actAs(ctx, &filter.UserID); err != nil {
+		return nil, err
+	}
+
+	event, err := a.storage.GetEventByDate(ctx, eventDatetime, filter)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return a.visibleEvent(ctx, event)
 }
 
-func (a *App) GetEventsForDay(ctx context.Context, startOfDay time.Time) ([]*storage.Event, error) {
-	return a.storage.GetEventsForDay(ctx, startOfDay)
+func (a *App) GetEventsForDay(
+	ctx context.Context,
+	startOfDay time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	if err := actAs(ctx, &filter.UserID); err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsForDay(ctx, startOfDay, filter)
+	return a.visibleEvents(ctx, filter, events, err)
 }
 
-func (a *App) GetEventsForWeek(ctx context.Context, startOfWeek time.Time) ([]*storage.Event, error) {
-	return a.storage.GetEventsForWeek(ctx, startOfWeek)
+func (a *App) GetEventsForWeek(
+	ctx context.Context,
+	startOfWeek time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	if err := actAs(ctx, &filter.UserID); err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsForWeek(ctx, startOfWeek, filter)
+	return a.visibleEvents(ctx, filter, events, err)
 }
 
-func (a *App) GetEventsForMonth(ctx context.Context, startOfMonth time.Time) ([]*storage.Event, error) {
-	return a.storage.GetEventsForMonth(ctx, startOfMonth)
+func (a *App) GetEventsForMonth(
+	ctx context.Context,
+	startOfMonth time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	if err := actAs(ctx, &filter.UserID); err != nil {
+		return nil, err
+	}
+
+	events, err := a.storage.GetEventsForMonth(ctx, startOfMonth, filter)
+	return a.visibleEvents(ctx, filter, events, err)
+}
+
+// fillEndTime sets the end of the event without it: timed event takes no time, all-day event takes one day.
+func fillEndTime(event *storage.Event) {
+	if !event.EndTime.IsZero() {
+		return
+	}
+
+	event.EndTime = event.DateTime
+	if event.AllDay {
+		event.EndTime = event.DateTime.AddDate(0, 0, 1)
+	}
+}
+
+// visibleEvents applies roles of the user of the filter to the result of storage.
+func (a *App) visibleEvents(
+	ctx context.Context,
+	filter storage.EventFilter,
+	events []*storage.Event,
+	err error,
+) ([]*storage.Event, error) {
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	events, err = a.applyRoles(ctx, filter, events)
+	return events, storageError(err)
+}
+
+// translate storage errors to the domain ones.
+func storageError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrEventNotFound), errors.Is(err, storage.ErrPreferencesNotFound),
+		errors.Is(err, storage.ErrTagNotFound), errors.Is(err, storage.ErrCalendarNotFound),
+		errors.Is(err, storage.ErrShareNotFound):
+		return apperror.Wrap(apperror.CodeNotFound, err)
+	case errors.Is(err, storage.ErrEventAlreadyExists), errors.Is(err, storage.ErrEventDateTimeIsBusy):
+		return apperror.Wrap(apperror.CodeConflict, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return apperror.Wrap(apperror.CodeUnavailable, err)
+	default:
+		return err
+	}
 }
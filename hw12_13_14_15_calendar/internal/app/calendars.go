@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+// BusyTitle replaces title of events that are shared with free/busy role.
+const BusyTitle = "Busy"
+
+var (
+	ErrDefaultCalendar  = errors.New("default calendar can not be deleted")
+	ErrCalendarReadOnly = errors.New("user can not change events of the calendar")
+)
+
+// CreateCalendar creates the calendar owned by the current user.
+func (a *App) CreateCalendar(ctx context.Context, calendar *storage.Calendar) error {
+	if err := actAs(ctx, &calendar.OwnerID); err != nil {
+		return err
+	}
+
+	if err := validateCalendar(calendar); err != nil {
+		return err
+	}
+
+	if err := a.storage.CreateCalendar(ctx, calendar); err != nil {
+		return storageError(err)
+	}
+
+	a.logger.Debug("calendar %s is created by %s", calendar.ID, ClientIdentity(ctx))
+	return nil
+}
+
+// UpdateCalendar changes name, time zone and color, the owner and the default flag are kept.
+// Only the owner manages the calendar, as well as its shares.
+func (a *App) UpdateCalendar(ctx context.Context, calendar *storage.Calendar) error {
+	current, err := a.ownCalendar(ctx, calendar.ID)
+	if err != nil {
+		return err
+	}
+	calendar.OwnerID = current.OwnerID
+	calendar.Default = current.Default
+
+	if err := validateCalendar(calendar); err != nil {
+		return err
+	}
+
+	return storageError(a.storage.UpdateCalendar(ctx, calendar))
+}
+
+// DeleteCalendar deletes the calendar with its events, the default calendar is kept.
+func (a *App) DeleteCalendar(ctx context.Context, calendarID uuid.UUID) error {
+	calendar, err := a.ownCalendar(ctx, calendarID)
+	if err != nil {
+		return err
+	}
+	if calendar.Default {
+		return apperror.Wrap(apperror.CodeConflict, ErrDefaultCalendar)
+	}
+
+	if err := a.storage.DeleteCalendar(ctx, calendarID); err != nil {
+		return storageError(err)
+	}
+
+	a.logger.Debug("calendar %s is deleted by %s", calendarID, ClientIdentity(ctx))
+	return nil
+}
+
+// GetCalendar returns the calendar owned by or shared with the current user.
+func (a *App) GetCalendar(ctx context.Context, calendarID uuid.UUID) (*storage.Calendar, error) {
+	userID, err := CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := a.storage.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	role, err := a.calendarRole(ctx, calendar, userID)
+	switch {
+	case err != nil:
+		return nil, err
+	case role == "":
+		return nil, storageError(storage.ErrCalendarNotFound)
+	}
+
+	return calendar, nil
+}
+
+// GetCalendars returns calendars owned by or shared with the user, the default one is always there.
+func (a *App) GetCalendars(ctx context.Context, userID int64) ([]*storage.Calendar, error) {
+	if err := actAs(ctx, &userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := a.storage.DefaultCalendar(ctx, userID); err != nil {
+		return nil, storageError(err)
+	}
+
+	calendars, err := a.storage.GetCalendars(ctx, userID)
+	return calendars, storageError(err)
+}
+
+func (a *App) ShareCalendar(ctx context.Context, share *storage.Share) error {
+	if err := validateShare(share); err != nil {
+		return err
+	}
+
+	calendar, err := a.ownCalendar(ctx, share.CalendarID)
+	if err != nil {
+		return err
+	}
+	if calendar.OwnerID == share.UserID {
+		return apperror.Validation([]apperror.FieldViolation{
+			{Field: "user_id", Description: "calendar can not be shared with its owner"},
+		})
+	}
+
+	return storageError(a.storage.ShareCalendar(ctx, share))
+}
+
+func (a *App) UnshareCalendar(ctx context.Context, calendarID uuid.UUID, userID int64) error {
+	if _, err := a.ownCalendar(ctx, calendarID); err != nil {
+		return err
+	}
+
+	return storageError(a.storage.UnshareCalendar(ctx, calendarID, userID))
+}
+
+func (a *App) GetShares(ctx context.Context, calendarID uuid.UUID) ([]*storage.Share, error) {
+	if _, err := a.ownCalendar(ctx, calendarID); err != nil {
+		return nil, err
+	}
+
+	shares, err := a.storage.GetShares(ctx, calendarID)
+	return shares, storageError(err)
+}
+
+// placeEvent puts the event without calendar to the default calendar of its user, otherwise
+// the user should own the calendar or have write access to it.
+func (a *App) placeEvent(ctx context.Context, event *storage.Event) error {
+	if event.CalendarID != uuid.Nil {
+		return a.writeCalendar(ctx, event.CalendarID, event.UserID)
+	}
+
+	calendar, err := a.storage.DefaultCalendar(ctx, event.UserID)
+	if err != nil {
+		return storageError(err)
+	}
+
+	event.CalendarID = calendar.ID
+	return nil
+}
+
+// applyRoles hides details of events from calendars that are shared with the user of the filter
+// with free/busy role. Such events do not match tags, as their tags are hidden too.
+func (a *App) applyRoles(
+	ctx context.Context,
+	filter storage.EventFilter,
+	events []*storage.Event,
+) ([]*storage.Event, error) {
+	if filter.UserID == 0 || len(events) == 0 {
+		return events, nil
+	}
+
+	shares, err := a.storage.GetUserShares(ctx, filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	freeBusy := make(map[uuid.UUID]bool)
+	for _, share := range shares {
+		if share.Role == storage.RoleFreeBusy {
+			freeBusy[share.CalendarID] = true
+		}
+	}
+	if len(freeBusy) == 0 {
+		return events, nil
+	}
+
+	res := make([]*storage.Event, 0, len(events))
+	for _, event := range events {
+		switch {
+		case !freeBusy[event.CalendarID]:
+			res = append(res, event)
+		case filter.Tag == "":
+			res = append(res, busyEvent(event))
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"context"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (a *App) GetPreferences(ctx context.Context, userID int64) (*storage.Preferences, error) {
+	if err := actAs(ctx, &userID); err != nil {
+		return nil, err
+	}
+
+	preferences, err := a.storage.GetPreferences(ctx, userID)
+	return preferences, storageError(err)
+}
+
+func (a *App) SavePreferences(ctx context.Context, preferences *storage.Preferences) error {
+	if err := actAs(ctx, &preferences.UserID); err != nil {
+		return err
+	}
+
+	if err := validatePreferences(preferences); err != nil {
+		return err
+	}
+
+	return storageError(a.storage.SavePreferences(ctx, preferences))
+}
+
+func (a *App) DeletePreferences(ctx context.Context, userID int64) error {
+	if err := actAs(ctx, &userID); err != nil {
+		return err
+	}
+
+	return storageError(a.storage.DeletePreferences(ctx, userID))
+}
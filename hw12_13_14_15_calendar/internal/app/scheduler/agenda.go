@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+	"github.com/google/uuid"
+)
+
+var ErrGetEventsForAgenda = errors.New("cannot get events for agenda")
+
+// SendAgendas returns job that publishes the agenda of the current day in the location to every user
+// who has events. Every user gets the agenda of a day only once, even after restart.
+func (s *Scheduler) SendAgendas(location *time.Location) jobs.Func {
+	return func(ctx context.Context) error {
+		day := startOfDay(time.Now().In(location))
+
+		count, err := s.putAgendasToQueue(ctx, day)
+		s.logger.Info("sent %d agendas for %s", count, day.Format(storage.DayLayout))
+
+		return err
+	}
+}
+
+// AgendaMissed reports whether the agenda of the current day in the location was due before now,
+// so it might be missed while scheduler was stopped.
+func AgendaMissed(schedule jobs.Schedule, location *time.Location, now time.Time) bool {
+	first := schedule.Next(startOfDay(now.In(location)).Add(-time.Minute))
+	return !first.IsZero() && !first.After(now)
+}
+
+func (s *Scheduler) putAgendasToQueue(ctx context.Context, day time.Time) (int, error) {
+	events, err := s.storage.GetEventsForDay(ctx, day, storage.EventFilter{})
+	if err != nil {
+		return 0, errors.Join(err, ErrGetEventsForAgenda)
+	}
+
+	agendas, err := s.agendas(ctx, day, events)
+	if err != nil {
+		return 0, errors.Join(err, ErrGetEventsForAgenda)
+	}
+
+	count := 0
+	for _, agenda := range agendas {
+		data, err := json.Marshal(agenda)
+		if err != nil {
+			return count, errors.Join(err, ErrSerializeNotification)
+		}
+
+		// agenda is claimed before publishing, so several schedulers do not send it twice.
+		// The claim is released when broker has not confirmed the agenda, the next run sends it again.
+		claimed, err := s.storage.ClaimAgenda(ctx, agenda.UserID, day)
+		if err != nil {
+			return count, err
+		}
+		if !claimed {
+			continue
+		}
+
+		err = s.publisher.Publish(ctx, broker.Message{ContentType: storage.AgendaContentType, Body: data})
+		if errors.Is(err, broker.ErrUnroutable) {
+			s.logger.Warning("there is no queue for agendas, agenda of user %d is not sent", agenda.UserID)
+		}
+		if err != nil {
+			return count, errors.Join(err, ErrSendNotificationToQueue, s.storage.ReleaseAgenda(ctx, agenda.UserID, day))
+		}
+
+		count++
+		s.logger.Debug("successfully put agenda to queue: %s", data)
+	}
+
+	return count, nil
+}
+
+// agendas groups events by users who see them: owners of calendars and users they are shared with
+// for reading or writing, events without calendar are seen by their users only.
+// Events are ordered by date, so are agendas.
+func (s *Scheduler) agendas(ctx context.Context, day time.Time, events []*storage.Event) ([]*storage.Agenda, error) {
+	var agendas []*storage.Agenda
+	byUser := make(map[int64]*storage.Agenda)
+	readers := make(map[uuid.UUID][]int64)
+	for _, event := range events {
+		users, found := readers[event.CalendarID]
+		switch {
+		case event.CalendarID == uuid.Nil:
+			users = []int64{event.UserID}
+		case !found:
+			var err error
+			if users, err = s.calendarReaders(ctx, event.CalendarID); err != nil {
+				return nil, err
+			}
+			readers[event.CalendarID] = users
+		}
+
+		for _, userID := range users {
+			agenda, found := byUser[userID]
+			if !found {
+				agenda = &storage.Agenda{UserID: userID, Day: day}
+				byUser[userID] = agenda
+				agendas = append(agendas, agenda)
+			}
+			agenda.Events = append(agenda.Events, *s.getNotificationForEvent(event))
+		}
+	}
+
+	return agendas, nil
+}
+
+// calendarReaders returns users who see details of events in the calendar.
+func (s *Scheduler) calendarReaders(ctx context.Context, calendarID uuid.UUID) ([]int64, error) {
+	calendar, err := s.storage.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := s.storage.GetShares(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []int64{calendar.OwnerID}
+	for _, share := range shares {
+		if share.Role == storage.RoleRead || share.Role == storage.RoleWrite {
+			users = append(users, share.UserID)
+		}
+	}
+
+	return users, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	memorybroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/memory"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/cron"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutAgendasToQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := memorystorage.New()
+	queue := memorybroker.New(10)
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, event := range []*storage.Event{
+		{Title: "Review", DateTime: day.Add(15 * time.Hour), UserID: 1},
+		{Title: "Standup", DateTime: day.Add(10 * time.Hour), UserID: 1},
+		{Title: "Lunch", DateTime: day.Add(13 * time.Hour), UserID: 2},
+		{Title: "Tomorrow", DateTime: day.Add(34 * time.Hour), UserID: 3},
+	} {
+		require.NoError(t, st.CreateEvent(ctx, event))
+	}
+
+	s := New(logger.New("ERROR", io.Discard), st, queue, time.Minute, time.Hour, ClaimConfig{})
+
+	count, err := s.putAgendasToQueue(ctx, day)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	received := make(chan broker.Message, 3)
+	go queue.Consume(ctx, func(_ context.Context, msg broker.Message) error {
+		received <- msg
+		return nil
+	}, 1)
+
+	agendas := make(map[int64][]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			require.Equal(t, storage.AgendaContentType, msg.ContentType)
+
+			var agenda storage.Agenda
+			require.NoError(t, json.Unmarshal(msg.Body, &agenda))
+			require.True(t, day.Equal(agenda.Day))
+			for _, event := range agenda.Events {
+				agendas[agenda.UserID] = append(agendas[agenda.UserID], event.Title)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("agenda is not published")
+		}
+	}
+	require.Equal(t, map[int64][]string{1: {"Standup", "Review"}, 2: {"Lunch"}}, agendas)
+
+	// restarted scheduler does not send the same agendas again
+	s = New(logger.New("ERROR", io.Discard), st, queue, time.Minute, time.Hour, ClaimConfig{})
+	count, err = s.putAgendasToQueue(ctx, day)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected agenda: %s", msg.Body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAgendasOfSharedCalendars(t *testing.T) {
+	ctx := context.Background()
+	st := memorystorage.New()
+	queue := memorybroker.New(10)
+
+	calendar := &storage.Calendar{OwnerID: 1, Name: "Team"}
+	require.NoError(t, st.CreateCalendar(ctx, calendar))
+	for userID, role := range map[int64]storage.Role{2: storage.RoleRead, 3: storage.RoleFreeBusy} {
+		require.NoError(t, st.ShareCalendar(ctx, &storage.Share{CalendarID: calendar.ID, UserID: userID, Role: role}))
+	}
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	event := &storage.Event{CalendarID: calendar.ID, Title: "Planning", DateTime: day.Add(10 * time.Hour), UserID: 1}
+	require.NoError(t, st.CreateEvent(ctx, event))
+
+	// schedulers that run together send every agenda once
+	var wg sync.WaitGroup
+	counts := make([]int, 3)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := New(logger.New("ERROR", io.Discard), st, queue, time.Minute, time.Hour, ClaimConfig{})
+			count, err := s.putAgendasToQueue(ctx, day)
+			assert.NoError(t, err)
+			counts[i] = count
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 2, counts[0]+counts[1]+counts[2])
+
+	received := make(chan broker.Message, 3)
+	consumeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go queue.Consume(consumeCtx, func(_ context.Context, msg broker.Message) error {
+		received <- msg
+		return nil
+	}, 1)
+
+	// the owner and the reader get the event, free/busy role does not show it
+	var users []int64
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			var agenda storage.Agenda
+			require.NoError(t, json.Unmarshal(msg.Body, &agenda))
+			require.Len(t, agenda.Events, 1)
+			require.Equal(t, "Planning", agenda.Events[0].Title)
+			users = append(users, agenda.UserID)
+		case <-time.After(time.Second):
+			t.Fatal("agenda is not published")
+		}
+	}
+	require.ElementsMatch(t, []int64{1, 2}, users)
+}
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(context.Context, broker.Message) error {
+	return broker.ErrUnroutable
+}
+
+func TestAgendaReleasedWhenNotPublished(t *testing.T) {
+	ctx := context.Background()
+	st := memorystorage.New()
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, st.CreateEvent(ctx, &storage.Event{Title: "Standup", DateTime: day.Add(time.Hour), UserID: 1}))
+
+	s := New(logger.New("ERROR", io.Discard), st, failingPublisher{}, time.Minute, time.Hour, ClaimConfig{})
+	_, err := s.putAgendasToQueue(ctx, day)
+	require.ErrorIs(t, err, broker.ErrUnroutable)
+
+	// the next run sends the agenda
+	s = New(logger.New("ERROR", io.Discard), st, memorybroker.New(10), time.Minute, time.Hour, ClaimConfig{})
+	count, err := s.putAgendasToQueue(ctx, day)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestAgendaMissed(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	spec, err := cron.Parse("0 8 * * *")
+	require.NoError(t, err)
+	schedule := jobs.In(spec, moscow)
+
+	// 07:00 and 09:00 in Moscow
+	require.False(t, AgendaMissed(schedule, moscow, time.Date(2024, 1, 10, 4, 0, 0, 0, time.UTC)))
+	require.True(t, AgendaMissed(schedule, moscow, time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC)))
+	require.False(t, AgendaMissed(jobs.Never{}, moscow, time.Date(2024, 1, 10, 6, 0, 0, 0, time.UTC)))
+}
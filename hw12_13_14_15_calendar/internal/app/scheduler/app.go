@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
-	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/rmq"
-	"github.com/streadway/amqp"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	"github.com/google/uuid"
 )
 
 var (
@@ -19,10 +20,21 @@ var (
 
 type Scheduler struct {
 	logger                 Logger
-	storage                storage.EventStorage
-	rmq                    *rmq.Rmq
-	runFrequencyInterval   time.Duration
-	timeForRemoveOldEvents time.Duration
+	storage                storage.Storage
+	publisher              broker.Publisher
+	runFrequencyInterval   atomic.Int64
+	timeForRemoveOldEvents atomic.Int64
+	claim                  ClaimConfig
+	// reconfigured wakes WatchReminders when runFrequencyInterval is changed.
+	reconfigured chan struct{}
+}
+
+// ClaimConfig allows to run several scheduler instances against one storage.
+// Due events are claimed by the instance for Lease, so other instances skip them.
+type ClaimConfig struct {
+	InstanceID string
+	Lease      time.Duration
+	BatchSize  int
 }
 
 type Logger interface {
@@ -34,53 +46,187 @@ type Logger interface {
 
 func New(
 	logger Logger,
-	storage storage.EventStorage,
-	rmq *rmq.Rmq,
+	storage storage.Storage,
+	publisher broker.Publisher,
 	runFrequencyInterval time.Duration,
 	timeForRemoveOldEvents time.Duration,
+	claim ClaimConfig,
 ) *Scheduler {
-	return &Scheduler{
-		logger:                 logger,
-		storage:                storage,
-		rmq:                    rmq,
-		runFrequencyInterval:   runFrequencyInterval,
-		timeForRemoveOldEvents: timeForRemoveOldEvents,
-	}
-}
-
-func (s *Scheduler) NotificationSender(ctx context.Context) {
-	ticker := time.NewTicker(s.runFrequencyInterval)
-	s.logger.Info("successfully init timer")
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				// put to queue
-				err := s.putNotificationsToQueue(ctx)
-				if err != nil {
-					s.logger.Error("put to queue error: %w", err)
-				}
-
-				// delete old events
-				err = s.deleteOldEvents(ctx)
-				if err != nil {
-					s.logger.Error("delete old events error: %w", err)
-				}
-			case <-ctx.Done():
-				ticker.Stop()
-				s.logger.Info("successfully stop timer")
-				return
+	s := &Scheduler{
+		logger:       logger,
+		storage:      storage,
+		publisher:    publisher,
+		claim:        claim,
+		reconfigured: make(chan struct{}, 1),
+	}
+	s.SetIntervals(runFrequencyInterval, timeForRemoveOldEvents)
+
+	return s
+}
+
+// SetIntervals changes intervals of the working scheduler, e.g. on reload of config.
+func (s *Scheduler) SetIntervals(runFrequencyInterval, timeForRemoveOldEvents time.Duration) {
+	s.timeForRemoveOldEvents.Store(int64(timeForRemoveOldEvents))
+
+	if s.runFrequencyInterval.Swap(int64(runFrequencyInterval)) != int64(runFrequencyInterval) {
+		select {
+		case s.reconfigured <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Scheduler) frequency() time.Duration {
+	return time.Duration(s.runFrequencyInterval.Load())
+}
+
+// WatchReminders calls due at the time of upcoming notifications, so they are fired exactly in time.
+// Reminders are kept in memory and refreshed on event changes when storage is able to report them,
+// otherwise and additionally they are reloaded every runFrequencyInterval. Blocks until ctx is done.
+func (s *Scheduler) WatchReminders(ctx context.Context, due func()) {
+	changes := s.watchEvents(ctx)
+
+	reload := time.NewTicker(s.frequency())
+	defer reload.Stop()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var queue reminders
+	s.reloadReminders(ctx, &queue)
+	s.logger.Info("successfully init reminders")
+
+	for {
+		select {
+		case <-timer.C:
+			if queue.popDue(time.Now()) > 0 {
+				due()
+			}
+		case eventID, ok := <-changes:
+			if !ok {
+				s.logger.Warning("watching of event changes stopped, continue with polling only")
+				changes = nil
+				continue
 			}
+
+			if eventID == uuid.Nil {
+				s.reloadReminders(ctx, &queue)
+			} else {
+				s.addReminder(ctx, &queue, eventID)
+			}
+		case <-reload.C:
+			s.reloadReminders(ctx, &queue)
+		case <-s.reconfigured:
+			// horizon is changed as well
+			reload.Reset(s.frequency())
+			s.reloadReminders(ctx, &queue)
+		case <-ctx.Done():
+			s.logger.Info("successfully stop reminders")
+			return
 		}
-	}()
+
+		s.resetTimer(timer, &queue)
+	}
 }
 
-func (s *Scheduler) putNotificationsToQueue(ctx context.Context) error {
-	events, err := s.getEventsForNotifications(ctx)
+// DispatchNotifications puts all due notifications to the queue.
+func (s *Scheduler) DispatchNotifications(ctx context.Context) error {
+	return s.putNotificationsToQueue(ctx)
+}
+
+// CleanupOldEvents removes events older than timeForRemoveOldEvents.
+func (s *Scheduler) CleanupOldEvents(ctx context.Context) error {
+	return s.deleteOldEvents(ctx)
+}
+
+// watchEvents returns nil channel when storage cannot report changes, so it is never selected.
+func (s *Scheduler) watchEvents(ctx context.Context) <-chan uuid.UUID {
+	watcher, ok := s.storage.(storage.EventWatcher)
+	if !ok {
+		return nil
+	}
+
+	changes, err := watcher.WatchEvents(ctx)
+	if err != nil {
+		s.logger.Warning("cannot watch event changes, continue with polling only: %s", err)
+		return nil
+	}
+
+	return changes
+}
+
+// reminders are loaded up to the second reload from now, later ones will be loaded by next reloads.
+func (s *Scheduler) horizon() time.Time {
+	return time.Now().Add(2 * s.frequency())
+}
+
+func (s *Scheduler) reloadReminders(ctx context.Context, queue *reminders) {
+	events, err := s.storage.GetUpcomingNotifications(ctx, s.horizon())
 	if err != nil {
-		return errors.Join(err, ErrGetEventsForNotification)
+		s.logger.Error("load upcoming notifications error: %s", err)
+		return
 	}
 
+	items := make([]reminder, 0, len(events))
+	for _, event := range events {
+		items = append(items, reminder{eventID: event.ID, at: event.TimeNotification})
+	}
+	queue.reset(items)
+
+	s.logger.Debug("loaded %d upcoming reminders", queue.len())
+}
+
+func (s *Scheduler) addReminder(ctx context.Context, queue *reminders, eventID uuid.UUID) {
+	event, err := s.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		// deleted event: its reminder will just find nothing to send.
+		return
+	}
+
+	if event.TimeNotification.IsZero() || !event.NotifyAt.IsZero() || event.TimeNotification.After(s.horizon()) {
+		return
+	}
+
+	queue.push(reminder{eventID: event.ID, at: event.TimeNotification})
+}
+
+func (s *Scheduler) resetTimer(timer *time.Timer, queue *reminders) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	if next, ok := queue.next(); ok {
+		timer.Reset(time.Until(next))
+	}
+}
+
+// putNotificationsToQueue sends all due notifications. Claim returns at most BatchSize events,
+// so claims are repeated until a claim is not full or ctx is done.
+func (s *Scheduler) putNotificationsToQueue(ctx context.Context) error {
+	_, claims := s.storage.(storage.NotificationClaimer)
+
+	for ctx.Err() == nil {
+		events, err := s.getEventsForNotifications(ctx)
+		if err != nil {
+			return errors.Join(err, ErrGetEventsForNotification)
+		}
+
+		if err := s.sendNotifications(ctx, events); err != nil {
+			return err
+		}
+
+		if !claims || len(events) < s.claim.BatchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *Scheduler) sendNotifications(ctx context.Context, events []*storage.Event) error {
 	for _, event := range events {
 		notification := s.getNotificationForEvent(event)
 
@@ -89,7 +235,12 @@ func (s *Scheduler) putNotificationsToQueue(ctx context.Context) error {
 			return errors.Join(err, ErrSerializeNotification)
 		}
 
-		err = s.sendToQueue(data)
+		// event is marked as notified only after broker has confirmed the message. Otherwise it stays
+		// pending and is sent again later, so notification may be duplicated but is never lost.
+		err = s.sendToQueue(ctx, data)
+		if errors.Is(err, broker.ErrUnroutable) {
+			s.logger.Warning("there is no queue for notifications, event %s stays pending", event.ID)
+		}
 		if err != nil {
 			return errors.Join(err, ErrSendNotificationToQueue)
 		}
@@ -106,16 +257,23 @@ func (s *Scheduler) putNotificationsToQueue(ctx context.Context) error {
 	return nil
 }
 
-func (s *Scheduler) sendToQueue(data []byte) error {
-	msg := amqp.Publishing{
+func (s *Scheduler) sendToQueue(ctx context.Context, data []byte) error {
+	msg := broker.Message{
 		ContentType: "application/json",
 		Body:        data,
 	}
-	return s.rmq.Publish(msg)
+	return s.publisher.Publish(ctx, msg)
 }
 
+// getEventsForNotifications claims due events when storage supports it. Otherwise only one
+// scheduler instance may be running, because every instance would get the same events.
 func (s *Scheduler) getEventsForNotifications(ctx context.Context) ([]*storage.Event, error) {
-	return s.storage.GetEventsForNotifications(ctx)
+	claimer, ok := s.storage.(storage.NotificationClaimer)
+	if !ok {
+		return s.storage.GetEventsForNotifications(ctx)
+	}
+
+	return claimer.ClaimEventsForNotifications(ctx, s.claim.InstanceID, s.claim.Lease, s.claim.BatchSize)
 }
 
 func (s *Scheduler) setNotifyTimeForEvent(ctx context.Context, event *storage.Event) error {
@@ -123,7 +281,7 @@ func (s *Scheduler) setNotifyTimeForEvent(ctx context.Context, event *storage.Ev
 }
 
 func (s *Scheduler) deleteOldEvents(ctx context.Context) error {
-	count, err := s.storage.DeleteOldEvents(ctx, s.timeForRemoveOldEvents)
+	count, err := s.storage.DeleteOldEvents(ctx, time.Duration(s.timeForRemoveOldEvents.Load()))
 	if err != nil {
 		return err
 	}
@@ -140,5 +298,6 @@ func (s *Scheduler) getNotificationForEvent(event *storage.Event) *storage.Notif
 		Title:    event.Title,
 		DateTime: event.DateTime,
 		UserID:   event.UserID,
+		AllDay:   event.AllDay,
 	}
 }
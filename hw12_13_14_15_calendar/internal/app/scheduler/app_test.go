@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	memorybroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutNotificationsToQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := memorystorage.New()
+	queue := memorybroker.New(10)
+
+	now := time.Now()
+	due := &storage.Event{
+		Title:            "Due",
+		DateTime:         now.Add(time.Hour),
+		TimeNotification: now.Add(-time.Minute),
+		UserID:           1,
+	}
+	future := &storage.Event{
+		Title:            "Future",
+		DateTime:         now.Add(2 * time.Hour),
+		TimeNotification: now.Add(time.Hour),
+		UserID:           1,
+	}
+	require.NoError(t, st.CreateEvent(ctx, due))
+	require.NoError(t, st.CreateEvent(ctx, future))
+
+	s := New(logger.New("ERROR", io.Discard), st, queue, time.Minute, time.Hour, ClaimConfig{
+		InstanceID: "test",
+		Lease:      time.Minute,
+		BatchSize:  10,
+	})
+	require.NoError(t, s.putNotificationsToQueue(ctx))
+
+	received := make(chan broker.Message, 2)
+	go queue.Consume(ctx, func(_ context.Context, msg broker.Message) error {
+		received <- msg
+		return nil
+	}, 1)
+
+	select {
+	case msg := <-received:
+		var notification storage.Notification
+		require.NoError(t, json.Unmarshal(msg.Body, &notification))
+		require.Equal(t, due.ID.String(), notification.EventID)
+		require.Equal(t, "Due", notification.Title)
+	case <-time.After(time.Second):
+		t.Fatal("notification is not published")
+	}
+
+	stored, err := st.GetEvent(ctx, due.ID)
+	require.NoError(t, err)
+	require.False(t, stored.NotifyAt.IsZero())
+
+	// already notified event is not sent again
+	require.NoError(t, s.putNotificationsToQueue(ctx))
+	select {
+	case msg := <-received:
+		t.Fatalf("unexpected notification: %s", msg.Body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPutNotificationsToQueueInBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := memorystorage.New()
+	queue := memorybroker.New(10)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, st.CreateEvent(ctx, &storage.Event{
+			Title:            "Due",
+			DateTime:         now.Add(time.Duration(i+1) * time.Hour),
+			TimeNotification: now.Add(-time.Minute),
+			UserID:           1,
+		}))
+	}
+
+	s := New(logger.New("ERROR", io.Discard), st, queue, time.Minute, time.Hour, ClaimConfig{
+		InstanceID: "test",
+		Lease:      time.Minute,
+		BatchSize:  2,
+	})
+	require.NoError(t, s.putNotificationsToQueue(ctx))
+
+	// all due events are sent in one run, not only the first batch
+	events, err := st.GetEventsForNotifications(ctx)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	received := make(chan broker.Message, 5)
+	go queue.Consume(ctx, func(_ context.Context, msg broker.Message) error {
+		received <- msg
+		return nil
+	}, 1)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("only %d notifications are published", i)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+)
+
+const (
+	NotificationsJob = "notifications"
+	CleanupJob       = "cleanup"
+	AgendaJob        = "agenda"
+)
+
+// Jobs builds jobs of the scheduler from config. It is used on start and on reload of config,
+// so jobs are always built in the same way.
+func (s *Scheduler) Jobs(conf config.JobsConf) ([]jobs.Job, error) {
+	notifications, _, err := conf.Notifications.Job(NotificationsJob, s.DispatchNotifications)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup, _, err := conf.Cleanup.Job(CleanupJob, s.CleanupOldEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	// agenda without schedule is never run, it may be enabled by reload of config.
+	agenda, location, err := conf.Agenda.Job(AgendaJob, nil)
+	if err != nil {
+		return nil, err
+	}
+	agenda.Run = s.SendAgendas(location)
+
+	return []jobs.Job{notifications, cleanup, agenda}, nil
+}
+
+// AddJobs adds jobs to the runner on start.
+func (s *Scheduler) AddJobs(runner *jobs.Runner, conf config.JobsConf) error {
+	list, err := s.Jobs(conf)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range list {
+		if job.Name == AgendaJob {
+			// time zone is checked by Jobs.
+			location, _ := time.LoadLocation(conf.Agenda.Timezone)
+			// after restart agendas of today are sent if their time has passed, the journal prevents duplicates
+			job.RunOnStart = AgendaMissed(job.Schedule, location, time.Now())
+		}
+		if err := runner.Add(job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
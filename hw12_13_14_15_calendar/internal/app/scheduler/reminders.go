@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type reminder struct {
+	eventID uuid.UUID
+	at      time.Time
+}
+
+// reminderHeap implements heap.Interface, the earliest reminder is on top.
+type reminderHeap []reminder
+
+func (h reminderHeap) Len() int           { return len(h) }
+func (h reminderHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h reminderHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *reminderHeap) Push(x any) {
+	*h = append(*h, x.(reminder))
+}
+
+func (h *reminderHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// reminders keeps upcoming notification times. It is used only to wake up scheduler at the right moment:
+// stale items (of deleted or moved events) just cause an extra check of storage.
+type reminders struct {
+	items reminderHeap
+}
+
+func (r *reminders) reset(items []reminder) {
+	r.items = append(reminderHeap(nil), items...)
+	heap.Init(&r.items)
+}
+
+func (r *reminders) push(item reminder) {
+	heap.Push(&r.items, item)
+}
+
+// next returns time of the earliest reminder.
+func (r *reminders) next() (time.Time, bool) {
+	if len(r.items) == 0 {
+		return time.Time{}, false
+	}
+
+	return r.items[0].at, true
+}
+
+// popDue removes all reminders that are due at now and returns their count.
+func (r *reminders) popDue(now time.Time) int {
+	count := 0
+	for len(r.items) > 0 && !r.items[0].at.After(now) {
+		heap.Pop(&r.items)
+		count++
+	}
+
+	return count
+}
+
+func (r *reminders) len() int {
+	return len(r.items)
+}
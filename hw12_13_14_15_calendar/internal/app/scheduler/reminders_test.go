@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminders(t *testing.T) {
+	now := time.Now()
+
+	var r reminders
+	_, ok := r.next()
+	require.False(t, ok)
+
+	r.reset([]reminder{
+		{eventID: uuid.New(), at: now.Add(time.Minute)},
+		{eventID: uuid.New(), at: now.Add(-time.Minute)},
+	})
+	r.push(reminder{eventID: uuid.New(), at: now})
+	r.push(reminder{eventID: uuid.New(), at: now.Add(time.Hour)})
+
+	next, ok := r.next()
+	require.True(t, ok)
+	require.Equal(t, now.Add(-time.Minute), next)
+
+	require.Equal(t, 2, r.popDue(now))
+	require.Equal(t, 2, r.len())
+
+	next, _ = r.next()
+	require.Equal(t, now.Add(time.Minute), next)
+
+	require.Equal(t, 0, r.popDue(now))
+	require.Equal(t, 2, r.popDue(now.Add(time.Hour)))
+
+	_, ok = r.next()
+	require.False(t, ok)
+}
@@ -2,15 +2,31 @@ package sender
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
-	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/rmq"
-	"github.com/streadway/amqp"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender/templates"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
 )
 
 type Sender struct {
-	logger  Logger
-	rmq     *rmq.Rmq
-	threads int
+	logger   Logger
+	consumer broker.Consumer
+	renderer Renderer
+	storage  Storage
+
+	// settings below are changed on reload of config.
+	mu       sync.Mutex
+	threads  int
+	defaults Defaults
+	// resized restarts consuming with the new number of threads.
+	resized chan struct{}
+	// intervals passes the new flush interval to RunOutbox.
+	intervals chan time.Duration
 }
 
 type Logger interface {
@@ -20,30 +36,327 @@ type Logger interface {
 	Error(msg string, a ...any)
 }
 
+type Renderer interface {
+	Render(
+		kind string,
+		channel string,
+		locale string,
+		location *time.Location,
+		now time.Time,
+		events ...templates.Data,
+	) (*templates.Message, error)
+}
+
+// Storage keeps preferences of users and notifications deferred by quiet hours and digests.
+type Storage interface {
+	GetPreferences(ctx context.Context, userID int64) (*storage.Preferences, error)
+	AddPendingNotification(ctx context.Context, pending *storage.PendingNotification) error
+	TakePendingNotifications(ctx context.Context, till time.Time) ([]*storage.PendingNotification, error)
+}
+
+// Defaults describe how to deliver notifications to users without preferences.
+type Defaults struct {
+	Channel  string
+	Locale   string
+	Location *time.Location
+}
+
+// recipient is effective preferences of the user.
+type recipient struct {
+	preferences *storage.Preferences
+	location    *time.Location
+}
+
 func New(
 	logger Logger,
-	rmq *rmq.Rmq,
+	consumer broker.Consumer,
 	threads int,
+	renderer Renderer,
+	storage Storage,
+	defaults Defaults,
 ) *Sender {
 	return &Sender{
-		logger:  logger,
-		rmq:     rmq,
-		threads: threads,
+		logger:    logger,
+		consumer:  consumer,
+		threads:   threads,
+		renderer:  renderer,
+		storage:   storage,
+		defaults:  defaults,
+		resized:   make(chan struct{}, 1),
+		intervals: make(chan time.Duration, 1),
+	}
+}
+
+// SetThreads changes the number of workers. Workers finish their current messages
+// and consuming is started again with the new number.
+func (s *Sender) SetThreads(threads int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.threads = threads
+	select {
+	case s.resized <- struct{}{}:
+	default:
+	}
+}
+
+// SetFlushInterval changes interval of checking deferred notifications by RunOutbox.
+func (s *Sender) SetFlushInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// only the latest interval matters.
+	select {
+	case <-s.intervals:
+	default:
 	}
+	s.intervals <- interval
+}
+
+// SetDefaults changes defaults for users without preferences.
+func (s *Sender) SetDefaults(defaults Defaults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaults = defaults
 }
 
+// Consume blocks until ctx is done and all workers are stopped.
 func (s *Sender) Consume(ctx context.Context) error {
-	return s.rmq.Handle(ctx, s.worker, s.threads)
+	// messages are handled with ctx, so restart of consuming does not interrupt them.
+	handler := func(_ context.Context, msg broker.Message) error {
+		return s.handle(ctx, msg)
+	}
+
+	for restarted := false; ; restarted = true {
+		s.mu.Lock()
+		threads := s.threads
+		s.mu.Unlock()
+
+		if restarted {
+			s.logger.Info("restart consuming with %d workers", threads)
+		}
+
+		consumeCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- s.consumer.Consume(consumeCtx, handler, threads)
+		}()
+
+		select {
+		case err := <-done:
+			cancel()
+			return err
+		case <-s.resized:
+			cancel()
+			if err := <-done; err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+	}
 }
 
-func (s *Sender) worker(ctx context.Context, ch <-chan amqp.Delivery) {
+// RunOutbox delivers deferred notifications when their time comes, until ctx is done.
+func (s *Sender) RunOutbox(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case msg := <-ch:
-			s.logger.Info("successfully receive from queue: %s", msg.Body)
-			msg.Ack(false)
+		case <-ticker.C:
+			s.flush(ctx, time.Now())
+		case interval := <-s.intervals:
+			ticker.Reset(interval)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+func (s *Sender) handle(ctx context.Context, msg broker.Message) error {
+	if msg.ContentType == storage.AgendaContentType {
+		return s.handleAgenda(ctx, msg)
+	}
+
+	var notification storage.Notification
+	if err := json.Unmarshal(msg.Body, &notification); err != nil {
+		s.logger.Error("cannot parse notification %s: %s", msg.Body, err)
+		// it will never be parsed, so do not return it to the queue.
+		return fmt.Errorf("%w: %w", broker.ErrReject, err)
+	}
+
+	to, err := s.recipient(ctx, notification.UserID)
+	if err != nil {
+		// storage is not available, try again later.
+		return err
+	}
+
+	now := time.Now()
+	if at := deliveryTime(to.preferences, to.location, now); at.After(now) {
+		pending := &storage.PendingNotification{Notification: notification, DeliverAt: at}
+		if err := s.storage.AddPendingNotification(ctx, pending); err != nil {
+			return err
+		}
+
+		s.logger.Debug("notification for user %d is deferred till %s", notification.UserID, at.Format(time.RFC3339))
+		return nil
+	}
+
+	if err := s.deliver(to, now, templates.KindReminder, notification); err != nil {
+		s.logger.Error("cannot render notification %s: %s", msg.Body, err)
+		// templates are checked on load, so it is a problem of the message itself.
+		return fmt.Errorf("%w: %w", broker.ErrReject, err)
+	}
+
+	return nil
+}
+
+// handleAgenda delivers agenda of the day at once: it is sent at the time chosen by the user anyway.
+func (s *Sender) handleAgenda(ctx context.Context, msg broker.Message) error {
+	var agenda storage.Agenda
+	if err := json.Unmarshal(msg.Body, &agenda); err != nil {
+		s.logger.Error("cannot parse agenda %s: %s", msg.Body, err)
+		return fmt.Errorf("%w: %w", broker.ErrReject, err)
+	}
+
+	to, err := s.recipient(ctx, agenda.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deliver(to, time.Now(), templates.KindAgenda, agenda.Events...); err != nil {
+		s.logger.Error("cannot render agenda %s: %s", msg.Body, err)
+		return fmt.Errorf("%w: %w", broker.ErrReject, err)
+	}
+
+	return nil
+}
+
+// flush delivers deferred notifications that are due, several notifications of one user are sent as a digest.
+func (s *Sender) flush(ctx context.Context, now time.Time) {
+	pending, err := s.storage.TakePendingNotifications(ctx, now)
+	if err != nil {
+		s.logger.Error("cannot take pending notifications: %s", err)
+		return
+	}
+
+	var users []int64
+	byUser := make(map[int64][]*storage.PendingNotification)
+	for _, p := range pending {
+		if _, found := byUser[p.Notification.UserID]; !found {
+			users = append(users, p.Notification.UserID)
+		}
+		byUser[p.Notification.UserID] = append(byUser[p.Notification.UserID], p)
+	}
+
+	for _, userID := range users {
+		notifications := byUser[userID]
+
+		to, err := s.recipient(ctx, userID)
+		if err != nil {
+			s.logger.Error("cannot get preferences of user %d: %s", userID, err)
+			s.restore(ctx, notifications)
+			continue
+		}
+
+		kind := templates.KindDigest
+		if len(notifications) == 1 && to.preferences.Digest == storage.DigestOff {
+			kind = templates.KindReminder
+		}
+
+		events := make([]storage.Notification, 0, len(notifications))
+		for _, p := range notifications {
+			events = append(events, p.Notification)
+		}
+
+		if err := s.deliver(to, now, kind, events...); err != nil {
+			// the same templates will not render them later, so they are dropped like broken messages.
+			s.logger.Error("cannot render %s for user %d: %s", kind, userID, err)
+		}
+	}
+}
+
+// restore returns notifications back to the outbox.
+func (s *Sender) restore(ctx context.Context, notifications []*storage.PendingNotification) {
+	for _, p := range notifications {
+		if err := s.storage.AddPendingNotification(ctx, p); err != nil {
+			s.logger.Error("cannot restore pending notification %s: %s", p.Notification.EventID, err)
+		}
+	}
+}
+
+// recipient returns preferences of the user with defaults for missing values.
+func (s *Sender) recipient(ctx context.Context, userID int64) (*recipient, error) {
+	preferences, err := s.storage.GetPreferences(ctx, userID)
+	switch {
+	case errors.Is(err, storage.ErrPreferencesNotFound):
+		preferences = &storage.Preferences{UserID: userID}
+	case err != nil:
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defaults := s.defaults
+	s.mu.Unlock()
+
+	if preferences.Channel == "" {
+		preferences.Channel = defaults.Channel
+	}
+	if preferences.Locale == "" {
+		preferences.Locale = defaults.Locale
+	}
+
+	location := defaults.Location
+	if preferences.TimeZone != "" {
+		// time zone is checked on save, but tzdata may differ between services.
+		if loc, err := time.LoadLocation(preferences.TimeZone); err == nil {
+			location = loc
+		}
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	return &recipient{preferences: preferences, location: location}, nil
+}
+
+func (s *Sender) deliver(to *recipient, now time.Time, kind string, notifications ...storage.Notification) error {
+	events := make([]templates.Data, 0, len(notifications))
+	for _, notification := range notifications {
+		events = append(events, templates.Data{
+			EventID:  notification.EventID,
+			Title:    notification.Title,
+			DateTime: notification.DateTime,
+			UserID:   notification.UserID,
+			AllDay:   notification.AllDay,
+		})
+	}
+
+	message, err := s.renderer.Render(
+		kind,
+		to.preferences.Channel,
+		to.preferences.Locale,
+		to.location,
+		now,
+		events...,
+	)
+	if err != nil {
+		return err
+	}
+
+	address := fmt.Sprintf("user %d", to.preferences.UserID)
+	if to.preferences.Address != "" {
+		address += " <" + to.preferences.Address + ">"
+	}
+
+	what := "notification"
+	if kind != templates.KindReminder {
+		what = kind
+	}
+
+	s.logger.Info("send %s %s to %s: %s", to.preferences.Channel, what, address, message.Text)
+	return nil
+}
@@ -0,0 +1,195 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/app/sender/templates"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	memorybroker "github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newSender(t *testing.T, w io.Writer, consumer broker.Consumer, st Storage) *Sender {
+	t.Helper()
+
+	renderer := templates.New("../../../templates", "en")
+	require.NoError(t, renderer.Load())
+
+	return New(logger.New("INFO", w), consumer, 2, renderer, st, Defaults{
+		Channel:  "sms",
+		Locale:   "en",
+		Location: time.UTC,
+	})
+}
+
+func TestConsume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var out syncBuffer
+	queue := memorybroker.New(10)
+	s := newSender(t, &out, queue, memorystorage.New())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Consume(ctx)
+	}()
+
+	body := `{"event_id":"1","title":"Meeting","date_time":"2024-01-10T10:00:00Z","user_id":7}`
+	require.NoError(t, queue.Publish(ctx, broker.Message{ContentType: "application/json", Body: []byte(body)}))
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "send sms notification to user 7: Meeting") &&
+			strings.Contains(out.String(), "10:00 AM")
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSetThreadsRestartsConsuming(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var out syncBuffer
+	queue := memorybroker.New(10)
+	s := newSender(t, &out, queue, memorystorage.New())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Consume(ctx)
+	}()
+
+	s.SetThreads(4)
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "restart consuming with 4 workers")
+	}, time.Second, 10*time.Millisecond)
+
+	// messages are still consumed after restart
+	body := `{"event_id":"1","title":"Meeting","date_time":"2024-01-10T10:00:00Z","user_id":7}`
+	require.NoError(t, queue.Publish(ctx, broker.Message{ContentType: "application/json", Body: []byte(body)}))
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "send sms notification to user 7: Meeting")
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestHandleRejectsBrokenNotification(t *testing.T) {
+	s := newSender(t, io.Discard, memorybroker.New(1), memorystorage.New())
+
+	err := s.handle(context.Background(), broker.Message{Body: []byte("not a json")})
+	require.ErrorIs(t, err, broker.ErrReject)
+}
+
+func notificationMessage(t *testing.T, title string, dateTime time.Time) broker.Message {
+	t.Helper()
+
+	body, err := json.Marshal(storage.Notification{EventID: title, Title: title, DateTime: dateTime, UserID: 7})
+	require.NoError(t, err)
+
+	return broker.Message{ContentType: "application/json", Body: body}
+}
+
+func TestHandleDefersDuringQuietHours(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	st := memorystorage.New()
+	require.NoError(t, st.SavePreferences(ctx, &storage.Preferences{
+		UserID:     7,
+		Channel:    "sms",
+		Address:    "+70000000000",
+		QuietStart: now.Add(-time.Hour).Format(storage.ClockLayout),
+		QuietEnd:   now.Add(time.Hour).Format(storage.ClockLayout),
+	}))
+
+	var out syncBuffer
+	s := newSender(t, &out, memorybroker.New(1), st)
+
+	require.NoError(t, s.handle(ctx, notificationMessage(t, "Meeting", now.Add(2*time.Hour))))
+	require.NotContains(t, out.String(), "send sms")
+
+	// nothing is due yet
+	s.flush(ctx, now)
+	require.NotContains(t, out.String(), "send sms")
+
+	s.flush(ctx, now.Add(2*time.Hour))
+	require.Contains(t, out.String(), "send sms notification to user 7 <+70000000000>: Meeting")
+
+	pending, err := st.TakePendingNotifications(ctx, now.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestFlushSendsDigest(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	st := memorystorage.New()
+	require.NoError(t, st.SavePreferences(ctx, &storage.Preferences{
+		UserID:  7,
+		Channel: "sms",
+		Address: "+70000000000",
+		Digest:  storage.DigestHourly,
+	}))
+
+	var out syncBuffer
+	s := newSender(t, &out, memorybroker.New(1), st)
+
+	require.NoError(t, s.handle(ctx, notificationMessage(t, "Meeting", now.Add(3*time.Hour))))
+	require.NoError(t, s.handle(ctx, notificationMessage(t, "Review", now.Add(4*time.Hour))))
+	require.NotContains(t, out.String(), "send sms")
+
+	s.flush(ctx, now.Add(time.Hour))
+	require.Contains(t, out.String(), "send sms digest to user 7 <+70000000000>: Meeting")
+	require.Contains(t, out.String(), "; Review")
+	require.Equal(t, 1, strings.Count(out.String(), "send sms"))
+}
+
+func TestHandleAgenda(t *testing.T) {
+	ctx := context.Background()
+
+	var out syncBuffer
+	s := newSender(t, &out, memorybroker.New(1), memorystorage.New())
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	body, err := json.Marshal(storage.Agenda{
+		UserID: 7,
+		Day:    day,
+		Events: []storage.Notification{
+			{EventID: "1", Title: "Standup", DateTime: day.Add(10 * time.Hour), UserID: 7},
+			{EventID: "2", Title: "Review", DateTime: day.Add(15 * time.Hour), UserID: 7},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.handle(ctx, broker.Message{ContentType: storage.AgendaContentType, Body: body}))
+	require.Contains(t, out.String(), "send sms agenda to user 7: Wed, Jan 10, 2024: 10:00 AM Standup; 3:00 PM Review")
+}
@@ -0,0 +1,75 @@
+package sender
+
+import (
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// daily digest is delivered at this local hour.
+const dailyDigestHour = 9
+
+// deliveryTime returns when the notification has to be delivered to the user: digest users get
+// it at the next digest period, nobody gets it during quiet hours. Result not after now means "send now".
+func deliveryTime(preferences *storage.Preferences, location *time.Location, now time.Time) time.Time {
+	local := now.In(location)
+	at := local
+
+	switch preferences.Digest {
+	case storage.DigestHourly:
+		at = time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, location)
+	case storage.DigestDaily:
+		at = time.Date(local.Year(), local.Month(), local.Day(), dailyDigestHour, 0, 0, 0, location)
+		if !at.After(local) {
+			at = at.AddDate(0, 0, 1)
+		}
+	case storage.DigestOff:
+	}
+
+	if end, quiet := quietEnd(preferences, at); quiet {
+		return end
+	}
+
+	return at
+}
+
+// quietEnd reports whether t is inside quiet hours and returns the end of them.
+// Quiet hours may cross midnight, e.g. 22:00-08:00.
+func quietEnd(preferences *storage.Preferences, t time.Time) (time.Time, bool) {
+	start, okStart := clock(preferences.QuietStart)
+	end, okEnd := clock(preferences.QuietEnd)
+	if !okStart || !okEnd || start == end {
+		return time.Time{}, false
+	}
+
+	current := t.Hour()*60 + t.Minute()
+	endOfDay := func(days int) time.Time {
+		// time.Date normalizes minutes, so wall clock is right on DST changes as well.
+		return time.Date(t.Year(), t.Month(), t.Day()+days, 0, end, 0, 0, t.Location())
+	}
+
+	switch {
+	case start < end && current >= start && current < end:
+		return endOfDay(0), true
+	case start > end && current >= start:
+		return endOfDay(1), true
+	case start > end && current < end:
+		return endOfDay(0), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// clock parses "HH:MM" into minutes since midnight.
+func clock(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	t, err := time.Parse(storage.ClockLayout, value)
+	if err != nil {
+		return 0, false
+	}
+
+	return t.Hour()*60 + t.Minute(), true
+}
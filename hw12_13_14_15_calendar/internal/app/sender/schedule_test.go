@@ -0,0 +1,56 @@
+package sender
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryTime(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, moscow)
+	}
+
+	tests := []struct {
+		name        string
+		preferences storage.Preferences
+		now         time.Time
+		expected    time.Time
+	}{
+		{"no preferences", storage.Preferences{}, at(10, 23, 30), at(10, 23, 30)},
+		{"before quiet hours", storage.Preferences{QuietStart: "22:00", QuietEnd: "08:00"}, at(10, 21, 59), at(10, 21, 59)},
+		{"quiet evening", storage.Preferences{QuietStart: "22:00", QuietEnd: "08:00"}, at(10, 23, 30), at(11, 8, 0)},
+		{"quiet night", storage.Preferences{QuietStart: "22:00", QuietEnd: "08:00"}, at(11, 3, 0), at(11, 8, 0)},
+		{"after quiet hours", storage.Preferences{QuietStart: "22:00", QuietEnd: "08:00"}, at(11, 8, 0), at(11, 8, 0)},
+		{"quiet day", storage.Preferences{QuietStart: "13:00", QuietEnd: "14:30"}, at(10, 13, 15), at(10, 14, 30)},
+		{"hourly digest", storage.Preferences{Digest: storage.DigestHourly}, at(10, 12, 15), at(10, 13, 0)},
+		{"hourly digest at midnight", storage.Preferences{Digest: storage.DigestHourly}, at(10, 23, 15), at(11, 0, 0)},
+		{
+			"hourly digest in quiet hours",
+			storage.Preferences{Digest: storage.DigestHourly, QuietStart: "22:00", QuietEnd: "08:00"},
+			at(10, 21, 15),
+			at(11, 8, 0),
+		},
+		{"daily digest", storage.Preferences{Digest: storage.DigestDaily}, at(10, 7, 0), at(10, 9, 0)},
+		{"daily digest tomorrow", storage.Preferences{Digest: storage.DigestDaily}, at(10, 9, 0), at(11, 9, 0)},
+		{
+			"daily digest in quiet hours",
+			storage.Preferences{Digest: storage.DigestDaily, QuietStart: "08:00", QuietEnd: "10:00"},
+			at(10, 12, 0),
+			at(11, 10, 0),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := deliveryTime(&tc.preferences, moscow, tc.now.UTC())
+			require.True(t, tc.expected.Equal(got), "expected %s, got %s", tc.expected, got)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package templates
+
+import (
+	"fmt"
+	"time"
+)
+
+// phrasebook contains locale dependent formats and words for relative time.
+type phrasebook struct {
+	dateLayout string
+	timeLayout string
+	allDay     string
+	now        string
+	future     string
+	past       string
+	units      func(n int, unit string) string
+}
+
+var phrasebooks = map[string]phrasebook{
+	"en": {
+		dateLayout: "Mon, Jan 2, 2006",
+		timeLayout: "3:04 PM",
+		allDay:     "all day",
+		now:        "now",
+		future:     "in %s",
+		past:       "%s ago",
+		units:      englishUnits,
+	},
+	"ru": {
+		dateLayout: "02.01.2006",
+		timeLayout: "15:04",
+		allDay:     "весь день",
+		now:        "сейчас",
+		future:     "через %s",
+		past:       "%s назад",
+		units:      russianUnits,
+	},
+}
+
+func phrasebookFor(locale string) phrasebook {
+	if book, ok := phrasebooks[locale]; ok {
+		return book
+	}
+
+	return phrasebooks["en"]
+}
+
+// relative returns phrase like "in 15 minutes" for the duration between now and t.
+func (b phrasebook) relative(t, now time.Time) string {
+	d := t.Sub(now)
+
+	format := b.future
+	if d < 0 {
+		format = b.past
+		d = -d
+	}
+
+	var amount string
+	switch {
+	case d < time.Minute:
+		return b.now
+	case d < time.Hour:
+		amount = b.units(int(d.Round(time.Minute)/time.Minute), "minute")
+	case d < 24*time.Hour:
+		amount = b.units(int(d.Round(time.Hour)/time.Hour), "hour")
+	default:
+		amount = b.units(int(d.Round(24*time.Hour)/(24*time.Hour)), "day")
+	}
+
+	return fmt.Sprintf(format, amount)
+}
+
+func englishUnits(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+var russianForms = map[string][3]string{
+	"minute": {"минуту", "минуты", "минут"},
+	"hour":   {"час", "часа", "часов"},
+	"day":    {"день", "дня", "дней"},
+}
+
+// russianUnits chooses one of three plural forms: 1 минуту, 2 минуты, 5 минут.
+func russianUnits(n int, unit string) string {
+	forms := russianForms[unit]
+
+	form := forms[2]
+	switch {
+	case n%100 >= 11 && n%100 <= 14:
+	case n%10 == 1:
+		form = forms[0]
+	case n%10 >= 2 && n%10 <= 4:
+		form = forms[1]
+	}
+
+	return fmt.Sprintf("%d %s", n, form)
+}
@@ -0,0 +1,325 @@
+// Package templates renders notifications from text and HTML templates stored in a directory:
+//
+//	<dir>/<locale>/<channel>/<kind>.txt.tmpl
+//	<dir>/<locale>/<channel>/<kind>.html.tmpl
+//
+// Text template may define "subject" block, it is used as the subject of the message.
+// Digest and agenda templates iterate over .Events, other kinds use the fields of the first event.
+package templates
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
+)
+
+const (
+	// KindReminder is a notification about upcoming event.
+	KindReminder = "reminder"
+	// KindDigest is a summary of several events sent at once.
+	KindDigest = "digest"
+	// KindAgenda is a summary of events of the day.
+	KindAgenda = "agenda"
+)
+
+const (
+	textExt = ".txt.tmpl"
+	htmlExt = ".html.tmpl"
+
+	subjectTemplate = "subject"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrNoTemplates      = errors.New("there are no templates for default locale")
+)
+
+// Data is an event the notification is rendered for. DateTime of all-day event is its date in UTC.
+type Data struct {
+	EventID  string
+	Title    string
+	DateTime time.Time
+	UserID   int64
+	AllDay   bool
+}
+
+// View is available inside templates. DateTime is converted to the time zone of the user,
+// all-day events start at the midnight there and their Time is a phrase like "all day".
+// View itself describes the first event, Events contains views of all rendered events.
+type View struct {
+	Data
+	Date     string
+	Time     string
+	Relative string
+	Locale   string
+	Now      time.Time
+	Events   []*View
+}
+
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type key struct {
+	locale  string
+	channel string
+	kind    string
+}
+
+type set struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+type Registry struct {
+	dir           string
+	defaultLocale string
+
+	mu          sync.RWMutex
+	sets        map[key]*set
+	fingerprint string
+}
+
+func New(dir string, defaultLocale string) *Registry {
+	return &Registry{
+		dir:           dir,
+		defaultLocale: defaultLocale,
+	}
+}
+
+// Load parses and checks all templates. Current templates are replaced only if all new ones are valid.
+func (r *Registry) Load() error {
+	fingerprint, err := r.scan()
+	if err != nil {
+		return err
+	}
+
+	return r.load(fingerprint)
+}
+
+// Watch reloads templates when files in the directory are changed. Invalid templates are reported
+// with onError and ignored, so the last valid version keeps working.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	r.mu.RLock()
+	last := r.fingerprint
+	r.mu.RUnlock()
+
+	reload.NewPoller(last, r.scan).Poll(ctx, interval, r.Load, onError)
+}
+
+// Render renders notification of the kind for the channel. Templates of default locale are used
+// when there are no templates for the locale.
+func (r *Registry) Render(
+	kind string,
+	channel string,
+	locale string,
+	location *time.Location,
+	now time.Time,
+	events ...Data,
+) (*Message, error) {
+	r.mu.RLock()
+	s, found := r.sets[key{locale: locale, channel: channel, kind: kind}]
+	if !found {
+		locale = r.defaultLocale
+		s, found = r.sets[key{locale: locale, channel: channel, kind: kind}]
+	}
+	r.mu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s/%s/%s", ErrTemplateNotFound, locale, channel, kind)
+	}
+
+	return s.render(newView(locale, location, now, events))
+}
+
+func newView(locale string, location *time.Location, now time.Time, events []Data) *View {
+	if len(events) == 0 {
+		return eventView(locale, location, now, Data{})
+	}
+
+	view := eventView(locale, location, now, events[0])
+	for _, data := range events {
+		view.Events = append(view.Events, eventView(locale, location, now, data))
+	}
+
+	return view
+}
+
+func eventView(locale string, location *time.Location, now time.Time, data Data) *View {
+	if location == nil {
+		location = time.UTC
+	}
+
+	book := phrasebookFor(locale)
+	clock := data.DateTime.In(location).Format(book.timeLayout)
+	if data.AllDay {
+		date := data.DateTime.UTC()
+		data.DateTime = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location)
+		clock = book.allDay
+	} else {
+		data.DateTime = data.DateTime.In(location)
+	}
+
+	return &View{
+		Data:     data,
+		Date:     data.DateTime.Format(book.dateLayout),
+		Time:     clock,
+		Relative: book.relative(data.DateTime, now),
+		Locale:   locale,
+		Now:      now.In(location),
+	}
+}
+
+func (s *set) render(view *View) (*Message, error) {
+	msg := &Message{}
+
+	if s.text != nil {
+		var buf bytes.Buffer
+		if err := s.text.Execute(&buf, view); err != nil {
+			return nil, err
+		}
+		msg.Text = strings.TrimSpace(buf.String())
+
+		if subject := s.text.Lookup(subjectTemplate); subject != nil {
+			buf.Reset()
+			if err := subject.Execute(&buf, view); err != nil {
+				return nil, err
+			}
+			msg.Subject = strings.TrimSpace(buf.String())
+		}
+	}
+
+	if s.html != nil {
+		var buf bytes.Buffer
+		if err := s.html.Execute(&buf, view); err != nil {
+			return nil, err
+		}
+		msg.HTML = buf.String()
+	}
+
+	return msg, nil
+}
+
+func (r *Registry) load(fingerprint string) error {
+	sets := make(map[key]*set)
+
+	err := r.walk(func(path string, k key, ext string) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		s, ok := sets[k]
+		if !ok {
+			s = &set{}
+			sets[k] = s
+		}
+
+		switch ext {
+		case textExt:
+			s.text, err = texttemplate.New(filepath.Base(path)).Parse(string(content))
+		case htmlExt:
+			s.html, err = htmltemplate.New(filepath.Base(path)).Parse(string(content))
+		}
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := r.check(sets); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.sets = sets
+	r.fingerprint = fingerprint
+	r.mu.Unlock()
+
+	return nil
+}
+
+// check executes every template with sample data, so mistakes like unknown fields are found on load.
+func (r *Registry) check(sets map[key]*set) error {
+	hasDefault := false
+	now := time.Now()
+
+	for k, s := range sets {
+		if k.locale == r.defaultLocale {
+			hasDefault = true
+		}
+
+		sample := []Data{
+			{EventID: "sample", Title: "Sample", DateTime: now.Add(15 * time.Minute), UserID: 1},
+			{EventID: "other", Title: "Other", DateTime: now.Add(2 * time.Hour), UserID: 1},
+		}
+		if _, err := s.render(newView(k.locale, time.UTC, now, sample)); err != nil {
+			return fmt.Errorf("%s/%s/%s: %w", k.locale, k.channel, k.kind, err)
+		}
+	}
+
+	if !hasDefault {
+		return fmt.Errorf("%w %q in %s", ErrNoTemplates, r.defaultLocale, r.dir)
+	}
+
+	return nil
+}
+
+// scan returns fingerprint of templates in the directory: it changes when any file is changed.
+func (r *Registry) scan() (string, error) {
+	var paths []string
+
+	err := r.walk(func(path string, _ key, _ string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return reload.Files(paths...)
+}
+
+// walk calls fn for every template file, other files are ignored.
+func (r *Registry) walk(fn func(path string, k key, ext string) error) error {
+	return filepath.WalkDir(r.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 3 {
+			return nil
+		}
+
+		for _, ext := range []string{textExt, htmlExt} {
+			if kind, ok := strings.CutSuffix(parts[2], ext); ok {
+				return fn(path, key{locale: parts[0], channel: parts[1], kind: kind}, ext)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,201 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const defaultTemplates = "../../../../templates"
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	// replace atomically, so watcher never sees half written file
+	require.NoError(t, os.WriteFile(path+".tmp", []byte(content), 0o644))
+	require.NoError(t, os.Rename(path+".tmp", path))
+}
+
+func TestRender(t *testing.T) {
+	r := New(defaultTemplates, "en")
+	require.NoError(t, r.Load())
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 10, 9, 45, 0, 0, time.UTC)
+	data := Data{EventID: "1", Title: "Standup", DateTime: now.Add(15 * time.Minute), UserID: 1}
+
+	msg, err := r.Render(KindReminder, "email", "ru", moscow, now, data)
+	require.NoError(t, err)
+	require.Equal(t, "Напоминание: Standup через 15 минут", msg.Subject)
+	require.Contains(t, msg.Text, "10.01.2024 в 13:00")
+	require.Contains(t, msg.HTML, "<strong>Standup</strong>")
+
+	msg, err = r.Render(KindReminder, "sms", "en", time.UTC, now, data)
+	require.NoError(t, err)
+	require.Equal(t, "Standup in 15 minutes, 10:00 AM", msg.Text)
+	require.Empty(t, msg.HTML)
+
+	// unknown locale falls back to the default one
+	msg, err = r.Render(KindReminder, "sms", "de", time.UTC, now, data)
+	require.NoError(t, err)
+	require.Equal(t, "Standup in 15 minutes, 10:00 AM", msg.Text)
+
+	_, err = r.Render(KindReminder, "pigeon", "en", time.UTC, now, data)
+	require.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestRenderDigest(t *testing.T) {
+	r := New(defaultTemplates, "en")
+	require.NoError(t, r.Load())
+
+	now := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	events := []Data{
+		{EventID: "1", Title: "Standup", DateTime: now.Add(time.Hour), UserID: 1},
+		{EventID: "2", Title: "Review", DateTime: now.Add(5 * time.Hour), UserID: 1},
+	}
+
+	msg, err := r.Render(KindDigest, "email", "en", time.UTC, now, events...)
+	require.NoError(t, err)
+	require.Equal(t, "Your upcoming events: 2", msg.Subject)
+	require.Contains(t, msg.Text, `- "Standup" in 1 hour: `)
+	require.Contains(t, msg.Text, `- "Review" in 5 hours: `)
+	require.Contains(t, msg.HTML, "<li><strong>Review</strong>")
+
+	msg, err = r.Render(KindDigest, "sms", "en", time.UTC, now, events...)
+	require.NoError(t, err)
+	require.Equal(t, "Standup 10:00 AM; Review 2:00 PM", msg.Text)
+}
+
+func TestRenderAllDay(t *testing.T) {
+	r := New(defaultTemplates, "en")
+	require.NoError(t, r.Load())
+
+	// the date is kept in every time zone, even where it is still the previous day in UTC.
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 9, 18, 0, 0, 0, losAngeles)
+	data := Data{EventID: "1", Title: "Holiday", DateTime: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), AllDay: true}
+
+	msg, err := r.Render(KindReminder, "email", "en", losAngeles, now, data)
+	require.NoError(t, err)
+	require.Contains(t, msg.Text, "Wed, Jan 10, 2024, all day")
+
+	msg, err = r.Render(KindReminder, "sms", "ru", losAngeles, now, data)
+	require.NoError(t, err)
+	require.Equal(t, "Holiday через 6 часов, весь день", msg.Text)
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	r := New(defaultTemplates, "en")
+	require.NoError(t, r.Load())
+
+	msg, err := r.Render(KindReminder, "email", "en", time.UTC, time.Now(), Data{
+		Title:    "<script>alert(1)</script>",
+		DateTime: time.Now(),
+	})
+	require.NoError(t, err)
+	require.NotContains(t, msg.HTML, "<script>")
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Run("syntax error", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "en/sms/reminder.txt.tmpl", "{{.Title")
+		require.Error(t, New(dir, "en").Load())
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "en/sms/reminder.txt.tmpl", "{{.Location}}")
+		require.Error(t, New(dir, "en").Load())
+	})
+
+	t.Run("no templates for default locale", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "ru/sms/reminder.txt.tmpl", "{{.Title}}")
+		require.ErrorIs(t, New(dir, "en").Load(), ErrNoTemplates)
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		require.Error(t, New(filepath.Join(t.TempDir(), "missing"), "en").Load())
+	})
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "en/sms/reminder.txt.tmpl", "first {{.Title}}")
+
+	r := New(dir, "en")
+	require.NoError(t, r.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var watchErr error
+	go r.Watch(ctx, 10*time.Millisecond, func(err error) {
+		mu.Lock()
+		watchErr = err
+		mu.Unlock()
+	})
+
+	render := func() string {
+		msg, err := r.Render(KindReminder, "sms", "en", time.UTC, time.Now(), Data{Title: "event"})
+		require.NoError(t, err)
+		return msg.Text
+	}
+
+	// file system may keep modification time with low precision, so size is changed as well
+	writeTemplate(t, dir, "en/sms/reminder.txt.tmpl", "second version {{.Title}}")
+	require.Eventually(t, func() bool {
+		return render() == "second version event"
+	}, time.Second, 10*time.Millisecond)
+
+	// broken template is reported and the last valid one is kept
+	writeTemplate(t, dir, "en/sms/reminder.txt.tmpl", "{{.Title")
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return watchErr != nil
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, "second version event", render())
+}
+
+func TestRelative(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		locale   string
+		shift    time.Duration
+		expected string
+	}{
+		{locale: "en", shift: 30 * time.Second, expected: "now"},
+		{locale: "en", shift: time.Minute, expected: "in 1 minute"},
+		{locale: "en", shift: 15 * time.Minute, expected: "in 15 minutes"},
+		{locale: "en", shift: -2 * time.Hour, expected: "2 hours ago"},
+		{locale: "en", shift: 3 * 24 * time.Hour, expected: "in 3 days"},
+		{locale: "ru", shift: time.Minute, expected: "через 1 минуту"},
+		{locale: "ru", shift: 3 * time.Minute, expected: "через 3 минуты"},
+		{locale: "ru", shift: 11 * time.Minute, expected: "через 11 минут"},
+		{locale: "ru", shift: 21 * time.Minute, expected: "через 21 минуту"},
+		{locale: "ru", shift: 5 * time.Hour, expected: "через 5 часов"},
+		{locale: "ru", shift: -2 * 24 * time.Hour, expected: "2 дня назад"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.expected, func(t *testing.T) {
+			require.Equal(t, tc.expected, phrasebookFor(tc.locale).relative(now.Add(tc.shift), now))
+		})
+	}
+}
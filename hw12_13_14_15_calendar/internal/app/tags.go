@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (a *App) GetTags(ctx context.Context, userID int64) ([]*storage.Tag, error) {
+	if err := actAs(ctx, &userID); err != nil {
+		return nil, err
+	}
+
+	tags, err := a.storage.GetTags(ctx, userID)
+	return tags, storageError(err)
+}
+
+func (a *App) SaveTag(ctx context.Context, tag *storage.Tag) error {
+	if err := actAs(ctx, &tag.UserID); err != nil {
+		return err
+	}
+
+	if err := validateTag(tag); err != nil {
+		return err
+	}
+
+	return storageError(a.storage.SaveTag(ctx, tag))
+}
+
+// DeleteTag removes the tag from events of the user too.
+func (a *App) DeleteTag(ctx context.Context, userID int64, name string) error {
+	if err := actAs(ctx, &userID); err != nil {
+		return err
+	}
+
+	return storageError(a.storage.DeleteTag(ctx, userID, name))
+}
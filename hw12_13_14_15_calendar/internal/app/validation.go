@@ -0,0 +1,240 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	// MaxTitleLength is the size of event.title column.
+	MaxTitleLength = 255
+	// MaxTagLength is the size of tag.name column.
+	MaxTagLength = 64
+	// MaxCalendarNameLength is the size of calendar.name column.
+	MaxCalendarNameLength = 255
+)
+
+// colorPattern is "#RRGGBB", the same format as HTML color input.
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// validateEvent checks event before create and update. Field names are the same as in API.
+func validateEvent(event *storage.Event) error {
+	var violations []apperror.FieldViolation
+	add := func(field, description string) {
+		violations = append(violations, apperror.FieldViolation{Field: field, Description: description})
+	}
+
+	switch title := strings.TrimSpace(event.Title); {
+	case title == "":
+		add("title", "title is required")
+	case utf8.RuneCountInString(event.Title) > MaxTitleLength:
+		add("title", fmt.Sprintf("title must be at most %d characters", MaxTitleLength))
+	}
+
+	// all-day events are set by dates in API.
+	startField, endField := "date_time", "end_time"
+	if event.AllDay {
+		startField, endField = "start_date", "end_date"
+	}
+
+	switch {
+	case event.DateTime.IsZero():
+		add(startField, startField+" is required")
+	case event.AllDay && !isDate(event.DateTime):
+		add(startField, startField+" must be a date without time")
+	}
+
+	switch {
+	case event.EndTime.Before(event.DateTime):
+		add(endField, endField+" must not be before "+startField)
+	case event.AllDay && !event.EndTime.After(event.DateTime):
+		add(endField, "all-day event must take at least one day")
+	case event.AllDay && !isDate(event.EndTime):
+		add(endField, endField+" must be a date without time")
+	}
+
+	if !event.TimeNotification.IsZero() && !event.DateTime.IsZero() && event.TimeNotification.After(event.DateTime) {
+		add("time_notification", "time_notification must not be after date_time")
+	}
+
+	if event.Color != "" && !colorPattern.MatchString(event.Color) {
+		add("color", "color must be in #RRGGBB format")
+	}
+
+	for _, name := range event.Tags {
+		if description := tagNameProblem(name); description != "" {
+			add("tags", description)
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return apperror.Validation(violations)
+	}
+
+	return nil
+}
+
+// validatePreferences checks preferences before save. Field names are the same as in API.
+func validatePreferences(preferences *storage.Preferences) error {
+	var violations []apperror.FieldViolation
+	add := func(field, description string) {
+		violations = append(violations, apperror.FieldViolation{Field: field, Description: description})
+	}
+
+	if preferences.UserID <= 0 {
+		add("user_id", "user_id must be positive")
+	}
+
+	if strings.TrimSpace(preferences.Channel) == "" {
+		add("channel", "channel is required")
+	}
+
+	if strings.TrimSpace(preferences.Address) == "" {
+		add("address", "address is required")
+	}
+
+	if preferences.TimeZone != "" {
+		if _, err := time.LoadLocation(preferences.TimeZone); err != nil {
+			add("time_zone", "unknown time zone "+preferences.TimeZone)
+		}
+	}
+
+	switch {
+	case (preferences.QuietStart == "") != (preferences.QuietEnd == ""):
+		add("quiet_hours", "quiet_start and quiet_end must be set together")
+	case preferences.QuietStart != "":
+		if _, err := time.Parse(storage.ClockLayout, preferences.QuietStart); err != nil {
+			add("quiet_start", "quiet_start must be in HH:MM format")
+		}
+		if _, err := time.Parse(storage.ClockLayout, preferences.QuietEnd); err != nil {
+			add("quiet_end", "quiet_end must be in HH:MM format")
+		}
+	}
+
+	switch preferences.Digest {
+	case storage.DigestOff, storage.DigestHourly, storage.DigestDaily:
+	default:
+		add("digest", "unknown digest mode "+string(preferences.Digest))
+	}
+
+	if len(violations) > 0 {
+		return apperror.Validation(violations)
+	}
+
+	return nil
+}
+
+// validateTag checks tag before save. Field names are the same as in API.
+func validateTag(tag *storage.Tag) error {
+	var violations []apperror.FieldViolation
+	add := func(field, description string) {
+		violations = append(violations, apperror.FieldViolation{Field: field, Description: description})
+	}
+
+	if tag.UserID <= 0 {
+		add("user_id", "user_id must be positive")
+	}
+
+	if description := tagNameProblem(tag.Name); description != "" {
+		add("name", description)
+	}
+
+	if tag.Color != "" && !colorPattern.MatchString(tag.Color) {
+		add("color", "color must be in #RRGGBB format")
+	}
+
+	if len(violations) > 0 {
+		return apperror.Validation(violations)
+	}
+
+	return nil
+}
+
+// tagNameProblem describes why the name can not be used, the name is a part of REST API path.
+func tagNameProblem(name string) string {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return "tag name is required"
+	case strings.TrimSpace(name) != name:
+		return "tag name must not start or end with spaces"
+	case utf8.RuneCountInString(name) > MaxTagLength:
+		return fmt.Sprintf("tag name must be at most %d characters", MaxTagLength)
+	case strings.Contains(name, "/"):
+		return "tag name must not contain /"
+	default:
+		return ""
+	}
+}
+
+// isDate reports whether the time is a midnight in UTC, all-day events are kept in such form.
+func isDate(t time.Time) bool {
+	return t.Equal(t.UTC().Truncate(24 * time.Hour))
+}
+
+// validateCalendar checks calendar before create and update. Field names are the same as in API.
+func validateCalendar(calendar *storage.Calendar) error {
+	var violations []apperror.FieldViolation
+	add := func(field, description string) {
+		violations = append(violations, apperror.FieldViolation{Field: field, Description: description})
+	}
+
+	if calendar.OwnerID <= 0 {
+		add("owner_id", "owner_id must be positive")
+	}
+
+	switch name := strings.TrimSpace(calendar.Name); {
+	case name == "":
+		add("name", "name is required")
+	case utf8.RuneCountInString(calendar.Name) > MaxCalendarNameLength:
+		add("name", fmt.Sprintf("name must be at most %d characters", MaxCalendarNameLength))
+	}
+
+	if calendar.TimeZone != "" {
+		if _, err := time.LoadLocation(calendar.TimeZone); err != nil {
+			add("time_zone", "unknown time zone "+calendar.TimeZone)
+		}
+	}
+
+	if calendar.Color != "" && !colorPattern.MatchString(calendar.Color) {
+		add("color", "color must be in #RRGGBB format")
+	}
+
+	if len(violations) > 0 {
+		return apperror.Validation(violations)
+	}
+
+	return nil
+}
+
+// validateShare checks share before save. Field names are the same as in API.
+func validateShare(share *storage.Share) error {
+	var violations []apperror.FieldViolation
+	add := func(field, description string) {
+		violations = append(violations, apperror.FieldViolation{Field: field, Description: description})
+	}
+
+	if share.UserID <= 0 {
+		add("user_id", "user_id must be positive")
+	}
+
+	switch share.Role {
+	case storage.RoleFreeBusy, storage.RoleRead, storage.RoleWrite:
+	case "":
+		add("role", "role is required")
+	default:
+		add("role", "unknown role "+string(share.Role))
+	}
+
+	if len(violations) > 0 {
+		return apperror.Validation(violations)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEvent(t *testing.T) {
+	now := time.Now()
+
+	validEvent := func() *storage.Event {
+		return &storage.Event{
+			Title:            "Event title",
+			DateTime:         now,
+			EndTime:          now.Add(time.Hour),
+			TimeNotification: now.Add(-time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(event *storage.Event)
+		fields []string
+	}{
+		{"valid", func(_ *storage.Event) {}, nil},
+		{"without notification", func(e *storage.Event) { e.TimeNotification = time.Time{} }, nil},
+		{"max title", func(e *storage.Event) { e.Title = strings.Repeat("я", MaxTitleLength) }, nil},
+		{"empty title", func(e *storage.Event) { e.Title = "   " }, []string{"title"}},
+		{"long title", func(e *storage.Event) { e.Title = strings.Repeat("a", MaxTitleLength+1) }, []string{"title"}},
+		{"without date", func(e *storage.Event) { e.DateTime = time.Time{} }, []string{"date_time"}},
+		{"without duration", func(e *storage.Event) { e.EndTime = e.DateTime }, nil},
+		{"end before start", func(e *storage.Event) { e.EndTime = now.Add(-time.Second) }, []string{"end_time"}},
+		{"all-day", func(e *storage.Event) { setDays(e, 1) }, nil},
+		{"multi-day", func(e *storage.Event) { setDays(e, 3) }, nil},
+		{"all-day without date", func(e *storage.Event) { setDays(e, 1); e.DateTime = time.Time{} }, []string{"start_date"}},
+		{"all-day with time", func(e *storage.Event) { setDays(e, 1); e.DateTime = now }, []string{"start_date"}},
+		{"all-day without days", func(e *storage.Event) { setDays(e, 0) }, []string{"end_date"}},
+		{"all-day ends before start", func(e *storage.Event) { setDays(e, -1) }, []string{"end_date"}},
+		{"all-day end time", func(e *storage.Event) { setDays(e, 1); e.EndTime = e.EndTime.Add(1) }, []string{"end_date"}},
+		{"late notification", func(e *storage.Event) { e.TimeNotification = now.Add(time.Minute) }, []string{"time_notification"}},
+		{"color and tags", func(e *storage.Event) { e.Color = "#FF8800"; e.Tags = []string{"work", "дом"} }, nil},
+		{"wrong color", func(e *storage.Event) { e.Color = "red" }, []string{"color"}},
+		{"empty tag", func(e *storage.Event) { e.Tags = []string{"work", " "} }, []string{"tags"}},
+		{"tag with slash", func(e *storage.Event) { e.Tags = []string{"a/b", "c/d"} }, []string{"tags"}},
+		{
+			"all at once",
+			func(e *storage.Event) { e.Title = ""; e.EndTime = now.Add(-time.Second); e.Color = "red" },
+			[]string{"title", "end_time", "color"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := validEvent()
+			test.modify(event)
+
+			err := validateEvent(event)
+			if test.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, apperror.CodeValidation, apperror.CodeOf(err))
+			fields := make([]string, 0, len(test.fields))
+			for _, violation := range apperror.FieldsOf(err) {
+				fields = append(fields, violation.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+// setDays makes the event all-day, it starts tomorrow and takes the number of days.
+func setDays(event *storage.Event, days int) {
+	event.AllDay = true
+	event.DateTime = time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	event.EndTime = event.DateTime.AddDate(0, 0, days)
+	event.TimeNotification = time.Time{}
+}
+
+func TestValidatePreferences(t *testing.T) {
+	validPreferences := func() *storage.Preferences {
+		return &storage.Preferences{
+			UserID:     1,
+			Channel:    "email",
+			Address:    "user@example.com",
+			TimeZone:   "Europe/Moscow",
+			QuietStart: "22:00",
+			QuietEnd:   "08:00",
+			Digest:     storage.DigestDaily,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(preferences *storage.Preferences)
+		fields []string
+	}{
+		{"valid", func(_ *storage.Preferences) {}, nil},
+		{"without quiet hours", func(p *storage.Preferences) { p.QuietStart = ""; p.QuietEnd = "" }, nil},
+		{"default time zone", func(p *storage.Preferences) { p.TimeZone = "" }, nil},
+		{"without user", func(p *storage.Preferences) { p.UserID = 0 }, []string{"user_id"}},
+		{"without address", func(p *storage.Preferences) { p.Channel = ""; p.Address = " " }, []string{"channel", "address"}},
+		{"unknown time zone", func(p *storage.Preferences) { p.TimeZone = "Mars/Olympus" }, []string{"time_zone"}},
+		{"half of quiet hours", func(p *storage.Preferences) { p.QuietEnd = "" }, []string{"quiet_hours"}},
+		{"wrong quiet hours", func(p *storage.Preferences) { p.QuietStart = "25:00"; p.QuietEnd = "8" }, []string{"quiet_start", "quiet_end"}},
+		{"unknown digest", func(p *storage.Preferences) { p.Digest = "weekly" }, []string{"digest"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preferences := validPreferences()
+			test.modify(preferences)
+
+			err := validatePreferences(preferences)
+			if test.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, apperror.CodeValidation, apperror.CodeOf(err))
+			fields := make([]string, 0, len(test.fields))
+			for _, violation := range apperror.FieldsOf(err) {
+				fields = append(fields, violation.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		tag    storage.Tag
+		fields []string
+	}{
+		{"valid", storage.Tag{UserID: 1, Name: "work", Color: "#0000ff"}, nil},
+		{"without color", storage.Tag{UserID: 1, Name: "work"}, nil},
+		{"max name", storage.Tag{UserID: 1, Name: strings.Repeat("я", MaxTagLength)}, nil},
+		{"without user", storage.Tag{Name: "work"}, []string{"user_id"}},
+		{"long name", storage.Tag{UserID: 1, Name: strings.Repeat("a", MaxTagLength+1)}, []string{"name"}},
+		{"spaces around name", storage.Tag{UserID: 1, Name: " work"}, []string{"name"}},
+		{"wrong color", storage.Tag{UserID: 1, Name: "work", Color: "#00f"}, []string{"color"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTag(&test.tag)
+			if test.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, apperror.CodeValidation, apperror.CodeOf(err))
+			fields := make([]string, 0, len(test.fields))
+			for _, violation := range apperror.FieldsOf(err) {
+				fields = append(fields, violation.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+func TestValidateCalendar(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar storage.Calendar
+		fields   []string
+	}{
+		{"valid", storage.Calendar{OwnerID: 1, Name: "Work", TimeZone: "Europe/Moscow", Color: "#00ff00"}, nil},
+		{"minimal", storage.Calendar{OwnerID: 1, Name: "Work"}, nil},
+		{"without owner", storage.Calendar{Name: "Work"}, []string{"owner_id"}},
+		{"blank name", storage.Calendar{OwnerID: 1, Name: "  "}, []string{"name"}},
+		{"long name", storage.Calendar{OwnerID: 1, Name: strings.Repeat("a", MaxCalendarNameLength+1)}, []string{"name"}},
+		{"unknown time zone", storage.Calendar{OwnerID: 1, Name: "Work", TimeZone: "Mars/Olympus"}, []string{"time_zone"}},
+		{"wrong color", storage.Calendar{OwnerID: 1, Name: "Work", Color: "green"}, []string{"color"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateCalendar(&test.calendar)
+			if test.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, apperror.CodeValidation, apperror.CodeOf(err))
+			fields := make([]string, 0, len(test.fields))
+			for _, violation := range apperror.FieldsOf(err) {
+				fields = append(fields, violation.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+func TestValidateShare(t *testing.T) {
+	tests := []struct {
+		name   string
+		share  storage.Share
+		fields []string
+	}{
+		{"free busy", storage.Share{UserID: 2, Role: storage.RoleFreeBusy}, nil},
+		{"read", storage.Share{UserID: 2, Role: storage.RoleRead}, nil},
+		{"write", storage.Share{UserID: 2, Role: storage.RoleWrite}, nil},
+		{"without user", storage.Share{Role: storage.RoleRead}, []string{"user_id"}},
+		{"without role", storage.Share{UserID: 2}, []string{"role"}},
+		{"unknown role", storage.Share{UserID: 2, Role: "admin"}, []string{"role"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateShare(&test.share)
+			if test.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, apperror.CodeValidation, apperror.CodeOf(err))
+			fields := make([]string, 0, len(test.fields))
+			for _, violation := range apperror.FieldsOf(err) {
+				fields = append(fields, violation.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
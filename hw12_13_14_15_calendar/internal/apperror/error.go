@@ -0,0 +1,133 @@
+// Package apperror describes errors of the calendar domain. Transport layers map them
+// to HTTP and gRPC statuses in one place, so the same failure looks the same for every client.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+type Code string
+
+const (
+	CodeInternal        Code = "internal"
+	CodeBadRequest      Code = "bad_request"
+	CodeNotFound        Code = "not_found"
+	CodeConflict        Code = "conflict"
+	CodeValidation      Code = "validation"
+	CodeUnauthenticated Code = "unauthenticated"
+	CodeForbidden       Code = "forbidden"
+	CodeUnavailable     Code = "unavailable"
+)
+
+type mapping struct {
+	httpStatus int
+	grpcCode   codes.Code
+}
+
+var mappings = map[Code]mapping{
+	CodeInternal:        {http.StatusInternalServerError, codes.Internal},
+	CodeBadRequest:      {http.StatusBadRequest, codes.InvalidArgument},
+	CodeNotFound:        {http.StatusNotFound, codes.NotFound},
+	CodeConflict:        {http.StatusConflict, codes.AlreadyExists},
+	CodeValidation:      {http.StatusUnprocessableEntity, codes.InvalidArgument},
+	CodeUnauthenticated: {http.StatusUnauthorized, codes.Unauthenticated},
+	CodeForbidden:       {http.StatusForbidden, codes.PermissionDenied},
+	CodeUnavailable:     {http.StatusServiceUnavailable, codes.Unavailable},
+}
+
+// order matters for reverse lookup: several codes may share the same gRPC code.
+var grpcLookupOrder = []Code{
+	CodeBadRequest,
+	CodeNotFound,
+	CodeConflict,
+	CodeUnauthenticated,
+	CodeForbidden,
+	CodeUnavailable,
+}
+
+// HTTPStatus returns HTTP status code for the error code.
+func (c Code) HTTPStatus() int {
+	if m, ok := mappings[c]; ok {
+		return m.httpStatus
+	}
+
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns gRPC status code for the error code.
+func (c Code) GRPCCode() codes.Code {
+	if m, ok := mappings[c]; ok {
+		return m.grpcCode
+	}
+
+	return codes.Internal
+}
+
+// CodeFromGRPC is used for errors that are produced by transport itself (bad request format, unknown route).
+func CodeFromGRPC(grpcCode codes.Code) Code {
+	for _, code := range grpcLookupOrder {
+		if mappings[code].grpcCode == grpcCode {
+			return code
+		}
+	}
+
+	return CodeInternal
+}
+
+// FieldViolation describes why the value of a single field is not acceptable.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+	Fields  []FieldViolation
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Validation reports all violations at once, so client can fix the whole request.
+func Validation(violations []FieldViolation) *Error {
+	return &Error{Code: CodeValidation, Message: "validation failed", Fields: violations}
+}
+
+// Wrap marks err with the code. Original error is still available for errors.Is.
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// FieldsOf returns field violations of the domain error, if any.
+func FieldsOf(err error) []FieldViolation {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+
+	return nil
+}
+
+// CodeOf returns code of the domain error or CodeInternal for any other error.
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+
+	return CodeInternal
+}
@@ -0,0 +1,59 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCodeMapping(t *testing.T) {
+	tests := []struct {
+		code       Code
+		httpStatus int
+		grpcCode   codes.Code
+	}{
+		{CodeInternal, http.StatusInternalServerError, codes.Internal},
+		{CodeNotFound, http.StatusNotFound, codes.NotFound},
+		{CodeConflict, http.StatusConflict, codes.AlreadyExists},
+		{CodeBadRequest, http.StatusBadRequest, codes.InvalidArgument},
+		{CodeValidation, http.StatusUnprocessableEntity, codes.InvalidArgument},
+		{CodeUnauthenticated, http.StatusUnauthorized, codes.Unauthenticated},
+		{CodeForbidden, http.StatusForbidden, codes.PermissionDenied},
+		{CodeUnavailable, http.StatusServiceUnavailable, codes.Unavailable},
+		{Code("unknown"), http.StatusInternalServerError, codes.Internal},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.code), func(t *testing.T) {
+			assert.Equal(t, test.httpStatus, test.code.HTTPStatus())
+			assert.Equal(t, test.grpcCode, test.code.GRPCCode())
+		})
+	}
+
+	assert.Equal(t, CodeNotFound, CodeFromGRPC(codes.NotFound))
+	assert.Equal(t, CodeBadRequest, CodeFromGRPC(codes.InvalidArgument))
+	assert.Equal(t, CodeInternal, CodeFromGRPC(codes.DataLoss))
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("event not found")
+	err := fmt.Errorf("get event: %w", Wrap(CodeNotFound, cause))
+
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, CodeNotFound, CodeOf(err))
+	assert.Equal(t, CodeInternal, CodeOf(cause))
+	assert.Equal(t, "get event: event not found", err.Error())
+}
+
+func TestValidation(t *testing.T) {
+	violations := []FieldViolation{{Field: "title", Description: "title is required"}}
+	err := fmt.Errorf("create event: %w", Validation(violations))
+
+	assert.Equal(t, CodeValidation, CodeOf(err))
+	assert.Equal(t, violations, FieldsOf(err))
+	assert.Nil(t, FieldsOf(errors.New("other")))
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+var ErrUsage = errors.New("usage: config check [FILE] | config env")
+
+// Command runs "config" subcommand of the service: "check" validates the file without starting
+// the service, "env" lists environment variables that override keys of the file.
+func Command(out io.Writer, args []string, path string, conf Validator) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch {
+	case args[0] == "check" && len(args) <= 2:
+		if len(args) == 2 {
+			path = args[1]
+		}
+
+		if err := Load(path, conf); err != nil {
+			var errs Errors
+			if !errors.As(err, &errs) {
+				return err
+			}
+
+			fmt.Fprintf(out, "config %s is invalid:\n", path)
+			for _, err := range errs {
+				fmt.Fprintf(out, "  %s\n", err)
+			}
+			return fmt.Errorf("%d problems found", len(errs))
+		}
+
+		fmt.Fprintf(out, "config %s is valid\n", path)
+		return nil
+	case args[0] == "env" && len(args) == 1:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tENV\tDEFAULT")
+		for _, key := range Keys(conf) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, strings.Join(key.Env, ", "), key.Default)
+		}
+		return w.Flush()
+	default:
+		return ErrUsage
+	}
+}
@@ -0,0 +1,316 @@
+// Package config loads TOML configuration of services into typed sections.
+//
+// Keys are taken from mapstructure tags of the config struct, default values from
+// `default` tags. Every key may be overridden by environment variable named as the key
+// in upper case with dots replaced by underscores, e.g. DB_HOST for db.host. Secret keys
+// (tag `secret` names the key with path to the file) may be read from files, e.g.
+// db.passwordFile or DB_PASSWORD_FILE for db.password. Renamed keys keep the old name
+// in `deprecated` tag, the old key and its variable are still read. After loading every section
+// that implements Validator is checked, so all problems of the file are reported at once.
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// Validator is implemented by the config and by its sections.
+type Validator interface {
+	Validate() error
+}
+
+// Defaulter is implemented by the config when defaults differ between instances of the same section,
+// e.g. ports of HTTP and GRPC servers. Keys are the same as in the file.
+type Defaulter interface {
+	Defaults() map[string]any
+}
+
+// Error is a problem with the key of the config.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Invalid reports a problem with the key, nil error means the key is valid.
+// Keys of sections are prefixed with the name of the section by Load.
+func Invalid(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return nest(key, err)
+}
+
+// Errors are all problems of the config.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Key describes key of the config for documentation.
+type Key struct {
+	Name    string
+	Env     []string
+	Default string
+	// SecretFile is the key with path to file with the value.
+	SecretFile string
+	// Deprecated is the old name of the key, it is used when the key is not set.
+	Deprecated string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Load reads the file into conf and validates it.
+func Load(path string, conf Validator) error {
+	keys := Keys(conf)
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+
+	for _, key := range keys {
+		if err := v.BindEnv(append([]string{key.Name}, key.Env...)...); err != nil {
+			return err
+		}
+		if key.Default != "" {
+			v.SetDefault(key.Name, key.Default)
+		}
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("couldn't load config: %w", err)
+	}
+
+	deprecated := readDeprecated(v, keys)
+
+	if err := readSecrets(v, keys); err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	var meta mapstructure.Metadata
+	if err := v.Unmarshal(conf, func(dc *mapstructure.DecoderConfig) { dc.Metadata = &meta }); err != nil {
+		return fmt.Errorf("couldn't read config %s: %w", path, err)
+	}
+
+	// unknown keys are errors, so typos are not ignored silently.
+	var unknown []string
+	for _, key := range meta.Unused {
+		if !deprecated[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("couldn't read config %s: unknown keys: %s", path, strings.Join(unknown, ", "))
+	}
+
+	errs := validate(reflect.ValueOf(conf).Elem(), "")
+	if err := conf.Validate(); err != nil {
+		errs = append(errs, flatten(err)...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config %s: %w", path, Errors(errs))
+	}
+
+	return nil
+}
+
+// Keys lists keys of the config in order of fields.
+func Keys(conf any) []Key {
+	keys := walk(reflect.TypeOf(conf).Elem(), "")
+
+	var defaults map[string]any
+	if d, ok := conf.(Defaulter); ok {
+		defaults = d.Defaults()
+	}
+
+	// file with secret may be set by env variable of the secret with _FILE suffix.
+	envs := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		envs[key.Name] = key.Env
+	}
+	for i := range keys {
+		if value, ok := defaults[keys[i].Name]; ok {
+			keys[i].Default = fmt.Sprint(value)
+		}
+		for _, key := range keys {
+			if key.SecretFile == keys[i].Name {
+				keys[i].Env = append(keys[i].Env, envs[key.Name][0]+"_FILE")
+			}
+		}
+	}
+
+	return keys
+}
+
+func walk(t reflect.Type, prefix string) []Key {
+	var keys []Key
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := fieldKey(field, prefix)
+		if isSection(field) {
+			keys = append(keys, walk(field.Type, name)...)
+			continue
+		}
+
+		key := Key{
+			Name:    name,
+			Env:     []string{strings.ToUpper(strings.ReplaceAll(name, ".", "_"))},
+			Default: field.Tag.Get("default"),
+		}
+		if secret := field.Tag.Get("secret"); secret != "" {
+			key.SecretFile = join(prefix, secret)
+		}
+		if old := field.Tag.Get("deprecated"); old != "" {
+			key.Deprecated = join(prefix, old)
+			key.Env = append(key.Env, strings.ToUpper(strings.ReplaceAll(key.Deprecated, ".", "_")))
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// fieldKey is the key of the field as mapstructure sees it, squashed sections have keys of the parent.
+func fieldKey(field reflect.StructField, prefix string) string {
+	tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+	for _, option := range tag[1:] {
+		if option == "squash" {
+			return prefix
+		}
+	}
+	if tag[0] != "" {
+		return join(prefix, tag[0])
+	}
+
+	return join(prefix, field.Name)
+}
+
+func isSection(field reflect.StructField) bool {
+	return field.Type.Kind() == reflect.Struct && field.Type != timeType
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+// readDeprecated uses values of old keys for keys that are not in the file,
+// variables of both keys still override them. It returns old keys known to viper.
+func readDeprecated(v *viper.Viper, keys []Key) map[string]bool {
+	deprecated := make(map[string]bool)
+	for _, key := range keys {
+		if key.Deprecated == "" {
+			continue
+		}
+
+		// viper keeps keys in lower case.
+		deprecated[strings.ToLower(key.Deprecated)] = true
+		if v.InConfig(key.Deprecated) && !v.InConfig(key.Name) {
+			v.SetDefault(key.Name, v.Get(key.Deprecated))
+		}
+	}
+
+	return deprecated
+}
+
+// readSecrets replaces secrets with content of their files, files win over values.
+func readSecrets(v *viper.Viper, keys []Key) error {
+	for _, key := range keys {
+		if key.SecretFile == "" {
+			continue
+		}
+
+		path := v.GetString(key.SecretFile)
+		if path == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return &Error{Key: key.SecretFile, Err: err}
+		}
+		v.Set(key.Name, strings.TrimRight(string(data), "\r\n"))
+	}
+
+	return nil
+}
+
+// validate checks nested sections, their errors are prefixed with keys of sections.
+func validate(v reflect.Value, prefix string) []error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || !isSection(field) {
+			continue
+		}
+
+		name := fieldKey(field, prefix)
+		section := v.Field(i)
+		errs = append(errs, validate(section, name)...)
+		if validator, ok := section.Addr().Interface().(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				for _, err := range flatten(err) {
+					errs = append(errs, nest(name, err))
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+func flatten(err error) []error {
+	//nolint:errorlint // only errors joined at the top are split.
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var res []error
+		for _, err := range joined.Unwrap() {
+			res = append(res, flatten(err)...)
+		}
+		return res
+	}
+
+	return []error{err}
+}
+
+func nest(prefix string, err error) error {
+	//nolint:errorlint // wrapped errors of keys keep their context.
+	if keyErr, ok := err.(*Error); ok {
+		return &Error{Key: join(prefix, keyErr.Key), Err: keyErr.Err}
+	}
+	if prefix == "" {
+		return err
+	}
+
+	return &Error{Key: prefix, Err: err}
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSection struct {
+	Interval time.Duration `mapstructure:"interval" default:"1m"`
+	Count    int           `mapstructure:"count"`
+}
+
+func (c *testSection) Validate() error {
+	return Positive("count", c.Count)
+}
+
+type testConfig struct {
+	Logger  LoggerConf  `mapstructure:"logger"`
+	Storage StorageConf `mapstructure:"storage"`
+	DB      DBConf      `mapstructure:"db"`
+	Server  ServerConf  `mapstructure:"server"`
+	Section testSection `mapstructure:"section"`
+}
+
+func (c *testConfig) Defaults() map[string]any {
+	return map[string]any{"server.port": 8080}
+}
+
+func (c *testConfig) Validate() error {
+	return CheckStorage(c.Storage, c.DB)
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoadDefaultsAndEnv(t *testing.T) {
+	path := writeConfig(t, `
+[section]
+count = 3
+`)
+	// env overrides keys that are not in the file too.
+	t.Setenv("LOGGER_LEVEL", "debug")
+	t.Setenv("SERVER_HOST", "0.0.0.0")
+
+	var conf testConfig
+	require.NoError(t, Load(path, &conf))
+
+	require.Equal(t, "debug", conf.Logger.Level)
+	require.Equal(t, StorageMemory, conf.Storage.Driver)
+	require.Equal(t, 5432, conf.DB.Port)
+	require.Equal(t, "0.0.0.0", conf.Server.Host)
+	require.Equal(t, 8080, conf.Server.Port)
+	require.Equal(t, time.Minute, conf.Section.Interval)
+	require.Equal(t, time.Minute, conf.Server.TLS.CertReloadInterval)
+	require.Equal(t, 3, conf.Section.Count)
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(secret, []byte("s3cret\n"), 0o600))
+
+	path := writeConfig(t, `
+[db]
+password = "inline"
+passwordFile = "`+secret+`"
+[section]
+count = 1
+`)
+
+	var conf testConfig
+	require.NoError(t, Load(path, &conf))
+	require.Equal(t, "s3cret", conf.DB.Password)
+
+	// docker style variable with path of the file
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+	err := Load(path, &testConfig{})
+	var keyErr *Error
+	require.ErrorAs(t, err, &keyErr)
+	require.Equal(t, "db.passwordFile", keyErr.Key)
+}
+
+func TestLoadErrors(t *testing.T) {
+	path := writeConfig(t, `
+[logger]
+level = "loud"
+[storage]
+driver = "postgres"
+[db]
+host = ""
+[server]
+port = 70000
+keyFile = "server.key"
+`)
+
+	err := Load(path, &testConfig{})
+	var errs Errors
+	require.ErrorAs(t, err, &errs)
+
+	keys := make([]string, 0, len(errs))
+	for _, err := range errs {
+		var keyErr *Error
+		require.ErrorAs(t, err, &keyErr)
+		keys = append(keys, keyErr.Key)
+	}
+	require.ElementsMatch(t, []string{
+		"logger.level", "server.port", "server", "section.count", "db.host", "db.name", "db.username",
+	}, keys)
+	require.ErrorIs(t, err, ErrRequired)
+	require.ErrorIs(t, err, ErrPort)
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := writeConfig(t, `
+[section]
+count = 1
+cuont = 2
+`)
+
+	err := Load(path, &testConfig{})
+	require.ErrorContains(t, err, "cuont")
+}
+
+func TestLoadDeprecatedKey(t *testing.T) {
+	path := writeConfig(t, `
+[storage]
+migrations_path = "./old"
+[section]
+count = 1
+`)
+
+	var conf testConfig
+	require.NoError(t, Load(path, &conf))
+	require.Equal(t, "./old", conf.Storage.MigrationsPath)
+
+	// variables of both names override the file.
+	t.Setenv("STORAGE_MIGRATIONS_PATH", "./env")
+	require.NoError(t, Load(path, &conf))
+	require.Equal(t, "./env", conf.Storage.MigrationsPath)
+
+	// the new key wins over the old one.
+	path = writeConfig(t, `
+[storage]
+migrationsPath = "./new"
+migrations_path = "./old"
+[section]
+count = 1
+`)
+	t.Setenv("STORAGE_MIGRATIONS_PATH", "")
+	require.NoError(t, Load(path, &conf))
+	require.Equal(t, "./new", conf.Storage.MigrationsPath)
+}
+
+func TestCommand(t *testing.T) {
+	valid := writeConfig(t, "[section]\ncount = 1\n")
+	invalid := writeConfig(t, "[section]\ncount = 0\n")
+
+	var out bytes.Buffer
+	require.NoError(t, Command(&out, []string{"check"}, valid, &testConfig{}))
+	require.Contains(t, out.String(), "is valid")
+
+	out.Reset()
+	require.Error(t, Command(&out, []string{"check", invalid}, valid, &testConfig{}))
+	require.Contains(t, out.String(), "section.count: should be positive")
+
+	out.Reset()
+	require.NoError(t, Command(&out, []string{"env"}, valid, &testConfig{}))
+	require.Contains(t, out.String(), "DB_PASSWORD_FILE")
+	require.Contains(t, out.String(), "STORAGE_MIGRATIONSPATH, STORAGE_MIGRATIONS_PATH")
+	require.Contains(t, out.String(), "8080")
+
+	require.True(t, errors.Is(Command(&out, []string{"show"}, valid, &testConfig{}), ErrUsage))
+}
+
+func TestDBConfDSN(t *testing.T) {
+	db := DBConf{
+		Host:           "primary",
+		Port:           5432,
+		Name:           "calendar",
+		Username:       "app",
+		Password:       `it's \ secret`,
+		SSLMode:        SSLVerifyFull,
+		SSLRootCert:    "/certs/ca.pem",
+		ConnectTimeout: 1500 * time.Millisecond,
+	}
+
+	require.Equal(t,
+		`host='primary' port='5432' user='app' password='it\'s \\ secret' dbname='calendar' `+
+			`sslmode='verify-full' sslrootcert='/certs/ca.pem' connect_timeout='2'`,
+		db.DSN(),
+	)
+	require.Empty(t, db.ReplicaDSN())
+
+	db.ReplicaHost = "replica"
+	require.Contains(t, db.ReplicaDSN(), "host='replica' port='5432'")
+}
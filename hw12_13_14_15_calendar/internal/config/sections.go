@@ -0,0 +1,351 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/logger"
+)
+
+var (
+	ErrRequired    = errors.New("is required")
+	ErrUnknown     = errors.New("unknown value")
+	ErrNotPositive = errors.New("should be positive")
+	ErrNegative    = errors.New("should not be negative")
+	ErrPort        = errors.New("should be in range 1-65535")
+)
+
+const (
+	StoragePostgres = "postgres"
+	StorageBolt     = "bolt"
+	StorageMemory   = "memory"
+
+	BrokerRMQ    = "rmq"
+	BrokerFile   = "file"
+	BrokerMemory = "memory"
+)
+
+type LoggerConf struct {
+	Level string `mapstructure:"level" default:"INFO"`
+	// Path of the log file, empty is stdout.
+	Path string `mapstructure:"path"`
+}
+
+func (c *LoggerConf) Validate() error {
+	return Invalid("level", logger.CheckLevel(c.Level))
+}
+
+// Open returns output of logs, the file is appended and its directory is created.
+func (c *LoggerConf) Open() (io.WriteCloser, error) {
+	if c.Path == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(c.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type ShutdownConf struct {
+	// Timeout is given to components to finish in-flight work on shutdown.
+	Timeout time.Duration `mapstructure:"timeout" default:"15s"`
+}
+
+func (c *ShutdownConf) Validate() error {
+	return Positive("timeout", c.Timeout)
+}
+
+type StorageConf struct {
+	Driver string `mapstructure:"driver" default:"memory"`
+	// MigrationsPath is directory with migrations, empty uses migrations embedded into the binary.
+	MigrationsPath string `mapstructure:"migrationsPath" deprecated:"migrations_path"`
+	// AutoMigrate applies new migrations on start, "calendar migrate" is used when it is disabled.
+	AutoMigrate bool `mapstructure:"autoMigrate" default:"true"`
+	// Path of the database file for bolt driver.
+	Path string `mapstructure:"path"`
+}
+
+func (c *StorageConf) Validate() error {
+	switch c.Driver {
+	case StoragePostgres, StorageMemory:
+		return nil
+	case StorageBolt:
+		return Required("path", c.Path)
+	default:
+		return OneOf("driver", c.Driver, StoragePostgres, StorageBolt, StorageMemory)
+	}
+}
+
+// SSL modes supported by lib/pq.
+const (
+	SSLDisable    = "disable"
+	SSLRequire    = "require"
+	SSLVerifyCA   = "verify-ca"
+	SSLVerifyFull = "verify-full"
+)
+
+type DBConf struct {
+	Host            string `mapstructure:"host" default:"localhost"`
+	Port            int    `mapstructure:"port" default:"5432"`
+	Name            string `mapstructure:"name"`
+	Username        string `mapstructure:"username"`
+	Password        string `mapstructure:"password" secret:"passwordFile"`
+	PasswordFile    string `mapstructure:"passwordFile"`
+	ApplicationName string `mapstructure:"applicationName"`
+	// ConnectTimeout of a new connection, 0 waits indefinitely.
+	ConnectTimeout time.Duration `mapstructure:"connectTimeout" default:"5s"`
+
+	SSLMode     string `mapstructure:"sslMode" default:"disable"`
+	SSLRootCert string `mapstructure:"sslRootCert"`
+	SSLCert     string `mapstructure:"sslCert"`
+	SSLKey      string `mapstructure:"sslKey"`
+
+	// ReplicaHost enables reading of events from the replica, other settings are shared with the primary.
+	ReplicaHost string `mapstructure:"replicaHost"`
+	// ReplicaPort is the port of the primary when it is 0.
+	ReplicaPort int `mapstructure:"replicaPort"`
+
+	// Zero values of the pool keep defaults of database/sql.
+	MaxOpenConns    int           `mapstructure:"maxOpenConns" default:"20"`
+	MaxIdleConns    int           `mapstructure:"maxIdleConns" default:"5"`
+	ConnMaxLifetime time.Duration `mapstructure:"connMaxLifetime" default:"30m"`
+	ConnMaxIdleTime time.Duration `mapstructure:"connMaxIdleTime" default:"5m"`
+	// QueryTimeout is used for queries of requests without deadline, 0 disables it.
+	QueryTimeout time.Duration `mapstructure:"queryTimeout" default:"10s"`
+}
+
+func (c *DBConf) Validate() error {
+	errs := []error{
+		Port("port", c.Port),
+		OneOf("sslMode", c.SSLMode, SSLDisable, SSLRequire, SSLVerifyCA, SSLVerifyFull),
+		NotNegative("connectTimeout", c.ConnectTimeout),
+		NotNegative("maxOpenConns", c.MaxOpenConns),
+		NotNegative("maxIdleConns", c.MaxIdleConns),
+		NotNegative("connMaxLifetime", c.ConnMaxLifetime),
+		NotNegative("connMaxIdleTime", c.ConnMaxIdleTime),
+		NotNegative("queryTimeout", c.QueryTimeout),
+	}
+	if c.ReplicaPort != 0 {
+		errs = append(errs, Port("replicaPort", c.ReplicaPort))
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		errs = append(errs, errors.New("both sslCert and sslKey are required for client certificate"))
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, Invalid("maxIdleConns", errors.New("should not exceed maxOpenConns")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// DSN is connection string of the primary for lib/pq.
+func (c *DBConf) DSN() string {
+	return c.dsn(c.Host, c.Port)
+}
+
+// ReplicaDSN is connection string of the read replica, empty when it is not set.
+func (c *DBConf) ReplicaDSN() string {
+	if c.ReplicaHost == "" {
+		return ""
+	}
+
+	port := c.ReplicaPort
+	if port == 0 {
+		port = c.Port
+	}
+
+	return c.dsn(c.ReplicaHost, port)
+}
+
+func (c *DBConf) dsn(host string, port int) string {
+	params := [][2]string{
+		{"host", host},
+		{"port", strconv.Itoa(port)},
+		{"user", c.Username},
+		{"password", c.Password},
+		{"dbname", c.Name},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+		{"application_name", c.ApplicationName},
+	}
+	if c.ConnectTimeout > 0 {
+		// lib/pq takes whole seconds, round up so small timeout is not disabled.
+		seconds := (c.ConnectTimeout + time.Second - 1) / time.Second
+		params = append(params, [2]string{"connect_timeout", strconv.Itoa(int(seconds))})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		parts = append(parts, p[0]+"="+quoteDSN(p[1]))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// quoteDSN quotes the value of key=value connection string, so passwords may contain spaces and quotes.
+func quoteDSN(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// CheckStorage checks settings of the database when it is used by the storage.
+func CheckStorage(storage StorageConf, db DBConf) error {
+	if storage.Driver != StoragePostgres {
+		return nil
+	}
+
+	return errors.Join(
+		Required("db.host", db.Host),
+		Required("db.name", db.Name),
+		Required("db.username", db.Username),
+	)
+}
+
+type BrokerConf struct {
+	Driver string `mapstructure:"driver" default:"rmq"`
+	// Path is directory of the queue for file driver.
+	Path string `mapstructure:"path"`
+	// Size of the queue for memory driver.
+	Size int `mapstructure:"size" default:"1024"`
+}
+
+func (c *BrokerConf) Validate() error {
+	switch c.Driver {
+	case BrokerRMQ:
+		return nil
+	case BrokerFile:
+		return Required("path", c.Path)
+	case BrokerMemory:
+		return Positive("size", c.Size)
+	default:
+		return OneOf("driver", c.Driver, BrokerRMQ, BrokerFile, BrokerMemory)
+	}
+}
+
+type ExchangeConf struct {
+	Name       string `mapstructure:"name"`
+	Type       string `mapstructure:"type" default:"fanout"`
+	QueueName  string `mapstructure:"queueName"`
+	BindingKey string `mapstructure:"bindingKey"`
+}
+
+type RMQConf struct {
+	URI             string        `mapstructure:"uri" secret:"uriFile"`
+	URIFile         string        `mapstructure:"uriFile"`
+	ConsumerTag     string        `mapstructure:"consumerTag"`
+	MaxElapsedTime  time.Duration `mapstructure:"maxElapsedTime" default:"1m"`
+	InitialInterval time.Duration `mapstructure:"initialInterval" default:"1s"`
+	Multiplier      float64       `mapstructure:"multiplier" default:"2"`
+	MaxInterval     time.Duration `mapstructure:"maxInterval" default:"15s"`
+	PublishTimeout  time.Duration `mapstructure:"publishTimeout" default:"5s"`
+	Mandatory       bool          `mapstructure:"mandatory"`
+	Exchange        ExchangeConf  `mapstructure:"exchange"`
+}
+
+// CheckBroker checks settings of RabbitMQ when it is used by the broker.
+func CheckBroker(broker BrokerConf, rmq RMQConf) error {
+	if broker.Driver != BrokerRMQ {
+		return nil
+	}
+
+	return errors.Join(
+		Required("rmq.uri", rmq.URI),
+		Required("rmq.exchange.name", rmq.Exchange.Name),
+	)
+}
+
+type ServerConf struct {
+	Host string  `mapstructure:"host"`
+	Port int     `mapstructure:"port"`
+	TLS  TLSConf `mapstructure:",squash"`
+	// TrustUserHeader takes the user of the request from the header of every client, it is only for
+	// servers behind a proxy that authenticates users. Otherwise only services verified by mutual TLS set it.
+	TrustUserHeader bool `mapstructure:"trustUserHeader"`
+}
+
+func (c *ServerConf) Validate() error {
+	return Port("port", c.Port)
+}
+
+// TLSConf enables TLS when certificate and key are set, client CA enables mutual TLS.
+type TLSConf struct {
+	CertFile           string        `mapstructure:"certFile"`
+	KeyFile            string        `mapstructure:"keyFile"`
+	ClientCAFile       string        `mapstructure:"clientCAFile"`
+	CertReloadInterval time.Duration `mapstructure:"certReloadInterval" default:"1m"`
+}
+
+func (c *TLSConf) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("both certFile and keyFile are required for TLS")
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		return errors.New("mutual TLS requires certFile and keyFile")
+	}
+
+	return NotNegative("certReloadInterval", c.CertReloadInterval)
+}
+
+// Required checks that the value is set.
+func Required(key, value string) error {
+	if value == "" {
+		return &Error{Key: key, Err: ErrRequired}
+	}
+
+	return nil
+}
+
+// OneOf checks that the value is one of allowed.
+func OneOf(key, value string, allowed ...string) error {
+	for _, v := range allowed {
+		if value == v {
+			return nil
+		}
+	}
+
+	return &Error{Key: key, Err: fmt.Errorf("%w %q, expected one of %s", ErrUnknown, value, strings.Join(allowed, ", "))}
+}
+
+func Port(key string, value int) error {
+	if value <= 0 || value > 65535 {
+		return &Error{Key: key, Err: ErrPort}
+	}
+
+	return nil
+}
+
+func Positive[T int | time.Duration](key string, value T) error {
+	if value <= 0 {
+		return &Error{Key: key, Err: ErrNotPositive}
+	}
+
+	return nil
+}
+
+func NotNegative[T int | time.Duration](key string, value T) error {
+	if value < 0 {
+		return &Error{Key: key, Err: ErrNegative}
+	}
+
+	return nil
+}
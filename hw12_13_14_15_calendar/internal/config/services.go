@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/cron"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/jobs"
+)
+
+// Sections of the scheduler and the sender, they are shared with all-in-one mode of the calendar.
+
+type SchedulerConf struct {
+	RunFrequencyInterval   time.Duration `mapstructure:"runFrequencyInterval" default:"1m"`
+	TimeForRemoveOldEvents time.Duration `mapstructure:"timeForRemoveOldEvents" default:"8760h"`
+	InstanceID             string        `mapstructure:"instanceId"`
+	ClaimLease             time.Duration `mapstructure:"claimLease" default:"1m"`
+	ClaimBatchSize         int           `mapstructure:"claimBatchSize" default:"100"`
+	HistorySize            int           `mapstructure:"historySize" default:"20"`
+	MetricsAddr            string        `mapstructure:"metricsAddr"`
+}
+
+func (c *SchedulerConf) Validate() error {
+	return errors.Join(
+		Positive("runFrequencyInterval", c.RunFrequencyInterval),
+		Positive("timeForRemoveOldEvents", c.TimeForRemoveOldEvents),
+		Positive("claimLease", c.ClaimLease),
+		Positive("claimBatchSize", c.ClaimBatchSize),
+		NotNegative("historySize", c.HistorySize),
+	)
+}
+
+type JobsConf struct {
+	Notifications JobConf `mapstructure:"notifications"`
+	Cleanup       JobConf `mapstructure:"cleanup"`
+	Agenda        JobConf `mapstructure:"agenda"`
+}
+
+// JobsDefaults are defaults of the [jobs] section for Defaulter, sections of jobs share the type.
+func JobsDefaults() map[string]any {
+	return map[string]any{
+		"jobs.notifications.interval": time.Minute,
+		"jobs.cleanup.interval":       time.Hour,
+	}
+}
+
+// JobConf sets either cron schedule or interval. Job without both runs only when triggered.
+type JobConf struct {
+	Schedule    string        `mapstructure:"schedule"`
+	Interval    time.Duration `mapstructure:"interval"`
+	Timezone    string        `mapstructure:"timezone"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	Concurrency int           `mapstructure:"concurrency"`
+	Jitter      time.Duration `mapstructure:"jitter"`
+}
+
+func (c *JobConf) Validate() error {
+	var errs []error
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, Invalid("timezone", err))
+	}
+	if c.Schedule != "" {
+		if _, err := cron.Parse(c.Schedule); err != nil {
+			errs = append(errs, Invalid("schedule", err))
+		}
+	}
+
+	return errors.Join(append(errs,
+		NotNegative("interval", c.Interval),
+		NotNegative("timeout", c.Timeout),
+		NotNegative("concurrency", c.Concurrency),
+		NotNegative("jitter", c.Jitter),
+	)...)
+}
+
+// Job builds job from the section, so wrong schedule is found on start, not at the time of the first run.
+func (c *JobConf) Job(name string, run jobs.Func) (jobs.Job, *time.Location, error) {
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return jobs.Job{}, nil, fmt.Errorf("unknown time zone of job %s: %w", name, err)
+	}
+
+	var schedule jobs.Schedule
+	switch {
+	case c.Schedule != "":
+		spec, err := cron.Parse(c.Schedule)
+		if err != nil {
+			return jobs.Job{}, nil, fmt.Errorf("job %s: %w", name, err)
+		}
+		schedule = jobs.In(spec, location)
+	case c.Interval > 0:
+		schedule = jobs.Every(c.Interval)
+	default:
+		schedule = jobs.Never{}
+	}
+
+	return jobs.Job{
+		Name:        name,
+		Schedule:    schedule,
+		Run:         run,
+		Timeout:     c.Timeout,
+		Concurrency: c.Concurrency,
+		Jitter:      c.Jitter,
+	}, location, nil
+}
+
+type SenderConf struct {
+	Threads       int           `mapstructure:"threads" default:"1"`
+	Channel       string        `mapstructure:"channel" default:"email"`
+	Locale        string        `mapstructure:"locale" default:"ru"`
+	Timezone      string        `mapstructure:"timezone"`
+	FlushInterval time.Duration `mapstructure:"flushInterval" default:"1m"`
+}
+
+func (c *SenderConf) Validate() error {
+	var errs []error
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, Invalid("timezone", err))
+	}
+
+	return errors.Join(append(errs,
+		Positive("threads", c.Threads),
+		Positive("flushInterval", c.FlushInterval),
+		Required("channel", c.Channel),
+		Required("locale", c.Locale),
+	)...)
+}
+
+type TemplatesConf struct {
+	Path           string        `mapstructure:"path" default:"./templates"`
+	ReloadInterval time.Duration `mapstructure:"reloadInterval"`
+}
+
+func (c *TemplatesConf) Validate() error {
+	return NotNegative("reloadInterval", c.ReloadInterval)
+}
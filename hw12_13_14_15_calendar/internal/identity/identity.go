@@ -0,0 +1,126 @@
+// Package identity passes the client verified by mutual TLS from transport layers to App,
+// so App may use it for authorization without knowing about HTTP or gRPC.
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"strconv"
+	"strings"
+)
+
+const (
+	// UserURIPrefix marks the URI of the client certificate that names the user, e.g. urn:calendar:user:42.
+	UserURIPrefix = "urn:calendar:user:"
+	// ServiceURI marks the client certificate of a service that acts on behalf of users.
+	ServiceURI = "urn:calendar:service"
+	// UserHeader names the user of the request when the client is not a user itself. It is honored
+	// only for services, see ServiceURI, and for servers behind a proxy that authenticates users.
+	UserHeader = "x-user-id"
+)
+
+// Identity is taken from the verified client certificate.
+type Identity struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	Emails       []string
+	URIs         []string
+	// SerialNumber with issuer identifies the certificate, e.g. for revocation.
+	SerialNumber string
+	Issuer       string
+	// UserID is the user the client acts as, zero when the user is unknown.
+	UserID int64
+	// Service may name the user of the request in UserHeader.
+	Service bool
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns nil when the client is not verified, e.g. without mutual TLS.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(contextKey{}).(*Identity)
+	return id
+}
+
+// FromConnectionState returns identity of the client only when its certificate is verified.
+func FromConnectionState(state tls.ConnectionState) *Identity {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return FromCertificate(state.VerifiedChains[0][0])
+}
+
+func FromCertificate(cert *x509.Certificate) *Identity {
+	id := &Identity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		Emails:       cert.EmailAddresses,
+		SerialNumber: cert.SerialNumber.String(),
+		Issuer:       cert.Issuer.CommonName,
+	}
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+		if uri.String() == ServiceURI {
+			id.Service = true
+		}
+		if !strings.HasPrefix(uri.String(), UserURIPrefix) {
+			continue
+		}
+		if userID, ok := parseUser(strings.TrimPrefix(uri.String(), UserURIPrefix)); ok {
+			id.UserID = userID
+		}
+	}
+
+	return id
+}
+
+// WithUser sets the user of UserHeader when the client is a service or trustHeader is set for the server
+// behind a proxy, anonymous clients get the identity with the user only. The header is ignored when
+// the certificate names the user itself, for other clients and when its value is wrong.
+func WithUser(id *Identity, header string, trustHeader bool) *Identity {
+	if id != nil && id.UserID != 0 {
+		return id
+	}
+	if !trustHeader && (id == nil || !id.Service) {
+		return id
+	}
+
+	userID, ok := parseUser(header)
+	if !ok {
+		return id
+	}
+
+	res := &Identity{}
+	if id != nil {
+		*res = *id
+	}
+	res.UserID = userID
+
+	return res
+}
+
+func parseUser(s string) (int64, bool) {
+	userID, err := strconv.ParseInt(s, 10, 64)
+	return userID, err == nil && userID > 0
+}
+
+func (id *Identity) String() string {
+	switch {
+	case id == nil:
+		return "anonymous"
+	case id.CommonName == "":
+		return "user " + strconv.FormatInt(id.UserID, 10)
+	case id.UserID != 0:
+		return id.CommonName + " (user " + strconv.FormatInt(id.UserID, 10) + ")"
+	}
+
+	return id.CommonName
+}
@@ -0,0 +1,71 @@
+package identity
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func certificate(t *testing.T, uris ...string) *x509.Certificate {
+	t.Helper()
+
+	cert := &x509.Certificate{
+		Subject:      pkix.Name{CommonName: "client"},
+		SerialNumber: big.NewInt(1),
+	}
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		cert.URIs = append(cert.URIs, u)
+	}
+
+	return cert
+}
+
+func TestFromCertificate(t *testing.T) {
+	id := FromCertificate(certificate(t, "urn:calendar:user:42"))
+	require.Equal(t, int64(42), id.UserID)
+	require.False(t, id.Service)
+
+	id = FromCertificate(certificate(t, ServiceURI))
+	require.Zero(t, id.UserID)
+	require.True(t, id.Service)
+}
+
+func TestWithUser(t *testing.T) {
+	service := FromCertificate(certificate(t, ServiceURI))
+	client := FromCertificate(certificate(t))
+	user := FromCertificate(certificate(t, "urn:calendar:user:42"))
+
+	tests := []struct {
+		name        string
+		id          *Identity
+		header      string
+		trustHeader bool
+		userID      int64
+	}{
+		{name: "anonymous", header: "7"},
+		{name: "anonymous behind proxy", header: "7", trustHeader: true, userID: 7},
+		{name: "client without service mark", id: client, header: "7"},
+		{name: "service", id: service, header: "7", userID: 7},
+		{name: "user of certificate wins", id: user, header: "7", trustHeader: true, userID: 42},
+		{name: "wrong header", id: service, header: "-7"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			id := WithUser(tc.id, tc.header, tc.trustHeader)
+			if tc.userID == 0 {
+				require.True(t, id == nil || id.UserID == 0)
+				return
+			}
+
+			require.Equal(t, tc.userID, id.UserID)
+		})
+	}
+}
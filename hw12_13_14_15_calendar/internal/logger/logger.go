@@ -1,12 +1,16 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+var ErrUnknownLevel = errors.New("unknown log level")
+
 type logLevel struct {
 	label string
 	value int
@@ -32,25 +36,50 @@ var logLevels = map[string]logLevel{
 }
 
 type Logger struct {
-	level   logLevel
+	// level is changed on reload of config while other goroutines write logs.
+	level   atomic.Pointer[logLevel]
 	writeTo io.Writer
 }
 
 func New(level string, writeTo io.Writer) *Logger {
-	level = strings.TrimSpace(strings.ToLower(level))
+	l := &Logger{writeTo: writeTo}
+	if err := l.SetLevel(level); err != nil {
+		debug := logLevels["debug"]
+		l.level.Store(&debug)
+	}
 
-	targetLvl, found := logLevels[level]
+	return l
+}
+
+// SetLevel changes level of the working logger. Unknown level is rejected and the current one is kept.
+func (l *Logger) SetLevel(level string) error {
+	targetLvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	l.level.Store(&targetLvl)
+	return nil
+}
+
+// CheckLevel allows to validate config before applying it.
+func CheckLevel(level string) error {
+	_, err := parseLevel(level)
+	return err
+}
 
+func parseLevel(level string) (logLevel, error) {
+	targetLvl, found := logLevels[strings.TrimSpace(strings.ToLower(level))]
 	if !found {
-		targetLvl = logLevels["debug"]
+		return logLevel{}, fmt.Errorf("%w: %q", ErrUnknownLevel, level)
 	}
 
-	return &Logger{targetLvl, writeTo}
+	return targetLvl, nil
 }
 
-func (l Logger) core(level logLevel, msg string, params ...any) {
+func (l *Logger) core(level logLevel, msg string, params ...any) {
 	// do not write anything if request level less then in config.
-	if level.value < l.level.value {
+	if level.value < l.level.Load().value {
 		return
 	}
 
@@ -65,22 +94,22 @@ func (l Logger) core(level logLevel, msg string, params ...any) {
 	l.writeTo.Write([]byte(buildedString.String()))
 }
 
-func (l Logger) Error(msg string, params ...any) {
+func (l *Logger) Error(msg string, params ...any) {
 	l.core(logLevels["error"], msg, params...)
 }
 
-func (l Logger) Warning(msg string, params ...any) {
+func (l *Logger) Warning(msg string, params ...any) {
 	l.core(logLevels["warning"], msg, params...)
 }
 
-func (l Logger) Info(msg string, params ...any) {
+func (l *Logger) Info(msg string, params ...any) {
 	l.core(logLevels["info"], msg, params...)
 }
 
-func (l Logger) Debug(msg string, params ...any) {
+func (l *Logger) Debug(msg string, params ...any) {
 	l.core(logLevels["debug"], msg, params...)
 }
 
-func (l Logger) Log(msg string, params ...any) {
-	l.core(l.level, msg, params...)
+func (l *Logger) Log(msg string, params ...any) {
+	l.core(*l.level.Load(), msg, params...)
 }
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -97,3 +98,31 @@ func TestLogger(t *testing.T) {
 		}
 	})
 }
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New("error", &buf)
+	logger.Info("Some test INFO log here")
+	if buf.Len() != 0 {
+		t.Errorf("Expected empty message, but got: '%s'", buf.String())
+	}
+
+	if err := logger.SetLevel(" Info "); err != nil {
+		t.Fatalf("Expected no error, but got: %s", err)
+	}
+	logger.Info("Some test INFO log here")
+	if !strings.Contains(buf.String(), "[INFO]") {
+		t.Errorf("Expected message has [INFO], but got: '%s'", buf.String())
+	}
+
+	// unknown level is rejected, the current one is kept.
+	if err := logger.SetLevel("verbose"); !errors.Is(err, ErrUnknownLevel) {
+		t.Errorf("Expected ErrUnknownLevel, but got: %v", err)
+	}
+	buf.Reset()
+	logger.Debug("Some test DEBUG log here")
+	if buf.Len() != 0 {
+		t.Errorf("Expected empty message, but got: '%s'", buf.String())
+	}
+}
@@ -0,0 +1,169 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	roleToPb = map[storage.Role]pb.Role{
+		storage.RoleFreeBusy: pb.Role_ROLE_FREE_BUSY,
+		storage.RoleRead:     pb.Role_ROLE_READ,
+		storage.RoleWrite:    pb.Role_ROLE_WRITE,
+	}
+	roleFromPb = map[pb.Role]storage.Role{
+		pb.Role_ROLE_FREE_BUSY: storage.RoleFreeBusy,
+		pb.Role_ROLE_READ:      storage.RoleRead,
+		pb.Role_ROLE_WRITE:     storage.RoleWrite,
+	}
+)
+
+func (s *Server) CreateCalendar(ctx context.Context, req *pb.CalendarRequest) (*pb.CalendarResponse, error) {
+	calendar := fromPbCalendar(req.Calendar)
+
+	if err := s.app.CreateCalendar(ctx, calendar); err != nil {
+		return nil, err
+	}
+
+	return &pb.CalendarResponse{Calendar: toPbCalendar(calendar)}, nil
+}
+
+func (s *Server) UpdateCalendar(ctx context.Context, req *pb.CalendarUpdateRequest) (*pb.CalendarResponse, error) {
+	calendarUUID, err := s.parseCalendarUUID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := fromPbCalendar(req.Calendar)
+	calendar.ID = calendarUUID // id from the path wins over the body
+
+	if err := s.app.UpdateCalendar(ctx, calendar); err != nil {
+		return nil, err
+	}
+
+	return &pb.CalendarResponse{Calendar: toPbCalendar(calendar)}, nil
+}
+
+func (s *Server) DeleteCalendar(ctx context.Context, req *pb.CalendarIdRequest) (*emptypb.Empty, error) {
+	calendarUUID, err := s.parseCalendarUUID(req.Id)
+	if err != nil {
+		return &emptypb.Empty{}, err
+	}
+
+	if err := s.app.DeleteCalendar(ctx, calendarUUID); err != nil {
+		return &emptypb.Empty{}, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetCalendar(ctx context.Context, req *pb.CalendarIdRequest) (*pb.CalendarResponse, error) {
+	calendarUUID, err := s.parseCalendarUUID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := s.app.GetCalendar(ctx, calendarUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CalendarResponse{Calendar: toPbCalendar(calendar)}, nil
+}
+
+func (s *Server) GetCalendars(ctx context.Context, req *pb.UserIdRequest) (*pb.CalendarsResponse, error) {
+	calendars, err := s.app.GetCalendars(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*pb.Calendar, len(calendars))
+	for i, calendar := range calendars {
+		res[i] = toPbCalendar(calendar)
+	}
+
+	return &pb.CalendarsResponse{Calendars: res}, nil
+}
+
+func (s *Server) ShareCalendar(ctx context.Context, req *pb.ShareRequest) (*pb.ShareResponse, error) {
+	calendarUUID, err := s.parseCalendarUUID(req.CalendarId)
+	if err != nil {
+		return nil, err
+	}
+
+	share := &storage.Share{
+		CalendarID: calendarUUID, // calendar and user from the path win over the body
+		UserID:     req.UserId,
+		Role:       roleFromPb[req.Share.GetRole()],
+	}
+
+	if err := s.app.ShareCalendar(ctx, share); err != nil {
+		return nil, err
+	}
+
+	return &pb.ShareResponse{Share: toPbShare(share)}, nil
+}
+
+func (s *Server) UnshareCalendar(ctx context.Context, req *pb.ShareIdRequest) (*emptypb.Empty, error) {
+	calendarUUID, err := s.parseCalendarUUID(req.CalendarId)
+	if err != nil {
+		return &emptypb.Empty{}, err
+	}
+
+	if err := s.app.UnshareCalendar(ctx, calendarUUID, req.UserId); err != nil {
+		return &emptypb.Empty{}, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetShares(ctx context.Context, req *pb.CalendarIdRequest) (*pb.SharesResponse, error) {
+	calendarUUID, err := s.parseCalendarUUID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.app.GetShares(ctx, calendarUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*pb.Share, len(shares))
+	for i, share := range shares {
+		res[i] = toPbShare(share)
+	}
+
+	return &pb.SharesResponse{Shares: res}, nil
+}
+
+// the default flag is managed by storage, so it is not taken from requests.
+func fromPbCalendar(calendar *pb.Calendar) *storage.Calendar {
+	return &storage.Calendar{
+		OwnerID:  calendar.GetOwnerId(),
+		Name:     calendar.GetName(),
+		TimeZone: calendar.GetTimeZone(),
+		Color:    calendar.GetColor(),
+	}
+}
+
+func toPbCalendar(calendar *storage.Calendar) *pb.Calendar {
+	return &pb.Calendar{
+		Id:       calendar.ID.String(),
+		OwnerId:  calendar.OwnerID,
+		Name:     calendar.Name,
+		TimeZone: calendar.TimeZone,
+		Color:    calendar.Color,
+		Default:  calendar.Default,
+	}
+}
+
+func toPbShare(share *storage.Share) *pb.Share {
+	return &pb.Share{
+		CalendarId: share.CalendarID.String(),
+		UserId:     share.UserID,
+		Role:       roleToPb[share.Role],
+	}
+}
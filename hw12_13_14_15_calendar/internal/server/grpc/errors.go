@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+const errorDomain = "calendar"
+
+type ErrorInterceptor struct {
+	logger Logger
+}
+
+func NewErrorInterceptor(logg Logger) *ErrorInterceptor {
+	return &ErrorInterceptor{
+		logger: logg,
+	}
+}
+
+// UnaryServerErrorInterceptor converts domain errors returned by handlers to gRPC statuses.
+func (e *ErrorInterceptor) UnaryServerErrorInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		if apperror.CodeOf(err) == apperror.CodeInternal {
+			e.logger.Error("%s: %s", info.FullMethod, err.Error())
+		}
+		return resp, statusError(err)
+	}
+
+	return resp, nil
+}
+
+// statusError builds gRPC status with google.rpc.ErrorInfo details from any error.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := apperror.CodeOf(err)
+
+	message := err.Error()
+	if code == apperror.CodeInternal {
+		// do not expose internals to the client.
+		message = "unexpected server error"
+	}
+
+	details := []protoiface.MessageV1{
+		&errdetails.ErrorInfo{
+			Reason: strings.ToUpper(string(code)),
+			Domain: errorDomain,
+		},
+	}
+
+	if fields := apperror.FieldsOf(err); len(fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Description,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	st := status.New(code.GRPCCode(), message)
+	detailed, detailsErr := st.WithDetails(details...)
+	if detailsErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
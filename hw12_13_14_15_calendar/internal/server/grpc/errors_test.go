@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusError(t *testing.T) {
+	t.Run("domain error", func(t *testing.T) {
+		st := status.Convert(statusError(apperror.Wrap(apperror.CodeNotFound, errors.New("event not found"))))
+
+		assert.Equal(t, codes.NotFound, st.Code())
+		assert.Equal(t, "event not found", st.Message())
+		require.Len(t, st.Details(), 1)
+		assert.Equal(t, "NOT_FOUND", st.Details()[0].(*errdetails.ErrorInfo).Reason)
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		err := apperror.Validation([]apperror.FieldViolation{{Field: "title", Description: "title is required"}})
+		st := status.Convert(statusError(err))
+
+		assert.Equal(t, codes.InvalidArgument, st.Code())
+		require.Len(t, st.Details(), 2)
+		badRequest := st.Details()[1].(*errdetails.BadRequest)
+		require.Len(t, badRequest.FieldViolations, 1)
+		assert.Equal(t, "title", badRequest.FieldViolations[0].Field)
+	})
+
+	t.Run("internal error", func(t *testing.T) {
+		st := status.Convert(statusError(errors.New("connection refused")))
+
+		assert.Equal(t, codes.Internal, st.Code())
+		assert.NotContains(t, st.Message(), "connection refused")
+	})
+
+	t.Run("status error is kept", func(t *testing.T) {
+		st := status.Convert(statusError(status.Error(codes.Canceled, "canceled")))
+
+		assert.Equal(t, codes.Canceled, st.Code())
+	})
+}
@@ -0,0 +1,45 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type IdentityInterceptor struct {
+	// trustUserHeader honors the user header of every client, the server is behind an authenticating proxy.
+	trustUserHeader bool
+}
+
+func NewIdentityInterceptor(trustUserHeader bool) *IdentityInterceptor {
+	return &IdentityInterceptor{trustUserHeader: trustUserHeader}
+}
+
+// UnaryServerIdentityInterceptor passes the client verified by mutual TLS and the user of the request
+// to App through context.
+func (i *IdentityInterceptor) UnaryServerIdentityInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	var id *identity.Identity
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			id = identity.FromConnectionState(info.State)
+		}
+	}
+
+	if values := metadata.ValueFromIncomingContext(ctx, identity.UserHeader); len(values) > 0 {
+		id = identity.WithUser(id, values[0], i.trustUserHeader)
+	}
+	if id != nil {
+		ctx = identity.NewContext(ctx, id)
+	}
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	digestToPb = map[storage.DigestMode]pb.DigestMode{
+		storage.DigestOff:    pb.DigestMode_DIGEST_MODE_OFF,
+		storage.DigestHourly: pb.DigestMode_DIGEST_MODE_HOURLY,
+		storage.DigestDaily:  pb.DigestMode_DIGEST_MODE_DAILY,
+	}
+	digestFromPb = map[pb.DigestMode]storage.DigestMode{
+		pb.DigestMode_DIGEST_MODE_OFF:    storage.DigestOff,
+		pb.DigestMode_DIGEST_MODE_HOURLY: storage.DigestHourly,
+		pb.DigestMode_DIGEST_MODE_DAILY:  storage.DigestDaily,
+	}
+)
+
+func (s *Server) GetPreferences(ctx context.Context, req *pb.UserIdRequest) (*pb.PreferencesResponse, error) {
+	preferences, err := s.app.GetPreferences(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.PreferencesResponse{Preferences: toPbPreferences(preferences)}, nil
+}
+
+func (s *Server) SavePreferences(ctx context.Context, req *pb.PreferencesRequest) (*pb.PreferencesResponse, error) {
+	preferences := &storage.Preferences{
+		UserID:     req.UserId, // user from the path wins over the body
+		Channel:    req.Preferences.GetChannel(),
+		Address:    req.Preferences.GetAddress(),
+		Locale:     req.Preferences.GetLocale(),
+		TimeZone:   req.Preferences.GetTimeZone(),
+		QuietStart: req.Preferences.GetQuietStart(),
+		QuietEnd:   req.Preferences.GetQuietEnd(),
+		Digest:     digestFromPb[req.Preferences.GetDigest()],
+	}
+
+	if err := s.app.SavePreferences(ctx, preferences); err != nil {
+		return nil, err
+	}
+
+	return &pb.PreferencesResponse{Preferences: toPbPreferences(preferences)}, nil
+}
+
+func (s *Server) DeletePreferences(ctx context.Context, req *pb.UserIdRequest) (*emptypb.Empty, error) {
+	if err := s.app.DeletePreferences(ctx, req.UserId); err != nil {
+		return &emptypb.Empty{}, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func toPbPreferences(preferences *storage.Preferences) *pb.Preferences {
+	return &pb.Preferences{
+		UserId:     preferences.UserID,
+		Channel:    preferences.Channel,
+		Address:    preferences.Address,
+		Locale:     preferences.Locale,
+		TimeZone:   preferences.TimeZone,
+		QuietStart: preferences.QuietStart,
+		QuietEnd:   preferences.QuietEnd,
+		Digest:     digestToPb[preferences.Digest],
+	}
+}
@@ -2,21 +2,27 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"time"
 
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-var ErrWrongEventUUIDArgument = errors.New("cannot parse event id argument to UUID")
+var (
+	ErrWrongEventUUIDArgument    = errors.New("cannot parse event id argument to UUID")
+	ErrWrongCalendarUUIDArgument = errors.New("cannot parse calendar id argument to UUID")
+)
 
 type Server struct {
 	host   string
@@ -31,12 +37,26 @@ type Application interface {
 	CreateEvent(ctx context.Context, event *storage.Event) error
 	UpdateEvent(ctx context.Context, eventID uuid.UUID, event *storage.Event) error
 	DeleteEvent(ctx context.Context, eventID uuid.UUID) error
-	GetEvents(ctx context.Context) ([]*storage.Event, error)
+	GetEvents(ctx context.Context, filter storage.EventFilter) ([]*storage.Event, error)
 	GetEvent(ctx context.Context, eventID uuid.UUID) (*storage.Event, error)
 	GetEventByDate(ctx context.Context, eventDatetime time.Time) (*storage.Event, error)
-	GetEventsForDay(ctx context.Context, startOfDay time.Time) ([]*storage.Event, error)
-	GetEventsForWeek(ctx context.Context, startOfWeek time.Time) ([]*storage.Event, error)
-	GetEventsForMonth(ctx context.Context, startOfMonth time.Time) ([]*storage.Event, error)
+	GetEventsForDay(ctx context.Context, startOfDay time.Time, filter storage.EventFilter) ([]*storage.Event, error)
+	GetEventsForWeek(ctx context.Context, startOfWeek time.Time, filter storage.EventFilter) ([]*storage.Event, error)
+	GetEventsForMonth(ctx context.Context, startOfMonth time.Time, filter storage.EventFilter) ([]*storage.Event, error)
+	GetPreferences(ctx context.Context, userID int64) (*storage.Preferences, error)
+	SavePreferences(ctx context.Context, preferences *storage.Preferences) error
+	DeletePreferences(ctx context.Context, userID int64) error
+	GetTags(ctx context.Context, userID int64) ([]*storage.Tag, error)
+	SaveTag(ctx context.Context, tag *storage.Tag) error
+	DeleteTag(ctx context.Context, userID int64, name string) error
+	CreateCalendar(ctx context.Context, calendar *storage.Calendar) error
+	UpdateCalendar(ctx context.Context, calendar *storage.Calendar) error
+	DeleteCalendar(ctx context.Context, calendarID uuid.UUID) error
+	GetCalendar(ctx context.Context, calendarID uuid.UUID) (*storage.Calendar, error)
+	GetCalendars(ctx context.Context, userID int64) ([]*storage.Calendar, error)
+	ShareCalendar(ctx context.Context, share *storage.Share) error
+	UnshareCalendar(ctx context.Context, calendarID uuid.UUID, userID int64) error
+	GetShares(ctx context.Context, calendarID uuid.UUID) ([]*storage.Share, error)
 }
 
 type Logger interface {
@@ -53,70 +73,96 @@ type Response struct {
 	Error      string `json:"error"`
 }
 
-func NewServer(host string, port int, logger Logger, app Application) *Server {
-	return &Server{
+// NewServer creates gRPC server, nil tlsConfig means plain connections. The user header is honored
+// for every client with trustUserHeader, otherwise only for services verified by mutual TLS.
+func NewServer(
+	host string,
+	port int,
+	logger Logger,
+	app Application,
+	tlsConfig *tls.Config,
+	trustUserHeader bool,
+) *Server {
+	s := &Server{
 		host:   host,
 		port:   port,
 		logger: logger,
 		app:    app,
 	}
-}
 
-func (s *Server) Start(ctx context.Context) error {
-	lsn, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.host, s.port))
-	if err != nil {
-		return err
+	// init interceptor.
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			NewLoggingInterceptor(logger).UnaryServerLoggingInterceptor,
+			NewErrorInterceptor(logger).UnaryServerErrorInterceptor,
+			NewIdentityInterceptor(trustUserHeader).UnaryServerIdentityInterceptor,
+		),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
 
-	// init interceptor.
-	s.server = grpc.NewServer(
-		grpc.UnaryInterceptor(NewLoggingInterceptor(s.logger).UnaryServerLoggingInterceptor),
-	)
+	// server is created at once, so Stop works even if it is called before Start.
+	s.server = grpc.NewServer(options...)
 	pb.RegisterCalendarServiceServer(s.server, s)
 
 	// init reflection/
 	reflection.Register(s.server)
 
-	go func() {
-		<-ctx.Done()
-		s.Stop()
-	}()
+	return s
+}
+
+// Start serves requests until Stop, it returns nil when the server is stopped.
+func (s *Server) Start(_ context.Context) error {
+	lsn, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.host, s.port))
+	if err != nil {
+		return err
+	}
 
 	s.logger.Info("grpc-server is up...")
 
-	return s.server.Serve(lsn)
+	if err := s.server.Serve(lsn); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+
+	return nil
 }
 
-func (s *Server) Stop() {
-	s.server.GracefulStop()
+// Stop waits for in-flight requests until ctx is done, then closes connections at once.
+func (s *Server) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
 }
 
-func (s *Server) CreateEvent(ctx context.Context, req *pb.EventRequest) (*emptypb.Empty, error) {
-	event := &storage.Event{
-		Title:            req.Event.Title,
-		Description:      req.Event.Description,
-		UserID:           req.Event.UserId,
-		Duration:         req.Event.Duration,
-		TimeNotification: req.Event.TimeNotification.AsTime(),
-		DateTime:         req.Event.DateTime.AsTime(),
+func (s *Server) CreateEvent(ctx context.Context, req *pb.EventRequest) (*pb.EventResponse, error) {
+	event, err := s.fromPbEvent(req.Event)
+	if err != nil {
+		return nil, err
 	}
 
-	err := s.app.CreateEvent(ctx, event)
+	err = s.app.CreateEvent(ctx, event)
 	if err != nil {
-		return &emptypb.Empty{}, err
+		return nil, err
 	}
 
-	return &emptypb.Empty{}, nil
+	return s.eventResponse(event), nil
 }
 
 func (s *Server) UpdateEvent(ctx context.Context, req *pb.EventUpdateRequest) (*emptypb.Empty, error) {
-	event := &storage.Event{
-		Title:            req.Event.Title,
-		Description:      req.Event.Description,
-		UserID:           req.Event.UserId,
-		Duration:         req.Event.Duration,
-		TimeNotification: req.Event.TimeNotification.AsTime(),
-		DateTime:         req.Event.DateTime.AsTime(),
+	event, err := s.fromPbEvent(req.Event)
+	if err != nil {
+		return nil, err
 	}
 
 	eventUUID, err := s.parseRequestAndGetUUID(req.Id)
@@ -132,29 +178,13 @@ func (s *Server) UpdateEvent(ctx context.Context, req *pb.EventUpdateRequest) (*
 	return &emptypb.Empty{}, nil
 }
 
-func (s *Server) GetEvents(ctx context.Context, _ *emptypb.Empty) (*pb.EventsResponse, error) {
-	events, err := s.app.GetEvents(ctx)
+func (s *Server) GetEvents(ctx context.Context, req *pb.EventsRequest) (*pb.EventsResponse, error) {
+	events, err := s.app.GetEvents(ctx, storage.EventFilter{Tag: req.Tag, UserID: req.UserId})
 	if err != nil {
 		return nil, err
 	}
 
-	pbEvents := make([]*pb.Event, len(events))
-	for i, event := range events {
-		pbEvents[i] = &pb.Event{
-			Id:               event.ID.String(),
-			Title:            event.Title,
-			Description:      event.Description,
-			UserId:           event.UserID,
-			Duration:         event.Duration,
-			TimeNotification: timestamppb.New(event.TimeNotification),
-			DateTime:         timestamppb.New(event.DateTime),
-		}
-	}
-
-	eventResponse := &pb.EventsResponse{
-		Events: pbEvents,
-	}
-	return eventResponse, nil
+	return &pb.EventsResponse{Events: s.eventsReponse(events)}, nil
 }
 
 func (s *Server) DeleteEvent(ctx context.Context, req *pb.EventIdRequest) (*emptypb.Empty, error) {
@@ -185,11 +215,23 @@ func (s *Server) GetEvent(ctx context.Context, req *pb.EventIdRequest) (*pb.Even
 	return s.eventResponse(event), nil
 }
 
+func (s *Server) GetEventByDate(ctx context.Context, req *pb.DateRequest) (*pb.EventResponse, error) {
+	event, err := s.app.GetEventByDate(ctx, req.DateTime.AsTime())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.eventResponse(event), nil
+}
+
 func (s *Server) GetEventsForDay(ctx context.Context, req *pb.RangeRequest) (*pb.EventsResponse, error) {
-	fmt.Println(req.DateTime)
-	fmt.Println(req.DateTime.AsTime())
+	start, err := rangeStart(req)
+	if err != nil {
+		return nil, err
+	}
 
-	events, err := s.app.GetEventsForDay(ctx, req.DateTime.AsTime())
+	filter := storage.EventFilter{Tag: req.Tag, UserID: req.UserId}
+	events, err := s.app.GetEventsForDay(ctx, start, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -198,7 +240,13 @@ func (s *Server) GetEventsForDay(ctx context.Context, req *pb.RangeRequest) (*pb
 }
 
 func (s *Server) GetEventsForWeek(ctx context.Context, req *pb.RangeRequest) (*pb.EventsResponse, error) {
-	events, err := s.app.GetEventsForWeek(ctx, req.DateTime.AsTime())
+	start, err := rangeStart(req)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := storage.EventFilter{Tag: req.Tag, UserID: req.UserId}
+	events, err := s.app.GetEventsForWeek(ctx, start, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -207,7 +255,13 @@ func (s *Server) GetEventsForWeek(ctx context.Context, req *pb.RangeRequest) (*p
 }
 
 func (s *Server) GetEventsForMonth(ctx context.Context, req *pb.RangeRequest) (*pb.EventsResponse, error) {
-	events, err := s.app.GetEventsForMonth(ctx, req.DateTime.AsTime())
+	start, err := rangeStart(req)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := storage.EventFilter{Tag: req.Tag, UserID: req.UserId}
+	events, err := s.app.GetEventsForMonth(ctx, start, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -220,38 +274,158 @@ func (s *Server) parseRequestAndGetUUID(uuidString string) (uuid.UUID, error) {
 	eventUUID, err := uuid.Parse(uuidString)
 	if err != nil {
 		s.logger.Debug(ErrWrongEventUUIDArgument.Error())
-		return uuid.Nil, ErrWrongEventUUIDArgument
+		return uuid.Nil, apperror.Wrap(apperror.CodeBadRequest, ErrWrongEventUUIDArgument)
 	}
 
 	return eventUUID, nil
 }
 
+// helper for getting calendar UUID from request.
+func (s *Server) parseCalendarUUID(uuidString string) (uuid.UUID, error) {
+	calendarUUID, err := uuid.Parse(uuidString)
+	if err != nil {
+		s.logger.Debug(ErrWrongCalendarUUIDArgument.Error())
+		return uuid.Nil, apperror.Wrap(apperror.CodeBadRequest, ErrWrongCalendarUUIDArgument)
+	}
+
+	return calendarUUID, nil
+}
+
 func (s *Server) eventResponse(event *storage.Event) *pb.EventResponse {
 	return &pb.EventResponse{
-		Event: &pb.Event{
-			Id:               event.ID.String(),
-			Title:            event.Title,
-			Description:      event.Description,
-			UserId:           event.UserID,
-			Duration:         event.Duration,
-			TimeNotification: timestamppb.New(event.TimeNotification),
-			DateTime:         timestamppb.New(event.DateTime),
-		},
+		Event: toPbEvent(event),
 	}
 }
 
 func (s *Server) eventsReponse(events []*storage.Event) []*pb.Event {
 	res := make([]*pb.Event, len(events))
 	for i, event := range events {
-		res[i] = &pb.Event{
-			Id:               event.ID.String(),
-			Title:            event.Title,
-			Description:      event.Description,
-			UserId:           event.UserID,
-			Duration:         event.Duration,
-			TimeNotification: timestamppb.New(event.TimeNotification),
-			DateTime:         timestamppb.New(event.DateTime),
-		}
+		res[i] = toPbEvent(event)
+	}
+	return res
+}
+
+func toPbEvent(event *storage.Event) *pb.Event {
+	res := &pb.Event{
+		Id:               event.ID.String(),
+		CalendarId:       event.CalendarID.String(),
+		Title:            event.Title,
+		Description:      event.Description,
+		UserId:           event.UserID,
+		Duration:         int64(event.EndTime.Sub(event.DateTime) / time.Second),
+		TimeNotification: asTimestamp(event.TimeNotification),
+		DateTime:         asTimestamp(event.DateTime),
+		EndTime:          asTimestamp(event.EndTime),
+		AllDay:           event.AllDay,
+		Color:            event.Color,
+		Location:         event.Location,
+		Tags:             event.Tags,
+	}
+	if event.AllDay {
+		res.StartDate = event.DateTime.UTC().Format(storage.DayLayout)
+		res.EndDate = event.EndTime.UTC().AddDate(0, 0, -1).Format(storage.DayLayout)
 	}
+
 	return res
 }
+
+// fromPbEvent converts event from request, empty calendar means the default calendar of the user.
+// Duration of older clients is used only without end time, all-day events are taken from dates.
+func (s *Server) fromPbEvent(event *pb.Event) (*storage.Event, error) {
+	calendarID := uuid.Nil
+	if event.GetCalendarId() != "" {
+		var err error
+		if calendarID, err = s.parseCalendarUUID(event.GetCalendarId()); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &storage.Event{
+		CalendarID:       calendarID,
+		Title:            event.GetTitle(),
+		Description:      event.GetDescription(),
+		UserID:           event.GetUserId(),
+		TimeNotification: asTime(event.GetTimeNotification()),
+		DateTime:         asTime(event.GetDateTime()),
+		EndTime:          asTime(event.GetEndTime()),
+		AllDay:           event.GetAllDay(),
+		Color:            event.GetColor(),
+		Location:         event.GetLocation(),
+		Tags:             event.GetTags(),
+	}
+	if res.EndTime.IsZero() && event.GetDuration() > 0 {
+		res.EndTime = res.DateTime.Add(time.Duration(event.GetDuration()) * time.Second)
+	}
+
+	if res.AllDay {
+		if err := setDates(res, event.GetStartDate(), event.GetEndDate()); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// setDates sets days of all-day event, end date is the last day of the event.
+func setDates(event *storage.Event, startDate, endDate string) error {
+	var violations []apperror.FieldViolation
+
+	if startDate != "" {
+		start, err := time.Parse(storage.DayLayout, startDate)
+		if err != nil {
+			violations = append(violations, apperror.FieldViolation{
+				Field: "start_date", Description: "start_date must be in YYYY-MM-DD format",
+			})
+		}
+		event.DateTime = start
+		event.EndTime = time.Time{}
+	}
+
+	if endDate != "" {
+		end, err := time.Parse(storage.DayLayout, endDate)
+		if err != nil {
+			violations = append(violations, apperror.FieldViolation{
+				Field: "end_date", Description: "end_date must be in YYYY-MM-DD format",
+			})
+		}
+		event.EndTime = end.AddDate(0, 0, 1)
+	}
+
+	if len(violations) > 0 {
+		return apperror.Validation(violations)
+	}
+
+	return nil
+}
+
+// rangeStart returns date_time of the request in its time zone.
+func rangeStart(req *pb.RangeRequest) (time.Time, error) {
+	location := time.UTC
+	if req.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(req.TimeZone); err != nil {
+			return time.Time{}, apperror.Validation([]apperror.FieldViolation{
+				{Field: "time_zone", Description: "unknown time zone " + req.TimeZone},
+			})
+		}
+	}
+
+	return req.DateTime.AsTime().In(location), nil
+}
+
+// absent timestamp means zero time, not the Unix epoch.
+func asTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+
+	return ts.AsTime()
+}
+
+func asTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *Server) GetTags(ctx context.Context, req *pb.UserIdRequest) (*pb.TagsResponse, error) {
+	tags, err := s.app.GetTags(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*pb.Tag, len(tags))
+	for i, tag := range tags {
+		res[i] = toPbTag(tag)
+	}
+
+	return &pb.TagsResponse{Tags: res}, nil
+}
+
+func (s *Server) SaveTag(ctx context.Context, req *pb.TagRequest) (*pb.TagResponse, error) {
+	tag := &storage.Tag{
+		UserID: req.UserId, // user and name from the path win over the body
+		Name:   req.Name,
+		Color:  req.Tag.GetColor(),
+	}
+
+	if err := s.app.SaveTag(ctx, tag); err != nil {
+		return nil, err
+	}
+
+	return &pb.TagResponse{Tag: toPbTag(tag)}, nil
+}
+
+func (s *Server) DeleteTag(ctx context.Context, req *pb.TagNameRequest) (*emptypb.Empty, error) {
+	if err := s.app.DeleteTag(ctx, req.UserId, req.Name); err != nil {
+		return &emptypb.Empty{}, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func toPbTag(tag *storage.Tag) *pb.Tag {
+	return &pb.Tag{
+		UserId: tag.UserID,
+		Name:   tag.Name,
+		Color:  tag.Color,
+	}
+}
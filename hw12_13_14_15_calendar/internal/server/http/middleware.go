@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/identity"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/http/response"
 )
 
@@ -18,6 +19,26 @@ func loggingMiddleware(next http.Handler, logger Logger) http.Handler {
 	})
 }
 
+// identityMiddleware passes the client verified by mutual TLS and the user of the request to App
+// through context, grpc-gateway keeps the context of the request. The header of the user is honored
+// for every client only with trustUserHeader, see identity.WithUser.
+func identityMiddleware(next http.Handler, trustUserHeader bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id *identity.Identity
+		if r.TLS != nil {
+			id = identity.FromConnectionState(*r.TLS)
+		}
+
+		if header := r.Header.Get(identity.UserHeader); header != "" {
+			id = identity.WithUser(id, header, trustUserHeader)
+		}
+		if id != nil {
+			r = r.WithContext(identity.NewContext(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func serverLog(logger Logger, rw *response.XResponseWriter, r *http.Request, time time.Time, latency time.Duration) {
 	logger.Info(fmt.Sprintf(
 		"%s [%s] %s %s %s %d %s \"%s\"",
@@ -0,0 +1,8 @@
+package internalhttp
+
+import _ "embed" // for OpenAPI document
+
+// OpenAPI document is generated from EventService.proto by protoc-gen-openapiv2 (see `make generate`).
+//
+//go:embed openapi/EventService.swagger.json
+var openAPISpec []byte
@@ -0,0 +1,83 @@
+package internalhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/apperror"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/status"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an error response body according to RFC 7807.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"` //nolint:tagliatelle
+}
+
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// errorHandler is used by grpc-gateway for every failed request.
+func (s *Server) errorHandler(
+	_ context.Context,
+	_ *runtime.ServeMux,
+	_ runtime.Marshaler,
+	w http.ResponseWriter,
+	r *http.Request,
+	err error,
+) {
+	s.problemResponse(w, r, err)
+}
+
+func (s *Server) problemResponse(w http.ResponseWriter, r *http.Request, err error) {
+	code := apperror.CodeOf(err)
+	httpStatus := code.HTTPStatus()
+	detail := err.Error()
+
+	// errors of gateway itself (wrong body, unknown route) come as gRPC status.
+	var appErr *apperror.Error
+	if st, ok := status.FromError(err); ok && !errors.As(err, &appErr) {
+		code = apperror.CodeFromGRPC(st.Code())
+		httpStatus = runtime.HTTPStatusFromCode(st.Code())
+		detail = st.Message()
+	}
+
+	if code == apperror.CodeInternal {
+		s.logger.Error("%s %s: %s", r.Method, r.URL.Path, err.Error())
+		// do not expose internals to the client.
+		detail = "unexpected server error"
+	}
+
+	problem := &Problem{
+		Type:     "urn:calendar:error:" + string(code),
+		Title:    http.StatusText(httpStatus),
+		Status:   httpStatus,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     string(code),
+	}
+
+	for _, field := range apperror.FieldsOf(err) {
+		problem.InvalidParams = append(problem.InvalidParams, InvalidParam{
+			Name:   field.Field,
+			Reason: field.Description,
+		})
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -1,17 +1,17 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
 // - protoc-gen-go-grpc v1.3.0
-// - protoc             v3.12.4
+// - protoc             v3.5.1-go
 // source: EventService.proto
 
 package pb
 
 import (
 	context "context"
-	empty "github.com/golang/protobuf/ptypes/empty"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
 // This is a compile-time assertion to ensure that this generated file
@@ -25,23 +25,54 @@ const (
 	CalendarService_DeleteEvent_FullMethodName       = "/event.CalendarService/DeleteEvent"
 	CalendarService_GetEvents_FullMethodName         = "/event.CalendarService/GetEvents"
 	CalendarService_GetEvent_FullMethodName          = "/event.CalendarService/GetEvent"
+	CalendarService_GetEventByDate_FullMethodName    = "/event.CalendarService/GetEventByDate"
 	CalendarService_GetEventsForDay_FullMethodName   = "/event.CalendarService/GetEventsForDay"
 	CalendarService_GetEventsForWeek_FullMethodName  = "/event.CalendarService/GetEventsForWeek"
 	CalendarService_GetEventsForMonth_FullMethodName = "/event.CalendarService/GetEventsForMonth"
+	CalendarService_GetPreferences_FullMethodName    = "/event.CalendarService/GetPreferences"
+	CalendarService_SavePreferences_FullMethodName   = "/event.CalendarService/SavePreferences"
+	CalendarService_DeletePreferences_FullMethodName = "/event.CalendarService/DeletePreferences"
+	CalendarService_GetTags_FullMethodName           = "/event.CalendarService/GetTags"
+	CalendarService_SaveTag_FullMethodName           = "/event.CalendarService/SaveTag"
+	CalendarService_DeleteTag_FullMethodName         = "/event.CalendarService/DeleteTag"
+	CalendarService_CreateCalendar_FullMethodName    = "/event.CalendarService/CreateCalendar"
+	CalendarService_UpdateCalendar_FullMethodName    = "/event.CalendarService/UpdateCalendar"
+	CalendarService_DeleteCalendar_FullMethodName    = "/event.CalendarService/DeleteCalendar"
+	CalendarService_GetCalendar_FullMethodName       = "/event.CalendarService/GetCalendar"
+	CalendarService_GetCalendars_FullMethodName      = "/event.CalendarService/GetCalendars"
+	CalendarService_ShareCalendar_FullMethodName     = "/event.CalendarService/ShareCalendar"
+	CalendarService_UnshareCalendar_FullMethodName   = "/event.CalendarService/UnshareCalendar"
+	CalendarService_GetShares_FullMethodName         = "/event.CalendarService/GetShares"
 )
 
 // CalendarServiceClient is the client API for CalendarService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CalendarServiceClient interface {
-	CreateEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*empty.Empty, error)
-	UpdateEvent(ctx context.Context, in *EventUpdateRequest, opts ...grpc.CallOption) (*empty.Empty, error)
-	DeleteEvent(ctx context.Context, in *EventIdRequest, opts ...grpc.CallOption) (*empty.Empty, error)
-	GetEvents(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*EventsResponse, error)
+	CreateEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	// UpdateEvent replaces the whole event, fields missing in the body are cleared.
+	UpdateEvent(ctx context.Context, in *EventUpdateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DeleteEvent(ctx context.Context, in *EventIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetEvents(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (*EventsResponse, error)
 	GetEvent(ctx context.Context, in *EventIdRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	GetEventByDate(ctx context.Context, in *DateRequest, opts ...grpc.CallOption) (*EventResponse, error)
 	GetEventsForDay(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*EventsResponse, error)
 	GetEventsForWeek(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*EventsResponse, error)
 	GetEventsForMonth(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*EventsResponse, error)
+	GetPreferences(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*PreferencesResponse, error)
+	SavePreferences(ctx context.Context, in *PreferencesRequest, opts ...grpc.CallOption) (*PreferencesResponse, error)
+	DeletePreferences(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetTags(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*TagsResponse, error)
+	SaveTag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*TagResponse, error)
+	DeleteTag(ctx context.Context, in *TagNameRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CreateCalendar(ctx context.Context, in *CalendarRequest, opts ...grpc.CallOption) (*CalendarResponse, error)
+	UpdateCalendar(ctx context.Context, in *CalendarUpdateRequest, opts ...grpc.CallOption) (*CalendarResponse, error)
+	DeleteCalendar(ctx context.Context, in *CalendarIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetCalendar(ctx context.Context, in *CalendarIdRequest, opts ...grpc.CallOption) (*CalendarResponse, error)
+	GetCalendars(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*CalendarsResponse, error)
+	ShareCalendar(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareResponse, error)
+	UnshareCalendar(ctx context.Context, in *ShareIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetShares(ctx context.Context, in *CalendarIdRequest, opts ...grpc.CallOption) (*SharesResponse, error)
 }
 
 type calendarServiceClient struct {
@@ -52,8 +83,8 @@ func NewCalendarServiceClient(cc grpc.ClientConnInterface) CalendarServiceClient
 	return &calendarServiceClient{cc}
 }
 
-func (c *calendarServiceClient) CreateEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
-	out := new(empty.Empty)
+func (c *calendarServiceClient) CreateEvent(ctx context.Context, in *EventRequest, opts ...grpc.CallOption) (*EventResponse, error) {
+	out := new(EventResponse)
 	err := c.cc.Invoke(ctx, CalendarService_CreateEvent_FullMethodName, in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -61,8 +92,8 @@ func (c *calendarServiceClient) CreateEvent(ctx context.Context, in *EventReques
 	return out, nil
 }
 
-func (c *calendarServiceClient) UpdateEvent(ctx context.Context, in *EventUpdateRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
-	out := new(empty.Empty)
+func (c *calendarServiceClient) UpdateEvent(ctx context.Context, in *EventUpdateRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, CalendarService_UpdateEvent_FullMethodName, in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -70,8 +101,8 @@ func (c *calendarServiceClient) UpdateEvent(ctx context.Context, in *EventUpdate
 	return out, nil
 }
 
-func (c *calendarServiceClient) DeleteEvent(ctx context.Context, in *EventIdRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
-	out := new(empty.Empty)
+func (c *calendarServiceClient) DeleteEvent(ctx context.Context, in *EventIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, CalendarService_DeleteEvent_FullMethodName, in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -79,7 +110,7 @@ func (c *calendarServiceClient) DeleteEvent(ctx context.Context, in *EventIdRequ
 	return out, nil
 }
 
-func (c *calendarServiceClient) GetEvents(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*EventsResponse, error) {
+func (c *calendarServiceClient) GetEvents(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (*EventsResponse, error) {
 	out := new(EventsResponse)
 	err := c.cc.Invoke(ctx, CalendarService_GetEvents_FullMethodName, in, out, opts...)
 	if err != nil {
@@ -97,6 +128,15 @@ func (c *calendarServiceClient) GetEvent(ctx context.Context, in *EventIdRequest
 	return out, nil
 }
 
+func (c *calendarServiceClient) GetEventByDate(ctx context.Context, in *DateRequest, opts ...grpc.CallOption) (*EventResponse, error) {
+	out := new(EventResponse)
+	err := c.cc.Invoke(ctx, CalendarService_GetEventByDate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *calendarServiceClient) GetEventsForDay(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*EventsResponse, error) {
 	out := new(EventsResponse)
 	err := c.cc.Invoke(ctx, CalendarService_GetEventsForDay_FullMethodName, in, out, opts...)
@@ -124,18 +164,160 @@ func (c *calendarServiceClient) GetEventsForMonth(ctx context.Context, in *Range
 	return out, nil
 }
 
+func (c *calendarServiceClient) GetPreferences(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*PreferencesResponse, error) {
+	out := new(PreferencesResponse)
+	err := c.cc.Invoke(ctx, CalendarService_GetPreferences_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) SavePreferences(ctx context.Context, in *PreferencesRequest, opts ...grpc.CallOption) (*PreferencesResponse, error) {
+	out := new(PreferencesResponse)
+	err := c.cc.Invoke(ctx, CalendarService_SavePreferences_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) DeletePreferences(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, CalendarService_DeletePreferences_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) GetTags(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*TagsResponse, error) {
+	out := new(TagsResponse)
+	err := c.cc.Invoke(ctx, CalendarService_GetTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) SaveTag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*TagResponse, error) {
+	out := new(TagResponse)
+	err := c.cc.Invoke(ctx, CalendarService_SaveTag_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) DeleteTag(ctx context.Context, in *TagNameRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, CalendarService_DeleteTag_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) CreateCalendar(ctx context.Context, in *CalendarRequest, opts ...grpc.CallOption) (*CalendarResponse, error) {
+	out := new(CalendarResponse)
+	err := c.cc.Invoke(ctx, CalendarService_CreateCalendar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) UpdateCalendar(ctx context.Context, in *CalendarUpdateRequest, opts ...grpc.CallOption) (*CalendarResponse, error) {
+	out := new(CalendarResponse)
+	err := c.cc.Invoke(ctx, CalendarService_UpdateCalendar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) DeleteCalendar(ctx context.Context, in *CalendarIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, CalendarService_DeleteCalendar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) GetCalendar(ctx context.Context, in *CalendarIdRequest, opts ...grpc.CallOption) (*CalendarResponse, error) {
+	out := new(CalendarResponse)
+	err := c.cc.Invoke(ctx, CalendarService_GetCalendar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) GetCalendars(ctx context.Context, in *UserIdRequest, opts ...grpc.CallOption) (*CalendarsResponse, error) {
+	out := new(CalendarsResponse)
+	err := c.cc.Invoke(ctx, CalendarService_GetCalendars_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) ShareCalendar(ctx context.Context, in *ShareRequest, opts ...grpc.CallOption) (*ShareResponse, error) {
+	out := new(ShareResponse)
+	err := c.cc.Invoke(ctx, CalendarService_ShareCalendar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) UnshareCalendar(ctx context.Context, in *ShareIdRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, CalendarService_UnshareCalendar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calendarServiceClient) GetShares(ctx context.Context, in *CalendarIdRequest, opts ...grpc.CallOption) (*SharesResponse, error) {
+	out := new(SharesResponse)
+	err := c.cc.Invoke(ctx, CalendarService_GetShares_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CalendarServiceServer is the server API for CalendarService service.
 // All implementations must embed UnimplementedCalendarServiceServer
 // for forward compatibility
 type CalendarServiceServer interface {
-	CreateEvent(context.Context, *EventRequest) (*empty.Empty, error)
-	UpdateEvent(context.Context, *EventUpdateRequest) (*empty.Empty, error)
-	DeleteEvent(context.Context, *EventIdRequest) (*empty.Empty, error)
-	GetEvents(context.Context, *empty.Empty) (*EventsResponse, error)
+	CreateEvent(context.Context, *EventRequest) (*EventResponse, error)
+	// UpdateEvent replaces the whole event, fields missing in the body are cleared.
+	UpdateEvent(context.Context, *EventUpdateRequest) (*emptypb.Empty, error)
+	DeleteEvent(context.Context, *EventIdRequest) (*emptypb.Empty, error)
+	GetEvents(context.Context, *EventsRequest) (*EventsResponse, error)
 	GetEvent(context.Context, *EventIdRequest) (*EventResponse, error)
+	GetEventByDate(context.Context, *DateRequest) (*EventResponse, error)
 	GetEventsForDay(context.Context, *RangeRequest) (*EventsResponse, error)
 	GetEventsForWeek(context.Context, *RangeRequest) (*EventsResponse, error)
 	GetEventsForMonth(context.Context, *RangeRequest) (*EventsResponse, error)
+	GetPreferences(context.Context, *UserIdRequest) (*PreferencesResponse, error)
+	SavePreferences(context.Context, *PreferencesRequest) (*PreferencesResponse, error)
+	DeletePreferences(context.Context, *UserIdRequest) (*emptypb.Empty, error)
+	GetTags(context.Context, *UserIdRequest) (*TagsResponse, error)
+	SaveTag(context.Context, *TagRequest) (*TagResponse, error)
+	DeleteTag(context.Context, *TagNameRequest) (*emptypb.Empty, error)
+	CreateCalendar(context.Context, *CalendarRequest) (*CalendarResponse, error)
+	UpdateCalendar(context.Context, *CalendarUpdateRequest) (*CalendarResponse, error)
+	DeleteCalendar(context.Context, *CalendarIdRequest) (*emptypb.Empty, error)
+	GetCalendar(context.Context, *CalendarIdRequest) (*CalendarResponse, error)
+	GetCalendars(context.Context, *UserIdRequest) (*CalendarsResponse, error)
+	ShareCalendar(context.Context, *ShareRequest) (*ShareResponse, error)
+	UnshareCalendar(context.Context, *ShareIdRequest) (*emptypb.Empty, error)
+	GetShares(context.Context, *CalendarIdRequest) (*SharesResponse, error)
 	mustEmbedUnimplementedCalendarServiceServer()
 }
 
@@ -143,21 +325,24 @@ type CalendarServiceServer interface {
 type UnimplementedCalendarServiceServer struct {
 }
 
-func (UnimplementedCalendarServiceServer) CreateEvent(context.Context, *EventRequest) (*empty.Empty, error) {
+func (UnimplementedCalendarServiceServer) CreateEvent(context.Context, *EventRequest) (*EventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateEvent not implemented")
 }
-func (UnimplementedCalendarServiceServer) UpdateEvent(context.Context, *EventUpdateRequest) (*empty.Empty, error) {
+func (UnimplementedCalendarServiceServer) UpdateEvent(context.Context, *EventUpdateRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateEvent not implemented")
 }
-func (UnimplementedCalendarServiceServer) DeleteEvent(context.Context, *EventIdRequest) (*empty.Empty, error) {
+func (UnimplementedCalendarServiceServer) DeleteEvent(context.Context, *EventIdRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteEvent not implemented")
 }
-func (UnimplementedCalendarServiceServer) GetEvents(context.Context, *empty.Empty) (*EventsResponse, error) {
+func (UnimplementedCalendarServiceServer) GetEvents(context.Context, *EventsRequest) (*EventsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEvents not implemented")
 }
 func (UnimplementedCalendarServiceServer) GetEvent(context.Context, *EventIdRequest) (*EventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEvent not implemented")
 }
+func (UnimplementedCalendarServiceServer) GetEventByDate(context.Context, *DateRequest) (*EventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEventByDate not implemented")
+}
 func (UnimplementedCalendarServiceServer) GetEventsForDay(context.Context, *RangeRequest) (*EventsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEventsForDay not implemented")
 }
@@ -167,6 +352,48 @@ func (UnimplementedCalendarServiceServer) GetEventsForWeek(context.Context, *Ran
 func (UnimplementedCalendarServiceServer) GetEventsForMonth(context.Context, *RangeRequest) (*EventsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEventsForMonth not implemented")
 }
+func (UnimplementedCalendarServiceServer) GetPreferences(context.Context, *UserIdRequest) (*PreferencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPreferences not implemented")
+}
+func (UnimplementedCalendarServiceServer) SavePreferences(context.Context, *PreferencesRequest) (*PreferencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SavePreferences not implemented")
+}
+func (UnimplementedCalendarServiceServer) DeletePreferences(context.Context, *UserIdRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePreferences not implemented")
+}
+func (UnimplementedCalendarServiceServer) GetTags(context.Context, *UserIdRequest) (*TagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTags not implemented")
+}
+func (UnimplementedCalendarServiceServer) SaveTag(context.Context, *TagRequest) (*TagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SaveTag not implemented")
+}
+func (UnimplementedCalendarServiceServer) DeleteTag(context.Context, *TagNameRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTag not implemented")
+}
+func (UnimplementedCalendarServiceServer) CreateCalendar(context.Context, *CalendarRequest) (*CalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) UpdateCalendar(context.Context, *CalendarUpdateRequest) (*CalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) DeleteCalendar(context.Context, *CalendarIdRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) GetCalendar(context.Context, *CalendarIdRequest) (*CalendarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) GetCalendars(context.Context, *UserIdRequest) (*CalendarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCalendars not implemented")
+}
+func (UnimplementedCalendarServiceServer) ShareCalendar(context.Context, *ShareRequest) (*ShareResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) UnshareCalendar(context.Context, *ShareIdRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnshareCalendar not implemented")
+}
+func (UnimplementedCalendarServiceServer) GetShares(context.Context, *CalendarIdRequest) (*SharesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShares not implemented")
+}
 func (UnimplementedCalendarServiceServer) mustEmbedUnimplementedCalendarServiceServer() {}
 
 // UnsafeCalendarServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -235,7 +462,7 @@ func _CalendarService_DeleteEvent_Handler(srv interface{}, ctx context.Context,
 }
 
 func _CalendarService_GetEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(empty.Empty)
+	in := new(EventsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -247,7 +474,7 @@ func _CalendarService_GetEvents_Handler(srv interface{}, ctx context.Context, de
 		FullMethod: CalendarService_GetEvents_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CalendarServiceServer).GetEvents(ctx, req.(*empty.Empty))
+		return srv.(CalendarServiceServer).GetEvents(ctx, req.(*EventsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -270,6 +497,24 @@ func _CalendarService_GetEvent_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CalendarService_GetEventByDate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetEventByDate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_GetEventByDate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetEventByDate(ctx, req.(*DateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _CalendarService_GetEventsForDay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RangeRequest)
 	if err := dec(in); err != nil {
@@ -324,6 +569,258 @@ func _CalendarService_GetEventsForMonth_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CalendarService_GetPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetPreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_GetPreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetPreferences(ctx, req.(*UserIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_SavePreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PreferencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).SavePreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_SavePreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).SavePreferences(ctx, req.(*PreferencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_DeletePreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).DeletePreferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_DeletePreferences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).DeletePreferences(ctx, req.(*UserIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_GetTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_GetTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetTags(ctx, req.(*UserIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_SaveTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).SaveTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_SaveTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).SaveTag(ctx, req.(*TagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_DeleteTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TagNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).DeleteTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_DeleteTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).DeleteTag(ctx, req.(*TagNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_CreateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).CreateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_CreateCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).CreateCalendar(ctx, req.(*CalendarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_UpdateCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarUpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).UpdateCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_UpdateCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).UpdateCalendar(ctx, req.(*CalendarUpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_DeleteCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).DeleteCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_DeleteCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).DeleteCalendar(ctx, req.(*CalendarIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_GetCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_GetCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetCalendar(ctx, req.(*CalendarIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_GetCalendars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetCalendars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_GetCalendars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetCalendars(ctx, req.(*UserIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_ShareCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).ShareCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_ShareCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).ShareCalendar(ctx, req.(*ShareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_UnshareCalendar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).UnshareCalendar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_UnshareCalendar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).UnshareCalendar(ctx, req.(*ShareIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalendarService_GetShares_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalendarIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalendarServiceServer).GetShares(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CalendarService_GetShares_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalendarServiceServer).GetShares(ctx, req.(*CalendarIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CalendarService_ServiceDesc is the grpc.ServiceDesc for CalendarService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -351,6 +848,10 @@ var CalendarService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetEvent",
 			Handler:    _CalendarService_GetEvent_Handler,
 		},
+		{
+			MethodName: "GetEventByDate",
+			Handler:    _CalendarService_GetEventByDate_Handler,
+		},
 		{
 			MethodName: "GetEventsForDay",
 			Handler:    _CalendarService_GetEventsForDay_Handler,
@@ -363,6 +864,62 @@ var CalendarService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetEventsForMonth",
 			Handler:    _CalendarService_GetEventsForMonth_Handler,
 		},
+		{
+			MethodName: "GetPreferences",
+			Handler:    _CalendarService_GetPreferences_Handler,
+		},
+		{
+			MethodName: "SavePreferences",
+			Handler:    _CalendarService_SavePreferences_Handler,
+		},
+		{
+			MethodName: "DeletePreferences",
+			Handler:    _CalendarService_DeletePreferences_Handler,
+		},
+		{
+			MethodName: "GetTags",
+			Handler:    _CalendarService_GetTags_Handler,
+		},
+		{
+			MethodName: "SaveTag",
+			Handler:    _CalendarService_SaveTag_Handler,
+		},
+		{
+			MethodName: "DeleteTag",
+			Handler:    _CalendarService_DeleteTag_Handler,
+		},
+		{
+			MethodName: "CreateCalendar",
+			Handler:    _CalendarService_CreateCalendar_Handler,
+		},
+		{
+			MethodName: "UpdateCalendar",
+			Handler:    _CalendarService_UpdateCalendar_Handler,
+		},
+		{
+			MethodName: "DeleteCalendar",
+			Handler:    _CalendarService_DeleteCalendar_Handler,
+		},
+		{
+			MethodName: "GetCalendar",
+			Handler:    _CalendarService_GetCalendar_Handler,
+		},
+		{
+			MethodName: "GetCalendars",
+			Handler:    _CalendarService_GetCalendars_Handler,
+		},
+		{
+			MethodName: "ShareCalendar",
+			Handler:    _CalendarService_ShareCalendar_Handler,
+		},
+		{
+			MethodName: "UnshareCalendar",
+			Handler:    _CalendarService_UnshareCalendar_Handler,
+		},
+		{
+			MethodName: "GetShares",
+			Handler:    _CalendarService_GetShares_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "EventService.proto",
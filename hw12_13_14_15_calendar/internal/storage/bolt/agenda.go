@@ -0,0 +1,42 @@
+package boltstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (s *Storage) ClaimAgenda(_ context.Context, userID int64, day time.Time) (bool, error) {
+	if s.db == nil {
+		return false, ErrNotConnected
+	}
+
+	claimed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		key := agendaKey(userID, day)
+		if tx.Bucket(agendasBucket).Get(key) != nil {
+			return nil
+		}
+
+		claimed = true
+		return tx.Bucket(agendasBucket).Put(key, []byte{})
+	})
+
+	return claimed && err == nil, err
+}
+
+func (s *Storage) ReleaseAgenda(_ context.Context, userID int64, day time.Time) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(agendasBucket).Delete(agendaKey(userID, day))
+	})
+}
+
+func agendaKey(userID int64, day time.Time) []byte {
+	return append(userKey(userID), day.Format(storage.DayLayout)...)
+}
@@ -0,0 +1,393 @@
+package boltstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (s *Storage) CreateCalendar(_ context.Context, calendar *storage.Calendar) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if calendar.ID == uuid.Nil {
+			calendar.ID = uuid.New()
+		}
+
+		stored := *calendar
+		stored.Default = false
+		return putCalendar(tx, &stored)
+	})
+}
+
+func (s *Storage) UpdateCalendar(_ context.Context, calendar *storage.Calendar) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		updated, err := getCalendar(tx, calendar.ID)
+		if err != nil {
+			return err
+		}
+
+		// owner and default flag are kept
+		updated.Name = calendar.Name
+		updated.TimeZone = calendar.TimeZone
+		updated.Color = calendar.Color
+		return putCalendar(tx, updated)
+	})
+}
+
+func (s *Storage) DeleteCalendar(_ context.Context, calendarID uuid.UUID) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := getCalendar(tx, calendarID); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(calendarsBucket).Delete(calendarID[:]); err != nil {
+			return err
+		}
+
+		// collect first: bolt does not allow to modify bucket while iterating over it.
+		var keys [][]byte
+		c := tx.Bucket(sharesBucket).Cursor()
+		for k, _ := c.Seek(calendarID[:]); k != nil && bytes.HasPrefix(k, calendarID[:]); k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := tx.Bucket(sharesBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+
+		var events []*storage.Event
+		err := tx.Bucket(eventsBucket).ForEach(func(_, v []byte) error {
+			event := &storage.Event{}
+			if err := json.Unmarshal(v, event); err != nil {
+				return err
+			}
+			if event.CalendarID == calendarID {
+				events = append(events, event)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			s.notifyOnCommit(tx, event.ID)
+			if err := removeEvent(tx, event); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) GetCalendar(_ context.Context, calendarID uuid.UUID) (*storage.Calendar, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var calendar *storage.Calendar
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		calendar, err = getCalendar(tx, calendarID)
+		return err
+	})
+
+	return calendar, err
+}
+
+func (s *Storage) GetCalendars(_ context.Context, userID int64) ([]*storage.Calendar, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	calendars := []*storage.Calendar{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		visible, err := visibleCalendars(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		for id := range visible {
+			calendar, err := getCalendar(tx, id)
+			if err != nil {
+				return err
+			}
+			calendars = append(calendars, calendar)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(calendars, func(i, j int) bool {
+		return calendars[i].Name < calendars[j].Name
+	})
+
+	return calendars, nil
+}
+
+func (s *Storage) DefaultCalendar(_ context.Context, ownerID int64) (*storage.Calendar, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var calendar *storage.Calendar
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		calendar, err = defaultCalendar(tx, ownerID)
+		return err
+	})
+
+	return calendar, err
+}
+
+func (s *Storage) ShareCalendar(_ context.Context, share *storage.Share) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	data, err := json.Marshal(share)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := getCalendar(tx, share.CalendarID); err != nil {
+			return err
+		}
+
+		return tx.Bucket(sharesBucket).Put(shareKey(share.CalendarID, share.UserID), data)
+	})
+}
+
+func (s *Storage) UnshareCalendar(_ context.Context, calendarID uuid.UUID, userID int64) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sharesBucket)
+		if bucket.Get(shareKey(calendarID, userID)) == nil {
+			return storage.ErrShareNotFound
+		}
+
+		return bucket.Delete(shareKey(calendarID, userID))
+	})
+}
+
+func (s *Storage) GetShares(_ context.Context, calendarID uuid.UUID) ([]*storage.Share, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	shares := []*storage.Share{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// keys of the calendar are ordered by user
+		c := tx.Bucket(sharesBucket).Cursor()
+		for k, v := c.Seek(calendarID[:]); k != nil && bytes.HasPrefix(k, calendarID[:]); k, v = c.Next() {
+			share := &storage.Share{}
+			if err := json.Unmarshal(v, share); err != nil {
+				return err
+			}
+			shares = append(shares, share)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s *Storage) GetUserShares(_ context.Context, userID int64) ([]*storage.Share, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var shares []*storage.Share
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		shares, err = userShares(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// matcher combines tag and calendars of the filter.
+func matcher(tx *bolt.Tx, filter storage.EventFilter) (func(event *storage.Event) bool, error) {
+	if filter.UserID == 0 {
+		return filter.Match, nil
+	}
+
+	visible, err := visibleCalendars(tx, filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(event *storage.Event) bool {
+		_, found := visible[event.CalendarID]
+		return found && filter.Match(event)
+	}, nil
+}
+
+// returns calendars owned by or shared with the user.
+func visibleCalendars(tx *bolt.Tx, userID int64) (map[uuid.UUID]struct{}, error) {
+	visible := make(map[uuid.UUID]struct{})
+	err := tx.Bucket(calendarsBucket).ForEach(func(_, v []byte) error {
+		calendar := &storage.Calendar{}
+		if err := json.Unmarshal(v, calendar); err != nil {
+			return err
+		}
+		if calendar.OwnerID == userID {
+			visible[calendar.ID] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := userShares(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		visible[share.CalendarID] = struct{}{}
+	}
+
+	return visible, nil
+}
+
+// shares bucket is ordered by calendar, so shares of the user are found by full scan.
+func userShares(tx *bolt.Tx, userID int64) ([]*storage.Share, error) {
+	shares := []*storage.Share{}
+	user := userKey(userID)
+	err := tx.Bucket(sharesBucket).ForEach(func(k, v []byte) error {
+		if !bytes.Equal(k[len(k)-len(user):], user) {
+			return nil
+		}
+
+		share := &storage.Share{}
+		if err := json.Unmarshal(v, share); err != nil {
+			return err
+		}
+		shares = append(shares, share)
+		return nil
+	})
+
+	return shares, err
+}
+
+func defaultCalendar(tx *bolt.Tx, ownerID int64) (*storage.Calendar, error) {
+	var calendar *storage.Calendar
+	err := tx.Bucket(calendarsBucket).ForEach(func(_, v []byte) error {
+		if calendar != nil {
+			return nil
+		}
+
+		candidate := &storage.Calendar{}
+		if err := json.Unmarshal(v, candidate); err != nil {
+			return err
+		}
+		if candidate.OwnerID == ownerID && candidate.Default {
+			calendar = candidate
+		}
+		return nil
+	})
+	if err != nil || calendar != nil {
+		return calendar, err
+	}
+
+	calendar = &storage.Calendar{ID: uuid.New(), OwnerID: ownerID, Name: storage.DefaultCalendarName, Default: true}
+	return calendar, putCalendar(tx, calendar)
+}
+
+// moveToDefaultCalendars puts events created before calendars to default calendars of their users.
+func moveToDefaultCalendars(tx *bolt.Tx) error {
+	var events []*storage.Event
+	err := tx.Bucket(eventsBucket).ForEach(func(_, v []byte) error {
+		event := &storage.Event{}
+		if err := json.Unmarshal(v, event); err != nil {
+			return err
+		}
+		if event.CalendarID == uuid.Nil {
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		calendar, err := defaultCalendar(tx, event.UserID)
+		if err != nil {
+			return err
+		}
+
+		// indexes do not depend on calendar, so only the event itself is replaced
+		event.CalendarID = calendar.ID
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(eventsBucket).Put(event.ID[:], data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getCalendar(tx *bolt.Tx, calendarID uuid.UUID) (*storage.Calendar, error) {
+	data := tx.Bucket(calendarsBucket).Get(calendarID[:])
+	if data == nil {
+		return nil, storage.ErrCalendarNotFound
+	}
+
+	calendar := &storage.Calendar{}
+	if err := json.Unmarshal(data, calendar); err != nil {
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+func putCalendar(tx *bolt.Tx, calendar *storage.Calendar) error {
+	data, err := json.Marshal(calendar)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(calendarsBucket).Put(calendar.ID[:], data)
+}
+
+func shareKey(calendarID uuid.UUID, userID int64) []byte {
+	return append(append([]byte(nil), calendarID[:]...), userKey(userID)...)
+}
@@ -0,0 +1,122 @@
+package boltstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (s *Storage) GetPreferences(_ context.Context, userID int64) (*storage.Preferences, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var preferences *storage.Preferences
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(preferencesBucket).Get(userKey(userID))
+		if data == nil {
+			return storage.ErrPreferencesNotFound
+		}
+
+		preferences = &storage.Preferences{}
+		return json.Unmarshal(data, preferences)
+	})
+
+	return preferences, err
+}
+
+func (s *Storage) SavePreferences(_ context.Context, preferences *storage.Preferences) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(preferencesBucket).Put(userKey(preferences.UserID), data)
+	})
+}
+
+func (s *Storage) DeletePreferences(_ context.Context, userID int64) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(preferencesBucket)
+		if bucket.Get(userKey(userID)) == nil {
+			return storage.ErrPreferencesNotFound
+		}
+
+		return bucket.Delete(userKey(userID))
+	})
+}
+
+func (s *Storage) AddPendingNotification(_ context.Context, pending *storage.PendingNotification) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	if pending.ID == uuid.Nil {
+		pending.ID = uuid.New()
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).Put(indexKey(pending.DeliverAt, pending.ID), data)
+	})
+}
+
+func (s *Storage) TakePendingNotifications(_ context.Context, till time.Time) ([]*storage.PendingNotification, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var res []*storage.PendingNotification
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		end := timeKey(till)
+
+		var keys [][]byte
+		c := tx.Bucket(pendingBucket).Cursor()
+		for k, v := c.First(); k != nil && bytes.Compare(k[:timeKeyLen], end) <= 0; k, v = c.Next() {
+			pending := &storage.PendingNotification{}
+			if err := json.Unmarshal(v, pending); err != nil {
+				return err
+			}
+			res = append(res, pending)
+			keys = append(keys, k)
+		}
+
+		for _, k := range keys {
+			if err := tx.Bucket(pendingBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func userKey(userID int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(userID))
+	return key
+}
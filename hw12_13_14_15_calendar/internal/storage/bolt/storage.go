@@ -0,0 +1,685 @@
+package boltstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrNotConnected = errors.New("bolt storage is not connected")
+	ErrCorruptIndex = errors.New("bolt storage index points to missing event")
+)
+
+var (
+	// main bucket: event id -> json encoded event.
+	eventsBucket = []byte("events")
+	// index bucket: date_time + event id -> nothing.
+	dateIndexBucket = []byte("events_by_date")
+	// index bucket: notification_time + event id -> nothing (only not notified events).
+	notifyIndexBucket = []byte("events_for_notify")
+	// index bucket: end_time - date_time in big endian + event id -> nothing.
+	lengthIndexBucket = []byte("events_by_length")
+	// user id in big endian -> json encoded preferences.
+	preferencesBucket = []byte("preferences")
+	// deliver_at + notification id -> json encoded pending notification.
+	pendingBucket = []byte("pending_notifications")
+	// user id in big endian + day -> nothing.
+	agendasBucket = []byte("sent_agendas")
+	// user id in big endian + tag name -> json encoded tag.
+	tagsBucket = []byte("tags")
+	// calendar id -> json encoded calendar.
+	calendarsBucket = []byte("calendars")
+	// calendar id + user id in big endian -> json encoded share.
+	sharesBucket = []byte("calendar_shares")
+	// event id -> lease end in big endian unix nanoseconds + owner of the claim.
+	claimsBucket = []byte("notification_claims")
+)
+
+const (
+	timeKeyLen   = 12
+	indexKeyLen  = timeKeyLen + 16
+	lengthKeyLen = 8
+	openTimeout  = 3 * time.Second
+
+	watchBufferSize = 64
+)
+
+type Storage struct {
+	db   *bolt.DB
+	path string
+
+	// the file is locked by one process, so changes are reported to watchers of this storage only.
+	mu       sync.Mutex
+	watchers map[chan uuid.UUID]struct{}
+}
+
+func New(path string) *Storage {
+	return &Storage{
+		path:     path,
+		watchers: make(map[chan uuid.UUID]struct{}),
+	}
+}
+
+func (s *Storage) Connect(_ context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		lengthsIndexed := tx.Bucket(lengthIndexBucket) != nil
+		for _, name := range [][]byte{
+			eventsBucket, dateIndexBucket, notifyIndexBucket, lengthIndexBucket, preferencesBucket, pendingBucket,
+			agendasBucket, tagsBucket, calendarsBucket, sharesBucket, claimsBucket,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if err := fillEndTimes(tx); err != nil {
+			return err
+		}
+		if !lengthsIndexed {
+			if err := indexLengths(tx); err != nil {
+				return err
+			}
+		}
+		return moveToDefaultCalendars(tx)
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+
+	return nil
+}
+
+func (s *Storage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+func (s *Storage) CreateEvent(_ context.Context, event *storage.Event) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if event.ID == uuid.Nil {
+			event.ID = uuid.New()
+		}
+
+		if tx.Bucket(eventsBucket).Get(event.ID[:]) != nil {
+			return storage.ErrEventAlreadyExists
+		}
+
+		if isBusy(tx, event) {
+			return storage.ErrEventDateTimeIsBusy
+		}
+
+		stored := event.Copy()
+		stored.Tags = storage.SortTags(event.Tags)
+		if err := addTags(tx, stored); err != nil {
+			return err
+		}
+
+		s.notifyOnCommit(tx, stored.ID)
+		return putEvent(tx, stored)
+	})
+}
+
+func (s *Storage) UpdateEvent(_ context.Context, eventID uuid.UUID, event *storage.Event) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		current, err := getEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
+
+		updated := event.Copy()
+		updated.ID = eventID
+		updated.Tags = storage.SortTags(event.Tags)
+
+		if isBusy(tx, updated) {
+			return storage.ErrEventDateTimeIsBusy
+		}
+
+		if err := addTags(tx, updated); err != nil {
+			return err
+		}
+
+		if err := deleteEvent(tx, current); err != nil {
+			return err
+		}
+
+		s.notifyOnCommit(tx, eventID)
+		return putEvent(tx, updated)
+	})
+}
+
+func (s *Storage) DeleteEvent(_ context.Context, eventID uuid.UUID) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		event, err := getEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
+
+		s.notifyOnCommit(tx, eventID)
+		return removeEvent(tx, event)
+	})
+}
+
+func (s *Storage) GetEvent(_ context.Context, eventID uuid.UUID) (*storage.Event, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var event *storage.Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		event, err = getEvent(tx, eventID)
+		return err
+	})
+
+	return event, err
+}
+
+func (s *Storage) GetEvents(_ context.Context, filter storage.EventFilter) ([]*storage.Event, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var events []*storage.Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		match, err := matcher(tx, filter)
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(dateIndexBucket).ForEach(func(k, _ []byte) error {
+			event, err := getEventByIndexKey(tx, k)
+			if err != nil {
+				return err
+			}
+			if match(event) {
+				events = append(events, event)
+			}
+			return nil
+		})
+	})
+
+	return events, err
+}
+
+func (s *Storage) GetEventByDate(
+	_ context.Context,
+	eventDatetime time.Time,
+	filter storage.EventFilter,
+) (*storage.Event, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var event *storage.Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		match, err := matcher(tx, filter)
+		if err != nil {
+			return err
+		}
+
+		// events of other users may start at the same time, the first matching one is returned.
+		prefix := timeKey(eventDatetime)
+		c := tx.Bucket(dateIndexBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if event, err = getEventByIndexKey(tx, k); err != nil {
+				return err
+			}
+			if match(event) {
+				return nil
+			}
+		}
+		event = nil
+
+		return storage.ErrEventNotFound
+	})
+
+	return event, err
+}
+
+// general mehtod for getting events by date range.
+func (s *Storage) getEventsForRange(
+	startRange time.Time,
+	endRange time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var events []*storage.Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		match, err := matcher(tx, filter)
+		if err != nil {
+			return err
+		}
+
+		// all-day events are compared with the wall clock of the range, it may be earlier or later
+		// than the range itself.
+		from, to := startRange, endRange
+		if wallClock := storage.WallClock(startRange); wallClock.Before(from) {
+			from = wallClock
+		}
+		if wallClock := storage.WallClock(endRange); wallClock.After(to) {
+			to = wallClock
+		}
+
+		// events that overlap the range start no earlier than the longest event before it.
+		from = from.Add(-maxLength(tx))
+		end := timeKey(to)
+
+		c := tx.Bucket(dateIndexBucket).Cursor()
+		for k, _ := c.Seek(timeKey(from)); k != nil && bytes.Compare(k[:timeKeyLen], end) < 0; k, _ = c.Next() {
+			event, err := getEventByIndexKey(tx, k)
+			if err != nil {
+				return err
+			}
+			if event.Overlaps(startRange, endRange) && match(event) {
+				events = append(events, event)
+			}
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (s *Storage) GetEventsForDay(
+	_ context.Context,
+	startOfDay time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(startOfDay, startOfDay.AddDate(0, 0, 1), filter)
+}
+
+func (s *Storage) GetEventsForWeek(
+	_ context.Context,
+	startOfWeek time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(startOfWeek, startOfWeek.AddDate(0, 0, 7), filter)
+}
+
+func (s *Storage) GetEventsForMonth(
+	_ context.Context,
+	startOfMonth time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(startOfMonth, startOfMonth.AddDate(0, 1, 0), filter)
+}
+
+func (s *Storage) GetEventsForNotifications(ctx context.Context) ([]*storage.Event, error) {
+	events, err := s.GetUpcomingNotifications(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].DateTime.Before(events[j].DateTime)
+	})
+
+	return events, nil
+}
+
+func (s *Storage) GetUpcomingNotifications(_ context.Context, till time.Time) ([]*storage.Event, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var events []*storage.Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		end := timeKey(till)
+
+		c := tx.Bucket(notifyIndexBucket).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:timeKeyLen], end) <= 0; k, _ = c.Next() {
+			event, err := getEventByIndexKey(tx, k)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (s *Storage) ClaimEventsForNotifications(
+	_ context.Context,
+	owner string,
+	lease time.Duration,
+	limit int,
+) ([]*storage.Event, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	var events []*storage.Event
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		end := timeKey(now)
+		claims := tx.Bucket(claimsBucket)
+
+		c := tx.Bucket(notifyIndexBucket).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:timeKeyLen], end) <= 0; k, _ = c.Next() {
+			event, err := getEventByIndexKey(tx, k)
+			if err != nil {
+				return err
+			}
+			if !leased(claims.Get(event.ID[:]), now) {
+				events = append(events, event)
+			}
+		}
+
+		// the same order as GetEventsForNotifications.
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].DateTime.Before(events[j].DateTime)
+		})
+		if len(events) > limit {
+			events = events[:limit]
+		}
+
+		for _, event := range events {
+			if err := claims.Put(event.ID[:], claimValue(owner, now.Add(lease))); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (s *Storage) DeleteOldEvents(_ context.Context, duration time.Duration) (int, error) {
+	if s.db == nil {
+		return 0, ErrNotConnected
+	}
+
+	counter := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		border := time.Now().Add(-duration)
+		borderKey := timeKey(border)
+
+		// collect first: bolt does not allow to modify bucket while iterating over it.
+		var old []*storage.Event
+		c := tx.Bucket(dateIndexBucket).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:timeKeyLen], borderKey) < 0; k, _ = c.Next() {
+			event, err := getEventByIndexKey(tx, k)
+			if err != nil {
+				return err
+			}
+			if event.Ended(border) {
+				old = append(old, event)
+			}
+		}
+
+		for _, event := range old {
+			if err := removeEvent(tx, event); err != nil {
+				return err
+			}
+			counter++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
+// check if another event of the same user already takes this time.
+func isBusy(tx *bolt.Tx, event *storage.Event) bool {
+	prefix := timeKey(event.DateTime)
+
+	c := tx.Bucket(dateIndexBucket).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		other, err := getEventByIndexKey(tx, k)
+		if err != nil {
+			continue
+		}
+		if other.ID != event.ID && other.UserID == event.UserID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func putEvent(tx *bolt.Tx, event *storage.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(eventsBucket).Put(event.ID[:], data); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(dateIndexBucket).Put(indexKey(event.DateTime, event.ID), nil); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(lengthIndexBucket).Put(lengthKey(event), nil); err != nil {
+		return err
+	}
+
+	if needNotification(event) {
+		return tx.Bucket(notifyIndexBucket).Put(indexKey(event.TimeNotification, event.ID), nil)
+	}
+
+	return nil
+}
+
+func deleteEvent(tx *bolt.Tx, event *storage.Event) error {
+	if err := tx.Bucket(eventsBucket).Delete(event.ID[:]); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(dateIndexBucket).Delete(indexKey(event.DateTime, event.ID)); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(lengthIndexBucket).Delete(lengthKey(event)); err != nil {
+		return err
+	}
+
+	if needNotification(event) {
+		return tx.Bucket(notifyIndexBucket).Delete(indexKey(event.TimeNotification, event.ID))
+	}
+
+	return nil
+}
+
+// removeEvent deletes the event with its claim, deleteEvent keeps the claim when the event is replaced.
+func removeEvent(tx *bolt.Tx, event *storage.Event) error {
+	if err := deleteEvent(tx, event); err != nil {
+		return err
+	}
+
+	return tx.Bucket(claimsBucket).Delete(event.ID[:])
+}
+
+func getEvent(tx *bolt.Tx, eventID uuid.UUID) (*storage.Event, error) {
+	data := tx.Bucket(eventsBucket).Get(eventID[:])
+	if data == nil {
+		return nil, storage.ErrEventNotFound
+	}
+
+	event := &storage.Event{}
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func getEventByIndexKey(tx *bolt.Tx, key []byte) (*storage.Event, error) {
+	eventID, err := uuid.FromBytes(key[timeKeyLen:])
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := getEvent(tx, eventID)
+	if errors.Is(err, storage.ErrEventNotFound) {
+		return nil, ErrCorruptIndex
+	}
+
+	return event, err
+}
+
+// maxLegacyDuration bounds durations of events saved by older versions, some clients wrote Unix timestamps
+// there: events with longer durations take no time instead of lasting for decades.
+const maxLegacyDuration = 366 * 24 * time.Hour
+
+// fillEndTimes converts duration of events saved by older versions to end time.
+func fillEndTimes(tx *bolt.Tx) error {
+	var events []*storage.Event
+	err := tx.Bucket(eventsBucket).ForEach(func(_, v []byte) error {
+		var event struct {
+			storage.Event
+			Duration int64 `json:"duration"` // seconds
+		}
+		if err := json.Unmarshal(v, &event); err != nil {
+			return err
+		}
+		if event.EndTime.IsZero() {
+			event.EndTime = event.DateTime
+			if event.Duration > 0 && event.Duration < int64(maxLegacyDuration/time.Second) {
+				event.EndTime = event.DateTime.Add(time.Duration(event.Duration) * time.Second)
+			}
+			events = append(events, &event.Event)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// other indexes do not depend on end time, so only events themselves and their lengths are replaced
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(eventsBucket).Put(event.ID[:], data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(lengthIndexBucket).Put(lengthKey(event), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexLengths fills the index of lengths for events saved by older versions.
+func indexLengths(tx *bolt.Tx) error {
+	return tx.Bucket(eventsBucket).ForEach(func(_, v []byte) error {
+		event := &storage.Event{}
+		if err := json.Unmarshal(v, event); err != nil {
+			return err
+		}
+		return tx.Bucket(lengthIndexBucket).Put(lengthKey(event), nil)
+	})
+}
+
+// maxLength returns the length of the longest event.
+func maxLength(tx *bolt.Tx) time.Duration {
+	k, _ := tx.Bucket(lengthIndexBucket).Cursor().Last()
+	if k == nil {
+		return 0
+	}
+
+	return time.Duration(binary.BigEndian.Uint64(k[:lengthKeyLen]))
+}
+
+func needNotification(event *storage.Event) bool {
+	return !event.TimeNotification.IsZero() && event.NotifyAt.IsZero()
+}
+
+// sortable representation of time: unix seconds with flipped sign bit and nanoseconds, both in big endian.
+func claimValue(owner string, until time.Time) []byte {
+	value := make([]byte, 8, 8+len(owner))
+	binary.BigEndian.PutUint64(value, uint64(until.UnixNano()))
+
+	return append(value, owner...)
+}
+
+// leased checks that the claim is not expired yet.
+func leased(claim []byte, now time.Time) bool {
+	if len(claim) < 8 {
+		return false
+	}
+
+	return int64(binary.BigEndian.Uint64(claim)) > now.UnixNano()
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, timeKeyLen)
+	binary.BigEndian.PutUint64(key, uint64(t.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(key[8:], uint32(t.Nanosecond()))
+	return key
+}
+
+// lengthKey keeps events without end time or ending before the start as zero-length ones.
+func lengthKey(event *storage.Event) []byte {
+	var length time.Duration
+	if event.EndTime.After(event.DateTime) {
+		length = event.EndTime.Sub(event.DateTime)
+	}
+
+	key := make([]byte, lengthKeyLen, lengthKeyLen+16)
+	binary.BigEndian.PutUint64(key, uint64(length))
+	return append(key, event.ID[:]...)
+}
+
+func indexKey(t time.Time, eventID uuid.UUID) []byte {
+	key := make([]byte, 0, indexKeyLen)
+	key = append(key, timeKey(t)...)
+	return append(key, eventID[:]...)
+}
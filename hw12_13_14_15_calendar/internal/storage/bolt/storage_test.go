@@ -0,0 +1,191 @@
+package boltstorage
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func randomTimeGenerator() time.Time {
+	return time.Unix(rand.Int63n(time.Now().Unix()-94608000)+94608000, 0)
+}
+
+func newStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	st := New(filepath.Join(t.TempDir(), "calendar.db"))
+	require.NoError(t, st.Connect(context.Background()))
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	return st
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newStorage(t)
+	})
+}
+
+func TestIndexesAfterUpdate(t *testing.T) {
+	st := newStorage(t)
+	start := randomTimeGenerator()
+
+	event := &storage.Event{
+		ID:               uuid.New(),
+		Title:            "Event",
+		DateTime:         start,
+		TimeNotification: time.Now().Add(-time.Minute),
+	}
+	require.NoError(t, st.CreateEvent(context.Background(), event))
+
+	// move event to another day and mark as notified: old index entries must go away
+	event.DateTime = start.AddDate(0, 0, 3)
+	event.NotifyAt = time.Now()
+	require.NoError(t, st.UpdateEvent(context.Background(), event.ID, event))
+
+	events, err := st.GetEventsForDay(context.Background(), start, storage.EventFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	events, err = st.GetEventsForNotifications(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, err = st.GetEventByDate(context.Background(), start.AddDate(0, 0, 3), storage.EventFilter{})
+	assert.NoError(t, err)
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.db")
+	event := &storage.Event{ID: uuid.New(), Title: "Durable", DateTime: randomTimeGenerator()}
+
+	st := New(path)
+	require.NoError(t, st.Connect(context.Background()))
+	require.NoError(t, st.CreateEvent(context.Background(), event))
+	require.NoError(t, st.Close())
+
+	st = New(path)
+	require.NoError(t, st.Connect(context.Background()))
+	defer st.Close()
+
+	stored, err := st.GetEvent(context.Background(), event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Durable", stored.Title)
+
+	// event without calendar is moved to the default calendar of its user on connect
+	calendar, err := st.DefaultCalendar(context.Background(), event.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, calendar.ID, stored.CalendarID)
+}
+
+func TestLegacyDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.db")
+	start := randomTimeGenerator()
+
+	// older versions kept duration in seconds instead of end time, some clients wrote Unix timestamps there
+	durations := map[uuid.UUID]int64{
+		uuid.New(): 5400,
+		uuid.New(): 1700000000,
+		uuid.New(): -60,
+	}
+	ends := make(map[uuid.UUID]time.Time)
+
+	st := New(path)
+	require.NoError(t, st.Connect(context.Background()))
+	for eventID, duration := range durations {
+		event := &storage.Event{ID: eventID, Title: "Old", DateTime: start, UserID: int64(len(ends) + 1)}
+		require.NoError(t, st.CreateEvent(context.Background(), event))
+
+		err := st.db.Update(func(tx *bolt.Tx) error {
+			data, err := json.Marshal(map[string]any{
+				"id": eventID, "title": "Old", "date_time": start, "user_id": event.UserID, "duration": duration,
+			})
+			if err != nil {
+				return err
+			}
+			return tx.Bucket(eventsBucket).Put(eventID[:], data)
+		})
+		require.NoError(t, err)
+
+		ends[eventID] = start
+		if duration == 5400 {
+			ends[eventID] = start.Add(90 * time.Minute)
+		}
+	}
+	require.NoError(t, st.Close())
+
+	st = New(path)
+	require.NoError(t, st.Connect(context.Background()))
+	defer st.Close()
+
+	for eventID, end := range ends {
+		stored, err := st.GetEvent(context.Background(), eventID)
+		require.NoError(t, err)
+		assert.True(t, end.Equal(stored.EndTime), "duration %d, end %s", durations[eventID], stored.EndTime)
+	}
+
+	// events with timestamps ended long ago, so they are deleted as old ones
+	deleted, err := st.DeleteOldEvents(context.Background(), time.Since(start.Add(2*time.Hour)))
+	require.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	events, err := st.GetEvents(context.Background(), storage.EventFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestLengthIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calendar.db")
+	start := randomTimeGenerator()
+
+	st := New(path)
+	require.NoError(t, st.Connect(context.Background()))
+
+	project := &storage.Event{Title: "Project", DateTime: start, EndTime: start.AddDate(0, 0, 30), UserID: 1}
+	meeting := &storage.Event{Title: "Meeting", DateTime: start.AddDate(0, 0, 40), UserID: 1}
+	meeting.EndTime = meeting.DateTime.Add(time.Hour)
+	require.NoError(t, st.CreateEvent(context.Background(), project))
+	require.NoError(t, st.CreateEvent(context.Background(), meeting))
+
+	requireMaxLength := func(expected time.Duration) {
+		t.Helper()
+		require.NoError(t, st.db.View(func(tx *bolt.Tx) error {
+			assert.Equal(t, expected, maxLength(tx))
+			return nil
+		}))
+	}
+	requireMaxLength(30 * 24 * time.Hour)
+
+	// ranges are scanned from the start of the longest event before them
+	events, err := st.GetEventsForDay(context.Background(), start.AddDate(0, 0, 29), storage.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, project.ID, events[0].ID)
+
+	require.NoError(t, st.DeleteEvent(context.Background(), project.ID))
+	requireMaxLength(time.Hour)
+
+	// files of older versions are indexed on connect
+	require.NoError(t, st.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(lengthIndexBucket)
+	}))
+	require.NoError(t, st.Close())
+
+	st = New(path)
+	require.NoError(t, st.Connect(context.Background()))
+	defer st.Close()
+
+	requireMaxLength(time.Hour)
+}
@@ -0,0 +1,132 @@
+package boltstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (s *Storage) GetTags(_ context.Context, userID int64) ([]*storage.Tag, error) {
+	if s.db == nil {
+		return nil, ErrNotConnected
+	}
+
+	tags := []*storage.Tag{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := userKey(userID)
+
+		// keys of the user are ordered by tag name
+		c := tx.Bucket(tagsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			tag := &storage.Tag{}
+			if err := json.Unmarshal(v, tag); err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *Storage) SaveTag(_ context.Context, tag *storage.Tag) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putTag(tx, tag)
+	})
+}
+
+func (s *Storage) DeleteTag(_ context.Context, userID int64, name string) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tagsBucket)
+		if bucket.Get(tagKey(userID, name)) == nil {
+			return storage.ErrTagNotFound
+		}
+
+		if err := bucket.Delete(tagKey(userID, name)); err != nil {
+			return err
+		}
+
+		// collect first: bolt does not allow to modify bucket while iterating over it.
+		filter := storage.EventFilter{Tag: name}
+		var tagged []*storage.Event
+		err := tx.Bucket(eventsBucket).ForEach(func(_, v []byte) error {
+			event := &storage.Event{}
+			if err := json.Unmarshal(v, event); err != nil {
+				return err
+			}
+			if event.UserID == userID && filter.Match(event) {
+				tagged = append(tagged, event)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, event := range tagged {
+			var tags []string
+			for _, tag := range event.Tags {
+				if tag != name {
+					tags = append(tags, tag)
+				}
+			}
+			event.Tags = tags
+
+			// indexes do not depend on tags, so only the event itself is replaced
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(eventsBucket).Put(event.ID[:], data); err != nil {
+				return err
+			}
+			s.notifyOnCommit(tx, event.ID)
+		}
+
+		return nil
+	})
+}
+
+// creates missing tags of the event.
+func addTags(tx *bolt.Tx, event *storage.Event) error {
+	for _, name := range event.Tags {
+		if tx.Bucket(tagsBucket).Get(tagKey(event.UserID, name)) != nil {
+			continue
+		}
+
+		if err := putTag(tx, &storage.Tag{UserID: event.UserID, Name: name}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func putTag(tx *bolt.Tx, tag *storage.Tag) error {
+	data, err := json.Marshal(tag)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(tagsBucket).Put(tagKey(tag.UserID, tag.Name), data)
+}
+
+func tagKey(userID int64, name string) []byte {
+	return append(userKey(userID), name...)
+}
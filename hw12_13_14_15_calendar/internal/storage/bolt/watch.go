@@ -0,0 +1,49 @@
+package boltstorage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// WatchEvents subscribes to changes made through this storage, they are reported after the transaction
+// is committed. Subscriber that does not keep up misses changes, so it should reload events from time to time.
+func (s *Storage) WatchEvents(ctx context.Context) (<-chan uuid.UUID, error) {
+	ch := make(chan uuid.UUID, watchBufferSize)
+
+	s.mu.Lock()
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.mu.Lock()
+		delete(s.watchers, ch)
+		close(ch)
+		s.mu.Unlock()
+	}()
+
+	return ch, nil
+}
+
+// notifyOnCommit reports the changed event when the transaction is committed, rolled back changes are not reported.
+func (s *Storage) notifyOnCommit(tx *bolt.Tx, eventID uuid.UUID) {
+	tx.OnCommit(func() {
+		s.notify(eventID)
+	})
+}
+
+// sends changed event ID to subscribers without blocking.
+func (s *Storage) notify(eventID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.watchers {
+		select {
+		case ch <- eventID:
+		default:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCalendarNotFound = errors.New("calendar not found")
+	ErrShareNotFound    = errors.New("share not found")
+)
+
+// DefaultCalendarName is the name of the calendar created for events without calendar.
+const DefaultCalendarName = "Default"
+
+// Calendar groups events of the owner. Every owner has one default calendar, it is created on demand.
+type Calendar struct {
+	ID       uuid.UUID `json:"id"`
+	OwnerID  int64     `json:"owner_id"` //nolint:tagliatelle
+	Name     string    `json:"name"`
+	TimeZone string    `json:"time_zone"` //nolint:tagliatelle
+	Color    string    `json:"color"`
+	Default  bool      `json:"default"`
+}
+
+// Role is access of the user to the calendar of another user.
+type Role string
+
+const (
+	// RoleFreeBusy shows only when events take place, without details.
+	RoleFreeBusy Role = "free_busy"
+	RoleRead     Role = "read"
+	// RoleWrite allows to create and change events in the calendar.
+	RoleWrite Role = "write"
+)
+
+// Share gives the user access to the calendar.
+type Share struct {
+	CalendarID uuid.UUID `json:"calendar_id"` //nolint:tagliatelle
+	UserID     int64     `json:"user_id"`     //nolint:tagliatelle
+	Role       Role      `json:"role"`
+}
@@ -13,15 +13,56 @@ var (
 	ErrEventDateTimeIsBusy = errors.New("this time is busy")
 )
 
+// Event takes time from DateTime till EndTime exclusive, EndTime equal to DateTime means the event
+// takes no time. All-day events keep dates, not instants: DateTime is the midnight of the first day
+// in UTC and EndTime is the midnight of the day after the last one, so the event is on the same days
+// in every time zone.
 type Event struct {
 	ID               uuid.UUID `json:"id"`
+	CalendarID       uuid.UUID `json:"calendar_id"` //nolint:tagliatelle
 	Title            string    `json:"title"`
 	DateTime         time.Time `json:"date_time"` //nolint:tagliatelle
-	Duration         int64     `json:"duration"`
+	EndTime          time.Time `json:"end_time"`  //nolint:tagliatelle
+	AllDay           bool      `json:"all_day"`   //nolint:tagliatelle
 	Description      string    `json:"description"`
 	UserID           int64     `json:"user_id"`           //nolint:tagliatelle
 	TimeNotification time.Time `json:"time_notification"` //nolint:tagliatelle
 	NotifyAt         time.Time `json:"notify_at"`         //nolint:tagliatelle
+	Color            string    `json:"color,omitempty"`
+	Location         string    `json:"location,omitempty"`
+	// Tags are names of tags of the user, sorted and unique.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Copy returns event that does not share tags with the original.
+func (e *Event) Copy() *Event {
+	res := *e
+	res.Tags = append([]string(nil), e.Tags...)
+	return &res
+}
+
+// Overlaps reports whether the event takes some time of the range [start, end), events without
+// duration overlap the range they start in. All-day events are compared with the wall clock of the range.
+func (e *Event) Overlaps(start, end time.Time) bool {
+	if e.AllDay {
+		start, end = WallClock(start), WallClock(end)
+	}
+
+	if e.EndTime.After(e.DateTime) {
+		return e.DateTime.Before(end) && e.EndTime.After(start)
+	}
+
+	return !e.DateTime.Before(start) && e.DateTime.Before(end)
+}
+
+// Ended reports whether the event is over before t.
+func (e *Event) Ended(t time.Time) bool {
+	return e.DateTime.Before(t) && !e.EndTime.After(t)
+}
+
+// WallClock returns the time with the same date and clock in UTC, all-day events are kept in such form.
+func WallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
 }
 
 type Notification struct {
@@ -29,4 +70,20 @@ type Notification struct {
 	Title    string    `json:"title"`
 	DateTime time.Time `json:"date_time"` //nolint:tagliatelle
 	UserID   int64     `json:"user_id"`   //nolint:tagliatelle
+	// AllDay means DateTime is the date of the event in UTC, its time is meaningless.
+	AllDay bool `json:"all_day,omitempty"` //nolint:tagliatelle
+}
+
+const (
+	// AgendaContentType marks broker messages with Agenda, other messages contain Notification.
+	AgendaContentType = "application/vnd.calendar.agenda+json"
+	// DayLayout is used to store the day of sent agenda.
+	DayLayout = "2006-01-02"
+)
+
+// Agenda is a summary of the day for the user.
+type Agenda struct {
+	UserID int64          `json:"user_id"` //nolint:tagliatelle
+	Day    time.Time      `json:"day"`
+	Events []Notification `json:"events"`
 }
@@ -0,0 +1,34 @@
+package memorystorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+type agendaKey struct {
+	userID int64
+	day    string
+}
+
+func (s *Storage) ClaimAgenda(_ context.Context, userID int64, day time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := agendaKey{userID: userID, day: day.Format(storage.DayLayout)}
+	if _, claimed := s.agendas[key]; claimed {
+		return false, nil
+	}
+
+	s.agendas[key] = struct{}{}
+	return true, nil
+}
+
+func (s *Storage) ReleaseAgenda(_ context.Context, userID int64, day time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.agendas, agendaKey{userID: userID, day: day.Format(storage.DayLayout)})
+	return nil
+}
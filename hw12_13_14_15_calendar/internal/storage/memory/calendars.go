@@ -0,0 +1,208 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+func (s *Storage) CreateCalendar(_ context.Context, calendar *storage.Calendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if calendar.ID == uuid.Nil {
+		calendar.ID = uuid.New()
+	}
+
+	stored := *calendar
+	stored.Default = false
+	s.calendars[calendar.ID] = &stored
+	return nil
+}
+
+func (s *Storage) UpdateCalendar(_ context.Context, calendar *storage.Calendar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, found := s.calendars[calendar.ID]
+	if !found {
+		return storage.ErrCalendarNotFound
+	}
+
+	// owner and default flag are kept
+	updated := *current
+	updated.Name = calendar.Name
+	updated.TimeZone = calendar.TimeZone
+	updated.Color = calendar.Color
+	s.calendars[calendar.ID] = &updated
+	return nil
+}
+
+func (s *Storage) DeleteCalendar(_ context.Context, calendarID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.calendars[calendarID]; !found {
+		return storage.ErrCalendarNotFound
+	}
+
+	delete(s.calendars, calendarID)
+	delete(s.shares, calendarID)
+	for id, event := range s.events {
+		if event.CalendarID == calendarID {
+			delete(s.events, id)
+			delete(s.claims, id)
+			s.notify(id)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) GetCalendar(_ context.Context, calendarID uuid.UUID) (*storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	calendar, found := s.calendars[calendarID]
+	if !found {
+		return nil, storage.ErrCalendarNotFound
+	}
+
+	res := *calendar
+	return &res, nil
+}
+
+func (s *Storage) GetCalendars(_ context.Context, userID int64) ([]*storage.Calendar, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	visible := s.visibleCalendars(userID)
+	res := make([]*storage.Calendar, 0, len(visible))
+	for id := range visible {
+		calendar := *s.calendars[id]
+		res = append(res, &calendar)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+func (s *Storage) DefaultCalendar(_ context.Context, ownerID int64) (*storage.Calendar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, calendar := range s.calendars {
+		if calendar.OwnerID == ownerID && calendar.Default {
+			res := *calendar
+			return &res, nil
+		}
+	}
+
+	calendar := &storage.Calendar{ID: uuid.New(), OwnerID: ownerID, Name: storage.DefaultCalendarName, Default: true}
+	s.calendars[calendar.ID] = calendar
+
+	res := *calendar
+	return &res, nil
+}
+
+func (s *Storage) ShareCalendar(_ context.Context, share *storage.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.calendars[share.CalendarID]; !found {
+		return storage.ErrCalendarNotFound
+	}
+
+	shares, found := s.shares[share.CalendarID]
+	if !found {
+		shares = make(map[int64]*storage.Share)
+		s.shares[share.CalendarID] = shares
+	}
+
+	stored := *share
+	shares[share.UserID] = &stored
+	return nil
+}
+
+func (s *Storage) UnshareCalendar(_ context.Context, calendarID uuid.UUID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.shares[calendarID][userID]; !found {
+		return storage.ErrShareNotFound
+	}
+
+	delete(s.shares[calendarID], userID)
+	return nil
+}
+
+func (s *Storage) GetShares(_ context.Context, calendarID uuid.UUID) ([]*storage.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]*storage.Share, 0, len(s.shares[calendarID]))
+	for _, share := range s.shares[calendarID] {
+		copied := *share
+		res = append(res, &copied)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].UserID < res[j].UserID
+	})
+
+	return res, nil
+}
+
+func (s *Storage) GetUserShares(_ context.Context, userID int64) ([]*storage.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := []*storage.Share{}
+	for _, shares := range s.shares {
+		if share, found := shares[userID]; found {
+			copied := *share
+			res = append(res, &copied)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CalendarID.String() < res[j].CalendarID.String()
+	})
+
+	return res, nil
+}
+
+// returns calendars owned by or shared with the user. Should be called under lock.
+func (s *Storage) visibleCalendars(userID int64) map[uuid.UUID]struct{} {
+	visible := make(map[uuid.UUID]struct{})
+	for id, calendar := range s.calendars {
+		if calendar.OwnerID == userID {
+			visible[id] = struct{}{}
+		}
+	}
+	for id, shares := range s.shares {
+		if _, found := shares[userID]; found {
+			visible[id] = struct{}{}
+		}
+	}
+
+	return visible
+}
+
+// matcher combines tag and calendars of the filter. Should be called under lock.
+func (s *Storage) matcher(filter storage.EventFilter) func(event *storage.Event) bool {
+	if filter.UserID == 0 {
+		return filter.Match
+	}
+
+	visible := s.visibleCalendars(filter.UserID)
+	return func(event *storage.Event) bool {
+		_, found := visible[event.CalendarID]
+		return found && filter.Match(event)
+	}
+}
@@ -0,0 +1,76 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+func (s *Storage) GetPreferences(_ context.Context, userID int64) (*storage.Preferences, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	preferences, found := s.preferences[userID]
+	if !found {
+		return nil, storage.ErrPreferencesNotFound
+	}
+
+	res := *preferences
+	return &res, nil
+}
+
+func (s *Storage) SavePreferences(_ context.Context, preferences *storage.Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *preferences
+	s.preferences[preferences.UserID] = &stored
+	return nil
+}
+
+func (s *Storage) DeletePreferences(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.preferences[userID]; !found {
+		return storage.ErrPreferencesNotFound
+	}
+
+	delete(s.preferences, userID)
+	return nil
+}
+
+func (s *Storage) AddPendingNotification(_ context.Context, pending *storage.PendingNotification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending.ID == uuid.Nil {
+		pending.ID = uuid.New()
+	}
+
+	stored := *pending
+	s.pending[pending.ID] = &stored
+	return nil
+}
+
+func (s *Storage) TakePendingNotifications(_ context.Context, till time.Time) ([]*storage.PendingNotification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []*storage.PendingNotification
+	for id, pending := range s.pending {
+		if !pending.DeliverAt.After(till) {
+			res = append(res, pending)
+			delete(s.pending, id)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].DeliverAt.Before(res[j].DeliverAt)
+	})
+
+	return res, nil
+}
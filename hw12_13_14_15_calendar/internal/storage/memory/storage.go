@@ -2,22 +2,45 @@ package memorystorage
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
 	"github.com/google/uuid"
-	"golang.org/x/exp/maps"
 )
 
+const watchBufferSize = 64
+
 type Storage struct {
-	mu     sync.RWMutex
-	events map[uuid.UUID]*storage.Event
+	mu          sync.RWMutex
+	events      map[uuid.UUID]*storage.Event
+	claims      map[uuid.UUID]claim
+	watchers    map[chan uuid.UUID]struct{}
+	calendars   map[uuid.UUID]*storage.Calendar
+	shares      map[uuid.UUID]map[int64]*storage.Share
+	preferences map[int64]*storage.Preferences
+	tags        map[int64]map[string]*storage.Tag
+	pending     map[uuid.UUID]*storage.PendingNotification
+	agendas     map[agendaKey]struct{}
+}
+
+type claim struct {
+	owner string
+	until time.Time
 }
 
 func New() *Storage {
 	return &Storage{
-		events: make(map[uuid.UUID]*storage.Event),
+		events:      make(map[uuid.UUID]*storage.Event),
+		claims:      make(map[uuid.UUID]claim),
+		watchers:    make(map[chan uuid.UUID]struct{}),
+		calendars:   make(map[uuid.UUID]*storage.Calendar),
+		shares:      make(map[uuid.UUID]map[int64]*storage.Share),
+		preferences: make(map[int64]*storage.Preferences),
+		tags:        make(map[int64]map[string]*storage.Tag),
+		pending:     make(map[uuid.UUID]*storage.PendingNotification),
+		agendas:     make(map[agendaKey]struct{}),
 	}
 }
 
@@ -33,28 +56,47 @@ func (s *Storage) CreateEvent(_ context.Context, event *storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+
 	if _, found := s.events[event.ID]; found {
 		return storage.ErrEventAlreadyExists
 	}
 
-	s.events[event.ID] = event
+	if s.isBusy(event) {
+		return storage.ErrEventDateTimeIsBusy
+	}
+
+	stored := event.Copy()
+	stored.Tags = storage.SortTags(event.Tags)
+	s.events[event.ID] = stored
+	s.addTags(stored)
+	s.notify(event.ID)
 	return nil
 }
 
-func (s *Storage) UpdateEvent(ctx context.Context, eventID uuid.UUID, event *storage.Event) error {
-	// same id
+func (s *Storage) UpdateEvent(_ context.Context, eventID uuid.UUID, event *storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
+	// same id
 	if _, found := s.events[eventID]; !found {
 		return storage.ErrEventNotFound
 	}
 
+	updated := event.Copy()
+	updated.ID = eventID
+	updated.Tags = storage.SortTags(event.Tags)
+
 	// busy time
-	if _, err := s.GetEventByDate(ctx, event.DateTime); err == nil {
+	if s.isBusy(updated) {
 		return storage.ErrEventDateTimeIsBusy
 	}
 
-	s.events[eventID] = event
+	s.events[eventID] = updated
+	s.addTags(updated)
+	s.notify(eventID)
 
 	return nil
 }
@@ -68,6 +110,8 @@ func (s *Storage) DeleteEvent(_ context.Context, eventID uuid.UUID) error {
 	}
 
 	delete(s.events, eventID)
+	delete(s.claims, eventID)
+	s.notify(eventID)
 	return nil
 }
 
@@ -80,94 +124,198 @@ func (s *Storage) GetEvent(_ context.Context, eventID uuid.UUID) (*storage.Event
 		return nil, storage.ErrEventNotFound
 	}
 
-	return event, nil
+	return event.Copy(), nil
 }
 
-func (s *Storage) GetEventByDate(_ context.Context, eventDatetime time.Time) (*storage.Event, error) {
-	// because can be already locked by parent function.
-	if s.mu.TryLock() {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-	}
+func (s *Storage) GetEventByDate(
+	_ context.Context,
+	eventDatetime time.Time,
+	filter storage.EventFilter,
+) (*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	for _, event := range s.events {
-		if event.DateTime == eventDatetime {
-			return event, nil
-		}
+	match := s.matcher(filter)
+	events := s.filter(func(event *storage.Event) bool {
+		return event.DateTime.Equal(eventDatetime) && match(event)
+	})
+	if len(events) == 0 {
+		return nil, storage.ErrEventNotFound
 	}
 
-	return nil, storage.ErrEventNotFound
+	return events[0], nil
 }
 
-func (s *Storage) GetEvents(_ context.Context) ([]*storage.Event, error) {
-	return maps.Values(s.events), nil
+func (s *Storage) GetEvents(_ context.Context, filter storage.EventFilter) ([]*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.filter(s.matcher(filter)), nil
 }
 
 // general mehtod for getting events by date range.
-func (s *Storage) getEventsForRange(startRange time.Time, endRange time.Time) ([]*storage.Event, error) {
-	var events []*storage.Event
-	for _, event := range s.events {
-		if event.DateTime.After(startRange.Add(-time.Second)) && event.DateTime.Before(endRange) {
-			events = append(events, event)
-		}
-	}
+func (s *Storage) getEventsForRange(
+	startRange time.Time,
+	endRange time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	return events, nil
+	match := s.matcher(filter)
+	return s.filter(func(event *storage.Event) bool {
+		return event.Overlaps(startRange, endRange) && match(event)
+	}), nil
+}
+
+func (s *Storage) GetEventsForDay(
+	_ context.Context,
+	startOfDay time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(startOfDay, startOfDay.AddDate(0, 0, 1), filter)
+}
+
+func (s *Storage) GetEventsForWeek(
+	_ context.Context,
+	startOfWeek time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(startOfWeek, startOfWeek.AddDate(0, 0, 7), filter)
+}
+
+func (s *Storage) GetEventsForMonth(
+	_ context.Context,
+	startOfMonth time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(startOfMonth, startOfMonth.AddDate(0, 1, 0), filter)
 }
 
-func (s *Storage) GetEventsForDay(_ context.Context, startOfDay time.Time) ([]*storage.Event, error) {
+func (s *Storage) GetEventsForNotifications(_ context.Context) ([]*storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.getEventsForRange(startOfDay, startOfDay.Add(24*time.Hour))
+	return s.filter(func(event *storage.Event) bool {
+		return !event.TimeNotification.IsZero() && time.Until(event.TimeNotification) <= 0 && event.NotifyAt.IsZero()
+	}), nil
 }
 
-func (s *Storage) GetEventsForWeek(_ context.Context, startOfWeek time.Time) ([]*storage.Event, error) {
+func (s *Storage) GetUpcomingNotifications(_ context.Context, till time.Time) ([]*storage.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	endRange := startOfWeek.Add(24 * time.Hour)
+	events := s.filter(func(event *storage.Event) bool {
+		return !event.TimeNotification.IsZero() && !event.TimeNotification.After(till) && event.NotifyAt.IsZero()
+	})
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TimeNotification.Before(events[j].TimeNotification)
+	})
 
-	return s.getEventsForRange(startOfWeek, endRange.AddDate(0, 0, 7))
+	return events, nil
 }
 
-func (s *Storage) GetEventsForMonth(_ context.Context, startOfMonth time.Time) ([]*storage.Event, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// WatchEvents subscribes to changes made through this storage. Subscriber that does not keep up
+// misses changes, so it should reload events from time to time.
+func (s *Storage) WatchEvents(ctx context.Context) (<-chan uuid.UUID, error) {
+	ch := make(chan uuid.UUID, watchBufferSize)
 
-	endRange := startOfMonth.Add(24 * time.Hour)
+	s.mu.Lock()
+	s.watchers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
 
-	return s.getEventsForRange(startOfMonth, endRange.AddDate(0, 1, 0))
+		s.mu.Lock()
+		delete(s.watchers, ch)
+		close(ch)
+		s.mu.Unlock()
+	}()
+
+	return ch, nil
 }
 
-func (s *Storage) GetEventsForNotifications(_ context.Context) ([]*storage.Event, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *Storage) ClaimEventsForNotifications(
+	_ context.Context,
+	owner string,
+	lease time.Duration,
+	limit int,
+) ([]*storage.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var events []*storage.Event
-	for _, event := range s.events {
-		if time.Until(event.TimeNotification) <= 0 && event.NotifyAt.IsZero() {
-			events = append(events, event)
-		}
+	now := time.Now()
+
+	events := s.filter(func(event *storage.Event) bool {
+		c, claimed := s.claims[event.ID]
+		return !event.TimeNotification.IsZero() && !event.TimeNotification.After(now) && event.NotifyAt.IsZero() &&
+			(!claimed || c.until.Before(now))
+	})
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	for _, event := range events {
+		s.claims[event.ID] = claim{owner: owner, until: now.Add(lease)}
 	}
 
 	return events, nil
 }
 
-func (s *Storage) DeleteOldEvents(ctx context.Context, duration time.Duration) (int, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *Storage) DeleteOldEvents(_ context.Context, duration time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	border := time.Now().Add(-duration)
 
 	counter := 0
-	for _, event := range s.events {
-		if time.Now().After(event.DateTime.Add(duration)) {
-			err := s.DeleteEvent(ctx, event.ID)
-			if err != nil {
-				return counter, err
-			}
+	for id, event := range s.events {
+		if event.Ended(border) {
+			delete(s.events, id)
+			delete(s.claims, id)
 			counter++
 		}
 	}
 
 	return counter, nil
 }
+
+// sends changed event ID to subscribers without blocking. Should be called under lock.
+func (s *Storage) notify(eventID uuid.UUID) {
+	for ch := range s.watchers {
+		select {
+		case ch <- eventID:
+		default:
+		}
+	}
+}
+
+// check if another event of the same user already takes this time. Should be called under lock.
+func (s *Storage) isBusy(event *storage.Event) bool {
+	for _, other := range s.events {
+		if other.ID != event.ID && other.UserID == event.UserID && other.DateTime.Equal(event.DateTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// returns copies of matched events ordered by date. Should be called under lock.
+func (s *Storage) filter(match func(event *storage.Event) bool) []*storage.Event {
+	var events []*storage.Event
+	for _, event := range s.events {
+		if match(event) {
+			events = append(events, event.Copy())
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].DateTime.Before(events[j].DateTime)
+	})
+
+	return events
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -93,17 +94,24 @@ func TestUpdateWithBusyTimeEvent(t *testing.T) {
 
 	st := New()
 
+	err := st.CreateEvent(context.Background(), &storage.Event{ID: uuid.New(), Title: "Busy", DateTime: time})
+	assert.NoError(t, err)
+
 	event := &storage.Event{
 		ID:       id,
 		Title:    "Event title",
-		DateTime: time,
+		DateTime: time.Add(1),
 	}
 
-	err := st.CreateEvent(context.Background(), event)
+	err = st.CreateEvent(context.Background(), event)
 	assert.NoError(t, err)
 
 	err = st.UpdateEvent(context.Background(), id, &storage.Event{Title: "1", DateTime: time})
 	assert.Equal(t, storage.ErrEventDateTimeIsBusy, err)
+
+	// the same time of the event itself is not busy
+	err = st.UpdateEvent(context.Background(), id, &storage.Event{Title: "2", DateTime: time.Add(1)})
+	assert.NoError(t, err)
 }
 
 func TestDeleteEvent(t *testing.T) {
@@ -127,6 +135,12 @@ func TestDeleteEvent(t *testing.T) {
 	assert.Equal(t, storage.ErrEventNotFound, err)
 }
 
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(_ *testing.T) storage.Storage {
+		return New()
+	})
+}
+
 func TestConcurrent(t *testing.T) {
 	st := New()
 	UUID := uuid.New()
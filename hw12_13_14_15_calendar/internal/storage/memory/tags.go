@@ -0,0 +1,83 @@
+package memorystorage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+func (s *Storage) GetTags(_ context.Context, userID int64) ([]*storage.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]*storage.Tag, 0, len(s.tags[userID]))
+	for _, tag := range s.tags[userID] {
+		copied := *tag
+		res = append(res, &copied)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+func (s *Storage) SaveTag(_ context.Context, tag *storage.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *tag
+	s.userTags(tag.UserID)[tag.Name] = &stored
+	return nil
+}
+
+func (s *Storage) DeleteTag(_ context.Context, userID int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.tags[userID][name]; !found {
+		return storage.ErrTagNotFound
+	}
+	delete(s.tags[userID], name)
+
+	filter := storage.EventFilter{Tag: name}
+	for id, event := range s.events {
+		if event.UserID != userID || !filter.Match(event) {
+			continue
+		}
+
+		var tags []string
+		for _, tag := range event.Tags {
+			if tag != name {
+				tags = append(tags, tag)
+			}
+		}
+		event.Tags = tags
+		s.notify(id)
+	}
+
+	return nil
+}
+
+// creates missing tags of the event. Should be called under lock.
+func (s *Storage) addTags(event *storage.Event) {
+	tags := s.userTags(event.UserID)
+	for _, name := range event.Tags {
+		if _, found := tags[name]; !found {
+			tags[name] = &storage.Tag{UserID: event.UserID, Name: name}
+		}
+	}
+}
+
+// Should be called under lock.
+func (s *Storage) userTags(userID int64) map[string]*storage.Tag {
+	tags, found := s.tags[userID]
+	if !found {
+		tags = make(map[string]*storage.Tag)
+		s.tags[userID] = tags
+	}
+
+	return tags
+}
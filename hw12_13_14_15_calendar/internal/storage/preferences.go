@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrPreferencesNotFound = errors.New("preferences not found")
+
+type DigestMode string
+
+const (
+	DigestOff    DigestMode = ""
+	DigestHourly DigestMode = "hourly"
+	DigestDaily  DigestMode = "daily"
+)
+
+// ClockLayout is the format of quiet hours bounds.
+const ClockLayout = "15:04"
+
+// Preferences describe how notifications are delivered to the user.
+// Quiet hours are "HH:MM" (ClockLayout) in the time zone of the user, empty values mean no quiet hours.
+type Preferences struct {
+	UserID     int64      `json:"user_id"` //nolint:tagliatelle
+	Channel    string     `json:"channel"`
+	Address    string     `json:"address"`
+	Locale     string     `json:"locale"`
+	TimeZone   string     `json:"time_zone"`   //nolint:tagliatelle
+	QuietStart string     `json:"quiet_start"` //nolint:tagliatelle
+	QuietEnd   string     `json:"quiet_end"`   //nolint:tagliatelle
+	Digest     DigestMode `json:"digest"`
+}
+
+// PendingNotification is deferred by sender till DeliverAt because of quiet hours or digest mode.
+type PendingNotification struct {
+	ID           uuid.UUID    `json:"id"`
+	Notification Notification `json:"notification"`
+	DeliverAt    time.Time    `json:"deliver_at"` //nolint:tagliatelle
+}
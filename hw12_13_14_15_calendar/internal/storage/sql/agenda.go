@@ -0,0 +1,41 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ClaimAgenda inserts the agenda, so concurrent schedulers can not claim it both.
+func (s *Storage) ClaimAgenda(ctx context.Context, userID int64, day time.Time) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		INSERT INTO sent_agenda (user_id, day) VALUES ($1, $2::date)
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`
+
+	var claimedBy int64
+	err := s.DB.QueryRowContext(ctx, query, userID, day.Format(storage.DayLayout)).Scan(&claimedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *Storage) ReleaseAgenda(ctx context.Context, userID int64, day time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `DELETE FROM sent_agenda WHERE user_id = $1 AND day = $2::date`
+
+	_, err := s.DB.ExecContext(ctx, query, userID, day.Format(storage.DayLayout))
+
+	return err
+}
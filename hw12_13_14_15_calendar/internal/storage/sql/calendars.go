@@ -0,0 +1,240 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const calendarColumns = `id, owner_id, name, time_zone, color, is_default`
+
+func (s *Storage) CreateCalendar(ctx context.Context, calendar *storage.Calendar) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `INSERT INTO calendar (id, owner_id, name, time_zone, color) VALUES ($1, $2, $3, $4, $5)`
+
+	calendarID := calendar.ID
+	if calendarID == uuid.Nil {
+		calendarID = uuid.New()
+	}
+
+	_, err := s.DB.ExecContext(ctx, query, calendarID, calendar.OwnerID, calendar.Name, calendar.TimeZone, calendar.Color)
+	if err != nil {
+		return err
+	}
+
+	calendar.ID = calendarID
+
+	return nil
+}
+
+func (s *Storage) UpdateCalendar(ctx context.Context, calendar *storage.Calendar) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// owner and default flag are kept
+	const query = `UPDATE calendar SET name = $1, time_zone = $2, color = $3 WHERE id = $4`
+
+	res, err := s.DB.ExecContext(ctx, query, calendar.Name, calendar.TimeZone, calendar.Color, calendar.ID)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err == nil && count == 0 {
+		return storage.ErrCalendarNotFound
+	}
+
+	return nil
+}
+
+// DeleteCalendar relies on ON DELETE CASCADE to delete events and shares of the calendar.
+func (s *Storage) DeleteCalendar(ctx context.Context, calendarID uuid.UUID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `DELETE FROM calendar WHERE id = $1`
+
+	res, err := s.DB.ExecContext(ctx, query, calendarID)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err == nil && count == 0 {
+		return storage.ErrCalendarNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) GetCalendar(ctx context.Context, calendarID uuid.UUID) (*storage.Calendar, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT ` + calendarColumns + ` FROM calendar WHERE id = $1`
+
+	return s.getCalendar(ctx, query, calendarID)
+}
+
+func (s *Storage) GetCalendars(ctx context.Context, userID int64) ([]*storage.Calendar, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		SELECT ` + calendarColumns + `
+		FROM calendar
+		WHERE owner_id = $1 OR id IN (SELECT calendar_id FROM calendar_share WHERE user_id = $1)
+		ORDER BY name
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendars := []*storage.Calendar{}
+	for rows.Next() {
+		calendar, err := scanCalendar(rows)
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, calendar)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return calendars, nil
+}
+
+// DefaultCalendar creates the calendar at most once thanks to the unique index on default calendars.
+func (s *Storage) DefaultCalendar(ctx context.Context, ownerID int64) (*storage.Calendar, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const (
+		create = `
+			INSERT INTO calendar (owner_id, name, is_default)
+			VALUES ($1, $2, TRUE)
+			ON CONFLICT (owner_id) WHERE is_default DO NOTHING
+		`
+		query = `SELECT ` + calendarColumns + ` FROM calendar WHERE owner_id = $1 AND is_default`
+	)
+
+	if _, err := s.DB.ExecContext(ctx, create, ownerID, storage.DefaultCalendarName); err != nil {
+		return nil, err
+	}
+
+	return s.getCalendar(ctx, query, ownerID)
+}
+
+func (s *Storage) ShareCalendar(ctx context.Context, share *storage.Share) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		INSERT INTO calendar_share (calendar_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (calendar_id, user_id) DO UPDATE
+		SET role = EXCLUDED.role
+	`
+
+	_, err := s.DB.ExecContext(ctx, query, share.CalendarID, share.UserID, share.Role)
+
+	return calendarError(err)
+}
+
+func (s *Storage) UnshareCalendar(ctx context.Context, calendarID uuid.UUID, userID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `DELETE FROM calendar_share WHERE calendar_id = $1 AND user_id = $2`
+
+	res, err := s.DB.ExecContext(ctx, query, calendarID, userID)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err == nil && count == 0 {
+		return storage.ErrShareNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) GetShares(ctx context.Context, calendarID uuid.UUID) ([]*storage.Share, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT calendar_id, user_id, role FROM calendar_share WHERE calendar_id = $1 ORDER BY user_id`
+
+	return s.getShares(ctx, query, calendarID)
+}
+
+func (s *Storage) GetUserShares(ctx context.Context, userID int64) ([]*storage.Share, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT calendar_id, user_id, role FROM calendar_share WHERE user_id = $1 ORDER BY calendar_id`
+
+	return s.getShares(ctx, query, userID)
+}
+
+func (s *Storage) getCalendar(ctx context.Context, query string, args ...any) (*storage.Calendar, error) {
+	calendar, err := scanCalendar(s.DB.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrCalendarNotFound
+		}
+
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+func (s *Storage) getShares(ctx context.Context, query string, args ...any) ([]*storage.Share, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*storage.Share{}
+	for rows.Next() {
+		var share storage.Share
+		if err := rows.Scan(&share.CalendarID, &share.UserID, &share.Role); err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func scanCalendar(row scanner) (*storage.Calendar, error) {
+	var calendar storage.Calendar
+	err := row.Scan(
+		&calendar.ID,
+		&calendar.OwnerID,
+		&calendar.Name,
+		&calendar.TimeZone,
+		&calendar.Color,
+		&calendar.Default,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &calendar, nil
+}
@@ -0,0 +1,102 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"sync"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+// migrationLock is the key of advisory lock that is held while migrations run,
+// so instances started together and manual migrations do not apply the same migration twice.
+const migrationLock int64 = 20231204223134
+
+// gooseMu guards global settings of goose, dialect and base FS.
+var gooseMu sync.Mutex
+
+var ErrMigrateUsage = errors.New(
+	"usage: migrate up | down | redo | status | to-version VERSION | create NAME",
+)
+
+// Migrate runs goose command on the primary under advisory lock on its own connection.
+// Migrations are embedded into the binary unless MigrationsPath is set.
+func (s *Storage) Migrate(ctx context.Context, command string, args ...string) error {
+	var version int64
+	switch {
+	case command == "to-version" && len(args) == 1:
+		var err error
+		version, err = strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[0], err)
+		}
+	case (command == "up" || command == "down" || command == "redo" || command == "status") && len(args) == 0:
+	default:
+		return ErrMigrateUsage
+	}
+
+	gooseMu.Lock()
+	defer gooseMu.Unlock()
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return err
+	}
+	fsys, dir := s.migrations()
+	goose.SetBaseFS(fsys)
+
+	// advisory lock belongs to the session, so goose runs on the same connection that holds it:
+	// the pool of one connection is used, so migrations do not depend on the size of the main pool.
+	db, err := sql.Open("postgres", s.conf.DSN)
+	if err != nil {
+		return err
+	}
+	// closing of the session releases the lock.
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	if _, err := db.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return fmt.Errorf("cannot lock migrations: %w", err)
+	}
+
+	switch command {
+	case "to-version":
+		return migrateTo(ctx, db, dir, version)
+	default:
+		return goose.RunContext(ctx, command, db, dir)
+	}
+}
+
+// migrations returns FS and directory of migrations for goose, nil FS is the disk.
+func (s *Storage) migrations() (fs.FS, string) {
+	if s.conf.MigrationsPath == "" {
+		return migrations.FS, "."
+	}
+
+	return nil, s.conf.MigrationsPath
+}
+
+// migrateTo applies or rolls back migrations depending on the current version.
+func migrateTo(ctx context.Context, db *sql.DB, dir string, version int64) error {
+	current, err := goose.EnsureDBVersionContext(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if version >= current {
+		return goose.UpToContext(ctx, db, dir, version)
+	}
+
+	return goose.DownToContext(ctx, db, dir, version)
+}
+
+// CreateMigration writes a blank SQL migration into the directory, database is not needed for it.
+// New migration is embedded on the next build when it is created in the migrations directory of the repo.
+func CreateMigration(dir, name string) error {
+	return goose.Create(nil, dir, name, "sql")
+}
@@ -0,0 +1,166 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+func (s *Storage) GetPreferences(ctx context.Context, userID int64) (*storage.Preferences, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		SELECT user_id, channel, address, locale, time_zone, quiet_start, quiet_end, digest
+		FROM user_preferences
+		WHERE user_id = $1
+	`
+
+	var preferences storage.Preferences
+	err := s.DB.QueryRowContext(ctx, query, userID).Scan(
+		&preferences.UserID,
+		&preferences.Channel,
+		&preferences.Address,
+		&preferences.Locale,
+		&preferences.TimeZone,
+		&preferences.QuietStart,
+		&preferences.QuietEnd,
+		&preferences.Digest,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrPreferencesNotFound
+		}
+
+		return nil, err
+	}
+
+	return &preferences, nil
+}
+
+func (s *Storage) SavePreferences(ctx context.Context, preferences *storage.Preferences) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		INSERT INTO user_preferences (user_id, channel, address, locale, time_zone, quiet_start, quiet_end, digest)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE
+		SET channel = EXCLUDED.channel, address = EXCLUDED.address, locale = EXCLUDED.locale,
+			time_zone = EXCLUDED.time_zone, quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end,
+			digest = EXCLUDED.digest, updated_at = NOW()
+	`
+
+	_, err := s.DB.ExecContext(
+		ctx,
+		query,
+		preferences.UserID,
+		preferences.Channel,
+		preferences.Address,
+		preferences.Locale,
+		preferences.TimeZone,
+		preferences.QuietStart,
+		preferences.QuietEnd,
+		preferences.Digest,
+	)
+
+	return err
+}
+
+func (s *Storage) DeletePreferences(ctx context.Context, userID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `DELETE FROM user_preferences WHERE user_id = $1`
+
+	res, err := s.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err == nil && count == 0 {
+		return storage.ErrPreferencesNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) AddPendingNotification(ctx context.Context, pending *storage.PendingNotification) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `INSERT INTO pending_notification (id, user_id, payload, deliver_at) VALUES ($1, $2, $3, $4)`
+
+	if pending.ID == uuid.Nil {
+		pending.ID = uuid.New()
+	}
+
+	payload, err := json.Marshal(pending.Notification)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, query, pending.ID, pending.Notification.UserID, payload, pending.DeliverAt)
+
+	return err
+}
+
+// TakePendingNotifications deletes due rows with SKIP LOCKED, so concurrent senders never get the same ones.
+func (s *Storage) TakePendingNotifications(
+	ctx context.Context,
+	till time.Time,
+) ([]*storage.PendingNotification, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		DELETE FROM pending_notification
+		WHERE id IN (
+			SELECT id
+			FROM pending_notification
+			WHERE deliver_at <= $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, payload, deliver_at
+	`
+
+	rows, err := s.DB.QueryContext(ctx, query, till)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*storage.PendingNotification
+	for rows.Next() {
+		var (
+			pending storage.PendingNotification
+			payload []byte
+		)
+
+		if err := rows.Scan(&pending.ID, &payload, &pending.DeliverAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &pending.Notification); err != nil {
+			return nil, err
+		}
+
+		res = append(res, &pending)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep order of the subquery.
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].DeliverAt.Before(res[j].DeliverAt)
+	})
+
+	return res, nil
+}
@@ -0,0 +1,52 @@
+package sqlstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+)
+
+// replicaRetryInterval is the time reads go to the primary after the replica fails.
+const replicaRetryInterval = 5 * time.Second
+
+// withTimeout limits the query with QueryTimeout, deadline of the caller takes precedence.
+func (s *Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || s.conf.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.conf.QueryTimeout)
+}
+
+// readEvents runs read-only query on the replica when it is available and falls back to the primary
+// on its failure. Each attempt has its own timeout, so slow replica does not eat time of the primary.
+func (s *Storage) readEvents(ctx context.Context, query string, args ...any) ([]*storage.Event, error) {
+	if s.replicaAvailable() {
+		replicaCtx, cancel := s.withTimeout(ctx)
+		events, err := queryEvents(replicaCtx, s.replica, query, args...)
+		cancel()
+		if err == nil {
+			return events, nil
+		}
+
+		// the caller is gone, there is no one to fall back for.
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.replicaFailed()
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.getEvents(ctx, query, args...)
+}
+
+func (s *Storage) replicaAvailable() bool {
+	return s.replica != nil && time.Now().UnixNano() >= s.replicaDownUntil.Load()
+}
+
+func (s *Storage) replicaFailed() {
+	s.replicaDownUntil.Store(time.Now().Add(replicaRetryInterval).UnixNano())
+}
@@ -5,105 +5,292 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq" // PG
-	"github.com/pressly/goose"
+	"github.com/lib/pq" // PG
 )
 
-type Storage struct {
-	DB               *sql.DB
-	connectionString string
-	migrationsPath   string
+// Postgres error codes for unique and foreign key constraint violations.
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+// tags of the event are aggregated into sorted array.
+const eventColumns = `id, calendar_id, title, date_time, end_time, all_day, description, user_id, notification_time,
+	notify_at, color, location, COALESCE((
+		SELECT array_agg(tag.name ORDER BY tag.name)
+		FROM event_tag JOIN tag ON tag.id = event_tag.tag_id
+		WHERE event_tag.event_id = event.id
+	), '{}')`
+
+// Config of the storage. Zero values of the pool keep defaults of database/sql.
+type Config struct {
+	DSN string
+	// ReplicaDSN is optional read replica for GetEvents and range queries.
+	ReplicaDSN string
+	// MigrationsPath is directory with migrations, empty uses migrations embedded into the binary.
+	MigrationsPath string
+	// AutoMigrate applies new migrations on Connect.
+	AutoMigrate bool
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// QueryTimeout is used for queries without deadline in ctx, 0 disables it.
+	QueryTimeout time.Duration
 }
 
-func New(connectionString string, migrationsPath string) *Storage {
-	return &Storage{
-		connectionString: connectionString,
-		migrationsPath:   migrationsPath,
+// NewConfig builds settings of the storage from sections of the config file.
+func NewConfig(db config.DBConf, storageConf config.StorageConf) Config {
+	return Config{
+		DSN:             db.DSN(),
+		ReplicaDSN:      db.ReplicaDSN(),
+		MigrationsPath:  storageConf.MigrationsPath,
+		AutoMigrate:     storageConf.AutoMigrate,
+		MaxOpenConns:    db.MaxOpenConns,
+		MaxIdleConns:    db.MaxIdleConns,
+		ConnMaxLifetime: db.ConnMaxLifetime,
+		ConnMaxIdleTime: db.ConnMaxIdleTime,
+		QueryTimeout:    db.QueryTimeout,
 	}
 }
 
+type Storage struct {
+	// DB is the primary, all writes and reads that should see them go there.
+	DB      *sql.DB
+	replica *sql.DB
+	conf    Config
+	// replicaDownUntil is unix nano time until that reads go to the primary after replica failure.
+	replicaDownUntil atomic.Int64
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func New(conf Config) *Storage {
+	return &Storage{conf: conf}
+}
+
 func (s *Storage) Connect(ctx context.Context) error {
-	db, err := sql.Open("postgres", s.connectionString)
+	db, err := s.open(ctx, s.conf.DSN)
 	if err != nil {
 		return err
 	}
 
-	err = db.PingContext(ctx)
-	if err != nil {
-		return err
+	s.DB = db
+
+	if s.conf.ReplicaDSN != "" {
+		replica, err := sql.Open("postgres", s.conf.ReplicaDSN)
+		if err != nil {
+			return err
+		}
+		s.setPool(replica)
+		s.replica = replica
+
+		// replica that is down on start is retried later, reads go to the primary meanwhile.
+		if err := replica.PingContext(ctx); err != nil {
+			s.replicaFailed()
+		}
 	}
 
-	s.DB = db
+	if !s.conf.AutoMigrate {
+		return nil
+	}
 
-	return s.migrate()
+	return s.Migrate(ctx, "up")
 }
 
-func (s *Storage) migrate() error {
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
+func (s *Storage) open(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
 	}
+	s.setPool(db)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
 
-	return goose.Up(s.DB, s.migrationsPath)
+func (s *Storage) setPool(db *sql.DB) {
+	if s.conf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(s.conf.MaxOpenConns)
+	}
+	if s.conf.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(s.conf.MaxIdleConns)
+	}
+	if s.conf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(s.conf.ConnMaxLifetime)
+	}
+	if s.conf.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(s.conf.ConnMaxIdleTime)
+	}
 }
 
 func (s *Storage) Close() error {
+	if s.replica != nil {
+		s.replica.Close()
+	}
+
 	return s.DB.Close()
 }
 
 func (s *Storage) CreateEvent(ctx context.Context, event *storage.Event) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// insert only if the time is not busy by another event of the same user.
 	const query = `
-		INSERT INTO event (title, date_time, duration, description, user_id, notification_time)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO event (
+			id, title, date_time, end_time, description, user_id, notification_time, notify_at, color, location, calendar_id,
+			all_day
+		)
+		SELECT $1::uuid, $2::varchar, $3::timestamptz, $4::timestamptz,
+			$5::text, $6::integer, $7::timestamptz, $8::timestamptz, $9::varchar, $10::text, $11::uuid, $12::boolean
+		WHERE NOT EXISTS (
+			SELECT 1 FROM event WHERE user_id = $6 AND date_time = $3
+		)
 	`
 
-	_, err := s.DB.ExecContext(
+	eventID := event.ID
+	if eventID == uuid.Nil {
+		eventID = uuid.New()
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
 		ctx,
 		query,
+		eventID,
 		event.Title,
 		event.DateTime,
-		event.Duration,
+		event.EndTime,
 		event.Description,
 		event.UserID,
-		event.TimeNotification,
+		nullTime(event.TimeNotification),
+		nullTime(event.NotifyAt),
+		event.Color,
+		event.Location,
+		event.CalendarID,
+		event.AllDay,
 	)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+			return storage.ErrEventAlreadyExists
+		}
+
+		return calendarError(err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return storage.ErrEventDateTimeIsBusy
+	}
+
+	if err := setEventTags(ctx, tx, eventID, event.UserID, event.Tags); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
 		return err
 	}
 
+	event.ID = eventID
+
 	return nil
 }
 
 func (s *Storage) UpdateEvent(ctx context.Context, eventID uuid.UUID, event *storage.Event) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	const query = `
 		UPDATE event
-		SET title = $1, date_time = $2, duration = $3, description = $4, user_id = $5, notification_time = $6, notify_at = $7
-		WHERE id = $8
+		SET title = $1, date_time = $2, end_time = $3, description = $4, user_id = $5, notification_time = $6, notify_at = $7,
+			color = $9, location = $10, calendar_id = $11, all_day = $12
+		WHERE id = $8 AND NOT EXISTS (
+			SELECT 1 FROM event WHERE user_id = $5 AND date_time = $2 AND id <> $8
+		)
 	`
 
-	_, err := s.DB.ExecContext(
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
 		ctx,
 		query,
 		event.Title,
 		event.DateTime,
-		event.Duration,
+		event.EndTime,
 		event.Description,
 		event.UserID,
-		event.TimeNotification,
-		event.NotifyAt,
+		nullTime(event.TimeNotification),
+		nullTime(event.NotifyAt),
 		eventID,
+		event.Color,
+		event.Location,
+		event.CalendarID,
+		event.AllDay,
 	)
+	if err != nil {
+		return calendarError(err)
+	}
+
+	count, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if count > 0 {
+		if err := setEventTags(ctx, tx, eventID, event.UserID, event.Tags); err != nil {
+			return err
+		}
 
-	return nil
+		return tx.Commit()
+	}
+
+	// nothing updated: either there is no such event or time is busy.
+	// the check runs in the transaction, it would wait for another connection of the pool otherwise.
+	const existsQuery = `SELECT EXISTS (SELECT 1 FROM event WHERE id = $1)`
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, existsQuery, eventID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return storage.ErrEventNotFound
+	}
+
+	return storage.ErrEventDateTimeIsBusy
 }
 
 func (s *Storage) DeleteEvent(ctx context.Context, eventID uuid.UUID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	const query = `DELETE FROM event WHERE id = $1`
 
 	res, err := s.DB.ExecContext(ctx, query, eventID)
@@ -120,194 +307,287 @@ func (s *Storage) DeleteEvent(ctx context.Context, eventID uuid.UUID) error {
 }
 
 func (s *Storage) GetEvent(ctx context.Context, eventID uuid.UUID) (*storage.Event, error) {
-	const query = `
-		SELECT id, title, date_time, duration, description, user_id, notification_time
-		FROM event
-		WHERE id = $1
-	`
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	row := s.DB.QueryRowContext(ctx, query, eventID)
+	const query = `SELECT ` + eventColumns + ` FROM event WHERE id = $1`
 
-	var event storage.Event
-	err := row.Scan(
-		&event.ID,
-		&event.Title,
-		&event.DateTime,
-		&event.Duration,
-		&event.Description,
-		&event.UserID,
-		&event.TimeNotification,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, storage.ErrEventNotFound
-		}
+	return s.getEvent(ctx, query, eventID)
+}
 
-		return nil, err
-	}
+func (s *Storage) GetEvents(ctx context.Context, filter storage.EventFilter) ([]*storage.Event, error) {
+	condition, args := filterCondition(filter, nil)
+	query := `SELECT ` + eventColumns + ` FROM event WHERE ` + condition + ` ORDER BY date_time`
 
-	return &event, nil
+	return s.readEvents(ctx, query, args...)
 }
 
-func (s *Storage) GetEvents(ctx context.Context) ([]*storage.Event, error) {
-	const query = `
-		SELECT id, title, date_time, duration, description, user_id, notification_time
+func (s *Storage) GetEventByDate(
+	ctx context.Context,
+	eventDatetime time.Time,
+	filter storage.EventFilter,
+) (*storage.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	condition, args := filterCondition(filter, []any{eventDatetime})
+	query := `SELECT ` + eventColumns + ` FROM event WHERE date_time = $1 AND ` + condition + ` LIMIT 1`
+
+	return s.getEvent(ctx, query, args...)
+}
+
+// general mehtod for getting events by date range.
+func (s *Storage) getEventsForRange(
+	ctx context.Context,
+	startRange time.Time,
+	endRange time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	// all-day events are compared with the wall clock of the range, events without duration
+	// are matched by the start.
+	condition, args := filterCondition(filter, []any{
+		startRange, endRange, storage.WallClock(startRange), storage.WallClock(endRange),
+	})
+	query := `
+		SELECT ` + eventColumns + `
 		FROM event
+		WHERE (
+			(NOT all_day AND date_time < $2 AND (end_time > $1 OR date_time >= $1))
+			OR (all_day AND date_time < $4 AND end_time > $3)
+		) AND ` + condition + `
+		ORDER BY date_time
 	`
-	rows, err := s.DB.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
 
-	var events []*storage.Event
+	return s.readEvents(ctx, query, args...)
+}
 
-	for rows.Next() {
-		e := &storage.Event{}
-		err := rows.Scan(
-			&e.ID,
-			&e.Title,
-			&e.DateTime,
-			&e.Duration,
-			&e.Description,
-			&e.UserID,
-			&e.TimeNotification,
-		)
-		if err != nil {
-			return nil, err
-		}
+func (s *Storage) GetEventsForDay(
+	ctx context.Context,
+	startOfDay time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(ctx, startOfDay, startOfDay.AddDate(0, 0, 1), filter)
+}
 
-		events = append(events, e)
-	}
+func (s *Storage) GetEventsForWeek(
+	ctx context.Context,
+	startOfWeek time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(ctx, startOfWeek, startOfWeek.AddDate(0, 0, 7), filter)
+}
 
-	return events, nil
+func (s *Storage) GetEventsForMonth(
+	ctx context.Context,
+	startOfMonth time.Time,
+	filter storage.EventFilter,
+) ([]*storage.Event, error) {
+	return s.getEventsForRange(ctx, startOfMonth, startOfMonth.AddDate(0, 1, 0), filter)
 }
 
-func (s *Storage) GetEventByDate(ctx context.Context, eventDatetime time.Time) (*storage.Event, error) {
+func (s *Storage) GetEventsForNotifications(ctx context.Context) ([]*storage.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	const query = `
-		SELECT id, title, date_time, duration, description, user_id, notification_time
+		SELECT ` + eventColumns + `
 		FROM event
-		WHERE date_time = $1
+		WHERE notification_time <= NOW()
+		AND notify_at IS NULL
+		ORDER BY date_time
 	`
 
-	row := s.DB.QueryRowContext(ctx, query, eventDatetime.String())
+	return s.getEvents(ctx, query)
+}
 
-	var event storage.Event
+func (s *Storage) GetUpcomingNotifications(ctx context.Context, till time.Time) ([]*storage.Event, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	err := row.Scan(
-		&event.ID,
-		&event.Title,
-		&event.DateTime,
-		&event.Duration,
-		&event.Description,
-		&event.UserID,
-		&event.TimeNotification,
-	)
-	if err != nil {
-		return nil, err
-	}
+	const query = `
+		SELECT ` + eventColumns + `
+		FROM event
+		WHERE notification_time <= $1
+		AND notify_at IS NULL
+		ORDER BY notification_time
+	`
 
-	return &event, nil
+	return s.getEvents(ctx, query, till)
 }
 
-// general mehtod for getting events by date range.
-func (s *Storage) getEventsForRange(
+// ClaimEventsForNotifications locks due events with SKIP LOCKED, so concurrent schedulers
+// never get the same rows, and marks them with owner and lease expiration time.
+func (s *Storage) ClaimEventsForNotifications(
 	ctx context.Context,
-	startRange time.Time,
-	endRange time.Time,
+	owner string,
+	lease time.Duration,
+	limit int,
 ) ([]*storage.Event, error) {
-	var events []*storage.Event
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
 	const query = `
-		SELECT id, title, date_time, duration, description, user_id, notification_time
-		FROM event
-		WHERE date_time >= $1 AND date_time < $2
-	`
+		UPDATE event
+		SET notify_claimed_by = $1, notify_claimed_until = NOW() + $2::bigint * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id
+			FROM event
+			WHERE notification_time <= NOW()
+			AND notify_at IS NULL
+			AND (notify_claimed_until IS NULL OR notify_claimed_until < NOW())
+			ORDER BY notification_time
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + eventColumns
 
-	rows, err := s.DB.QueryContext(ctx, query, startRange, endRange)
+	events, err := s.getEvents(ctx, query, owner, lease.Milliseconds(), limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	// Iterate on the results of the query and create event objects
-	for rows.Next() {
-		event := &storage.Event{}
-		err := rows.Scan(
-			&event.ID,
-			&event.Title,
-			&event.DateTime,
-			&event.Duration,
-			&event.Description,
-			&event.UserID,
-			&event.TimeNotification,
-		)
-		if err != nil {
-			return nil, err
-		}
-		events = append(events, event)
-	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].DateTime.Before(events[j].DateTime)
+	})
 
 	return events, nil
 }
 
-func (s *Storage) GetEventsForDay(ctx context.Context, startOfDay time.Time) ([]*storage.Event, error) {
-	return s.getEventsForRange(ctx, startOfDay, startOfDay.Add(24*time.Hour))
-}
+func (s *Storage) DeleteOldEvents(ctx context.Context, duration time.Duration) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-func (s *Storage) GetEventsForWeek(ctx context.Context, startOfWeek time.Time) ([]*storage.Event, error) {
-	return s.getEventsForRange(ctx, startOfWeek, startOfWeek.AddDate(0, 0, 7))
-}
+	const query = `DELETE FROM event WHERE date_time < $1 AND end_time <= $1`
 
-func (s *Storage) GetEventsForMonth(ctx context.Context, startOfMonth time.Time) ([]*storage.Event, error) {
-	return s.getEventsForRange(ctx, startOfMonth, startOfMonth.AddDate(0, 1, 0))
+	res, err := s.DB.ExecContext(ctx, query, time.Now().Add(-duration))
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return int(affected), err
+	}
+
+	return int(affected), nil
 }
 
-func (s *Storage) GetEventsForNotifications(ctx context.Context) ([]*storage.Event, error) {
-	var events []*storage.Event
+func (s *Storage) getEvent(ctx context.Context, query string, args ...any) (*storage.Event, error) {
+	event, err := scanEvent(s.DB.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrEventNotFound
+		}
 
-	const query = `
-		SELECT id, title, date_time, user_id
-		FROM event
-		WHERE EXTRACT(EPOCH FROM (notification_time - NOW())) < 0
-		AND notify_at is null
-	`
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (s *Storage) getEvents(ctx context.Context, query string, args ...any) ([]*storage.Event, error) {
+	return queryEvents(ctx, s.DB, query, args...)
+}
 
-	rows, err := s.DB.QueryContext(ctx, query)
+func queryEvents(ctx context.Context, db *sql.DB, query string, args ...any) ([]*storage.Event, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var events []*storage.Event
+
 	// Iterate on the results of the query and create event objects
 	for rows.Next() {
-		event := &storage.Event{}
-		err := rows.Scan(
-			&event.ID,
-			&event.Title,
-			&event.DateTime,
-			&event.UserID,
-		)
+		event, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
 		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
-func (s *Storage) DeleteOldEvents(ctx context.Context, duration time.Duration) (int, error) {
-	query := fmt.Sprintf("DELETE FROM event WHERE date_time < NOW() - INTERVAL '%d hours'", int(duration.Hours()))
+func scanEvent(row scanner) (*storage.Event, error) {
+	var (
+		event            storage.Event
+		description      sql.NullString
+		timeNotification sql.NullTime
+		notifyAt         sql.NullTime
+		tags             pq.StringArray
+	)
 
-	res, err := s.DB.ExecContext(ctx, query)
+	err := row.Scan(
+		&event.ID,
+		&event.CalendarID,
+		&event.Title,
+		&event.DateTime,
+		&event.EndTime,
+		&event.AllDay,
+		&description,
+		&event.UserID,
+		&timeNotification,
+		&notifyAt,
+		&event.Color,
+		&event.Location,
+		&tags,
+	)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	affected, err := res.RowsAffected()
-	if err != nil {
-		return int(affected), err
+	event.Description = description.String
+	event.TimeNotification = timeNotification.Time
+	event.NotifyAt = notifyAt.Time
+	if len(tags) > 0 {
+		event.Tags = tags
 	}
 
-	return int(affected), nil
+	return &event, nil
+}
+
+// filterCondition returns SQL condition of the filter, its parameters are appended to args.
+func filterCondition(filter storage.EventFilter, args []any) (string, []any) {
+	var conditions []string
+
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1
+			FROM event_tag JOIN tag ON tag.id = event_tag.tag_id
+			WHERE event_tag.event_id = event.id AND tag.name = $%d
+		)`, len(args)))
+	}
+
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf(`calendar_id IN (
+			SELECT id FROM calendar WHERE owner_id = $%[1]d
+			UNION
+			SELECT calendar_id FROM calendar_share WHERE user_id = $%[1]d
+		)`, len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "TRUE", args
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// reference to missing calendar means that the calendar is deleted or never existed.
+func calendarError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
+		return storage.ErrCalendarNotFound
+	}
+
+	return err
+}
+
+// zero time is stored as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
 }
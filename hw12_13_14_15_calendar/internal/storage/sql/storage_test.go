@@ -0,0 +1,273 @@
+package sqlstorage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/config"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage/storagetest"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	embeddedPort = 54329
+	embeddedDSN  = "host=localhost port=54329 user=postgres password=postgres dbname=postgres sslmode=disable"
+)
+
+// Starts local Postgres for the test. Set CALENDAR_TEST_DSN to use already running server instead.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	if dsn, ok := os.LookupEnv("CALENDAR_TEST_DSN"); ok {
+		return dsn
+	}
+
+	if testing.Short() {
+		t.Skip("skip postgres tests in short mode")
+	}
+
+	dir := t.TempDir()
+	pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(embeddedPort).
+		RuntimePath(filepath.Join(dir, "runtime")).
+		DataPath(filepath.Join(dir, "data")).
+		Logger(nil))
+
+	if err := pg.Start(); err != nil {
+		t.Skipf("cannot start embedded postgres: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := pg.Stop(); err != nil {
+			t.Errorf("cannot stop embedded postgres: %s", err)
+		}
+	})
+
+	return embeddedDSN
+}
+
+func TestNewConfig(t *testing.T) {
+	db := config.DBConf{Host: "primary", Port: 5432, ReplicaHost: "replica", QueryTimeout: time.Second}
+	conf := NewConfig(db, config.StorageConf{MigrationsPath: "migrations", AutoMigrate: true})
+
+	require.Equal(t, db.DSN(), conf.DSN)
+	require.Contains(t, conf.ReplicaDSN, "host='replica' port='5432'")
+	require.Equal(t, "migrations", conf.MigrationsPath)
+	require.True(t, conf.AutoMigrate)
+	require.Equal(t, time.Second, conf.QueryTimeout)
+}
+
+func TestConformance(t *testing.T) {
+	dsn := startPostgres(t)
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		st := New(Config{
+			DSN:          dsn,
+			AutoMigrate:  true,
+			QueryTimeout: 5 * time.Second,
+		})
+		require.NoError(t, st.Connect(context.Background()))
+		t.Cleanup(func() {
+			st.Close()
+		})
+
+		_, err := st.DB.Exec(`TRUNCATE event, event_tag, tag, calendar, calendar_share, user_preferences, pending_notification, sent_agenda`)
+		require.NoError(t, err)
+
+		return st
+	})
+}
+
+func TestReplicaFallback(t *testing.T) {
+	dsn := startPostgres(t)
+
+	// nothing listens on the port of the replica, so reads go to the primary.
+	st := New(Config{
+		DSN:         dsn,
+		ReplicaDSN:  "host=127.0.0.1 port=1 user=calendar dbname=calendar sslmode=disable connect_timeout=1",
+		AutoMigrate: true,
+	})
+	require.NoError(t, st.Connect(context.Background()))
+	t.Cleanup(func() {
+		st.Close()
+	})
+	require.False(t, st.replicaAvailable())
+
+	_, err := st.DB.Exec(`TRUNCATE event, event_tag, tag, calendar, calendar_share, user_preferences, pending_notification, sent_agenda`)
+	require.NoError(t, err)
+
+	calendar, err := st.DefaultCalendar(context.Background(), 1)
+	require.NoError(t, err)
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, st.CreateEvent(context.Background(), &storage.Event{
+		ID:         uuid.New(),
+		CalendarID: calendar.ID,
+		Title:      "standup",
+		DateTime:   start,
+		EndTime:    start.Add(15 * time.Minute),
+		UserID:     1,
+	}))
+
+	// the replica is tried again after the retry interval and fails once more.
+	st.replicaDownUntil.Store(0)
+	events, err := st.GetEventsForDay(context.Background(), start.Add(-time.Minute), storage.EventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.False(t, st.replicaAvailable())
+}
+
+func TestUpdateEventWithSingleConnection(t *testing.T) {
+	dsn := startPostgres(t)
+
+	// the update that fails must not wait for another connection while its transaction holds the only one.
+	st := New(Config{DSN: dsn, MaxOpenConns: 1, AutoMigrate: true, QueryTimeout: 5 * time.Second})
+	require.NoError(t, st.Connect(context.Background()))
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	_, err := st.DB.Exec(`TRUNCATE event, event_tag, tag, calendar, calendar_share, user_preferences, pending_notification, sent_agenda`)
+	require.NoError(t, err)
+
+	calendar, err := st.DefaultCalendar(context.Background(), 1)
+	require.NoError(t, err)
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	events := make([]*storage.Event, 2)
+	for i := range events {
+		events[i] = &storage.Event{
+			CalendarID: calendar.ID,
+			Title:      "standup",
+			DateTime:   start.Add(time.Duration(i) * time.Hour),
+			EndTime:    start.Add(time.Duration(i)*time.Hour + 15*time.Minute),
+			UserID:     1,
+		}
+		require.NoError(t, st.CreateEvent(context.Background(), events[i]))
+	}
+
+	busy := *events[1]
+	busy.DateTime = events[0].DateTime
+	busy.EndTime = events[0].EndTime
+	require.ErrorIs(t, st.UpdateEvent(context.Background(), busy.ID, &busy), storage.ErrEventDateTimeIsBusy)
+	require.ErrorIs(t, st.UpdateEvent(context.Background(), uuid.New(), &busy), storage.ErrEventNotFound)
+}
+
+func TestMigrate(t *testing.T) {
+	dsn := startPostgres(t)
+
+	st := New(Config{DSN: dsn})
+	require.NoError(t, st.Connect(context.Background()))
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	ctx := context.Background()
+	require.NoError(t, st.Migrate(ctx, "up"))
+	require.NoError(t, st.Migrate(ctx, "redo"))
+	require.NoError(t, st.Migrate(ctx, "to-version", "20231216200639"))
+
+	var exists bool
+	require.NoError(t, st.DB.QueryRow(`SELECT to_regclass('sent_agenda') IS NOT NULL`).Scan(&exists))
+	require.False(t, exists)
+
+	require.NoError(t, st.Migrate(ctx, "up"))
+	require.NoError(t, st.DB.QueryRow(`SELECT to_regclass('sent_agenda') IS NOT NULL`).Scan(&exists))
+	require.True(t, exists)
+}
+
+func TestMigrateWithSingleConnection(t *testing.T) {
+	dsn := startPostgres(t)
+
+	// migrations run on their own connection, so the pool of one connection is enough
+	st := New(Config{DSN: dsn, MaxOpenConns: 1, AutoMigrate: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(t, st.Connect(ctx))
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	require.NoError(t, st.Migrate(ctx, "status"))
+}
+
+func TestMigrateLegacyDuration(t *testing.T) {
+	dsn := startPostgres(t)
+
+	st := New(Config{DSN: dsn})
+	require.NoError(t, st.Connect(context.Background()))
+	t.Cleanup(func() {
+		st.Close()
+	})
+
+	ctx := context.Background()
+	require.NoError(t, st.Migrate(ctx, "up"))
+	require.NoError(t, st.Migrate(ctx, "to-version", "20261019170000"))
+
+	_, err := st.DB.Exec(`TRUNCATE event, calendar CASCADE`)
+	require.NoError(t, err)
+
+	// some clients wrote Unix timestamps instead of durations in seconds
+	start := time.Date(2023, 12, 4, 10, 0, 0, 0, time.UTC)
+	durations := map[string]int64{"Meeting": 5400, "Timestamp": 1701684000, "Negative": -60}
+	for title, duration := range durations {
+		_, err := st.DB.Exec(`
+			WITH default_calendar AS (
+				INSERT INTO calendar (owner_id, name, is_default) VALUES (1, 'Default', TRUE)
+				ON CONFLICT (owner_id) WHERE is_default DO UPDATE SET name = calendar.name
+				RETURNING id
+			)
+			INSERT INTO event (calendar_id, title, date_time, duration, user_id)
+			SELECT id, $1, $2, $3, 1 FROM default_calendar`,
+			title, start, duration)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, st.Migrate(ctx, "up"))
+
+	ends := make(map[string]time.Time)
+	rows, err := st.DB.Query(`SELECT title, end_time FROM event`)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var title string
+		var end time.Time
+		require.NoError(t, rows.Scan(&title, &end))
+		ends[title] = end
+	}
+	require.NoError(t, rows.Err())
+
+	require.Len(t, ends, 3)
+	require.True(t, start.Add(90*time.Minute).Equal(ends["Meeting"]), ends["Meeting"])
+	require.True(t, start.Equal(ends["Timestamp"]), ends["Timestamp"])
+	require.True(t, start.Equal(ends["Negative"]), ends["Negative"])
+
+	_, err = st.DB.Exec(`TRUNCATE event, calendar CASCADE`)
+	require.NoError(t, err)
+}
+
+func TestMigrateWithoutDatabase(t *testing.T) {
+	st := New(Config{})
+
+	fsys, dir := st.migrations()
+	embedded, err := fs.Glob(fsys, dir+"/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, embedded)
+
+	require.ErrorIs(t, st.Migrate(context.Background(), "sideways"), ErrMigrateUsage)
+	require.ErrorIs(t, st.Migrate(context.Background(), "to-version"), ErrMigrateUsage)
+	require.ErrorContains(t, st.Migrate(context.Background(), "to-version", "latest"), "invalid version")
+
+	created := t.TempDir()
+	require.NoError(t, CreateMigration(created, "add_tags"))
+	files, err := filepath.Glob(filepath.Join(created, "*_add_tags.sql"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
@@ -0,0 +1,106 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (s *Storage) GetTags(ctx context.Context, userID int64) ([]*storage.Tag, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `SELECT user_id, name, color FROM tag WHERE user_id = $1 ORDER BY name`
+
+	rows, err := s.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*storage.Tag{}
+	for rows.Next() {
+		var tag storage.Tag
+		if err := rows.Scan(&tag.UserID, &tag.Name, &tag.Color); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *Storage) SaveTag(ctx context.Context, tag *storage.Tag) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `
+		INSERT INTO tag (user_id, name, color)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE
+		SET color = EXCLUDED.color
+	`
+
+	_, err := s.DB.ExecContext(ctx, query, tag.UserID, tag.Name, tag.Color)
+
+	return err
+}
+
+// DeleteTag relies on ON DELETE CASCADE to remove the tag from events.
+func (s *Storage) DeleteTag(ctx context.Context, userID int64, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	const query = `DELETE FROM tag WHERE user_id = $1 AND name = $2`
+
+	res, err := s.DB.ExecContext(ctx, query, userID, name)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err == nil && count == 0 {
+		return storage.ErrTagNotFound
+	}
+
+	return nil
+}
+
+// setEventTags replaces tags of the event, missing tags of the user are created.
+func setEventTags(ctx context.Context, tx *sql.Tx, eventID uuid.UUID, userID int64, tags []string) error {
+	const (
+		unlink = `DELETE FROM event_tag WHERE event_id = $1`
+		create = `
+			INSERT INTO tag (user_id, name)
+			SELECT $1::bigint, unnest($2::varchar[])
+			ON CONFLICT (user_id, name) DO NOTHING
+		`
+		link = `
+			INSERT INTO event_tag (event_id, tag_id)
+			SELECT $1::uuid, id FROM tag WHERE user_id = $2 AND name = ANY($3::varchar[])
+		`
+	)
+
+	if _, err := tx.ExecContext(ctx, unlink, eventID); err != nil {
+		return err
+	}
+
+	tags = storage.SortTags(tags)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, create, userID, pq.Array(tags)); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, link, eventID, userID, pq.Array(tags))
+
+	return err
+}
@@ -0,0 +1,70 @@
+package sqlstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel is filled by trigger from migrations on every change of event dates.
+const eventChangesChannel = "event_changes"
+
+const (
+	listenerMinReconnectInterval = 10 * time.Second
+	listenerMaxReconnectInterval = time.Minute
+	listenerPingInterval         = 90 * time.Second
+)
+
+// WatchEvents listens for changes of events through LISTEN/NOTIFY, so changes made by calendar
+// service are visible to scheduler running in another process.
+func (s *Storage) WatchEvents(ctx context.Context) (<-chan uuid.UUID, error) {
+	listener := pq.NewListener(
+		s.conf.DSN,
+		listenerMinReconnectInterval,
+		listenerMaxReconnectInterval,
+		nil,
+	)
+
+	if err := listener.Listen(eventChangesChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	ch := make(chan uuid.UUID)
+
+	go func() {
+		defer close(ch)
+		defer listener.Close()
+
+		for {
+			var eventID uuid.UUID
+
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				// nil is sent after reconnect: notifications could be lost in the meantime.
+				if n != nil {
+					id, err := uuid.Parse(n.Extra)
+					if err != nil {
+						continue
+					}
+					eventID = id
+				}
+			case <-time.After(listenerPingInterval):
+				go listener.Ping()
+				continue
+			}
+
+			select {
+			case ch <- eventID:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -7,18 +7,109 @@ import (
 	"github.com/google/uuid"
 )
 
+// EventStorage is implemented by every storage driver. Behaviour is checked by storagetest suite:
+//   - CreateEvent generates ID for event without it and rejects duplicated ID with ErrEventAlreadyExists;
+//   - CreateEvent and UpdateEvent reject time taken by another event of the same user with ErrEventDateTimeIsBusy;
+//   - methods for a single event return ErrEventNotFound for unknown ID or date;
+//   - ranges are half-open: [start, start+1 day), [start, start+7 days), [start, start+1 month),
+//     they contain events that overlap the range (see Event.Overlaps), not only ones starting in it;
+//   - lists are ordered by DateTime and returned events are copies, safe for modification;
+//   - lists contain only events matched by the filter;
+//   - events belong to calendars, UpdateEvent may move the event to another calendar;
+//   - tags of saved events are created for the user when they do not exist yet;
+//   - DeleteOldEvents deletes events that ended before now minus the duration;
+//   - GetUpcomingNotifications returns not yet notified events with TimeNotification up to till,
+//     ordered by TimeNotification.
 type EventStorage interface {
 	Connect(ctx context.Context) error
 	Close() error
 	CreateEvent(ctx context.Context, event *Event) error
 	UpdateEvent(ctx context.Context, eventID uuid.UUID, event *Event) error
 	DeleteEvent(ctx context.Context, eventID uuid.UUID) error
-	GetEvents(ctx context.Context) ([]*Event, error)
+	GetEvents(ctx context.Context, filter EventFilter) ([]*Event, error)
 	GetEvent(ctx context.Context, eventID uuid.UUID) (*Event, error)
-	GetEventByDate(ctx context.Context, eventDatetime time.Time) (*Event, error)
-	GetEventsForDay(ctx context.Context, startOfDay time.Time) ([]*Event, error)
-	GetEventsForWeek(ctx context.Context, startOfWeek time.Time) ([]*Event, error)
-	GetEventsForMonth(ctx context.Context, startOfMonth time.Time) ([]*Event, error)
+	GetEventByDate(ctx context.Context, eventDatetime time.Time, filter EventFilter) (*Event, error)
+	GetEventsForDay(ctx context.Context, startOfDay time.Time, filter EventFilter) ([]*Event, error)
+	GetEventsForWeek(ctx context.Context, startOfWeek time.Time, filter EventFilter) ([]*Event, error)
+	GetEventsForMonth(ctx context.Context, startOfMonth time.Time, filter EventFilter) ([]*Event, error)
 	GetEventsForNotifications(ctx context.Context) ([]*Event, error)
+	GetUpcomingNotifications(ctx context.Context, till time.Time) ([]*Event, error)
 	DeleteOldEvents(ctx context.Context, duration time.Duration) (int, error)
 }
+
+// PreferencesStorage keeps notification preferences of users. SavePreferences creates or replaces them.
+type PreferencesStorage interface {
+	GetPreferences(ctx context.Context, userID int64) (*Preferences, error)
+	SavePreferences(ctx context.Context, preferences *Preferences) error
+	DeletePreferences(ctx context.Context, userID int64) error
+}
+
+// TagStorage keeps tags of users. GetTags returns tags ordered by name, SaveTag creates the tag
+// or changes its color, DeleteTag removes the tag from events of the user too and returns ErrTagNotFound
+// for unknown tag.
+type TagStorage interface {
+	GetTags(ctx context.Context, userID int64) ([]*Tag, error)
+	SaveTag(ctx context.Context, tag *Tag) error
+	DeleteTag(ctx context.Context, userID int64, name string) error
+}
+
+// CalendarStorage keeps calendars and their shares:
+//   - GetCalendars returns calendars owned by or shared with the user ordered by name;
+//   - DefaultCalendar returns the default calendar of the owner, it is created on the first call;
+//   - DeleteCalendar deletes events and shares of the calendar too;
+//   - ShareCalendar creates the share or changes its role;
+//   - shares are ordered by user for the calendar and by calendar for the user;
+//   - unknown calendars and shares are reported with ErrCalendarNotFound and ErrShareNotFound.
+type CalendarStorage interface {
+	CreateCalendar(ctx context.Context, calendar *Calendar) error
+	UpdateCalendar(ctx context.Context, calendar *Calendar) error
+	DeleteCalendar(ctx context.Context, calendarID uuid.UUID) error
+	GetCalendar(ctx context.Context, calendarID uuid.UUID) (*Calendar, error)
+	GetCalendars(ctx context.Context, userID int64) ([]*Calendar, error)
+	DefaultCalendar(ctx context.Context, ownerID int64) (*Calendar, error)
+	ShareCalendar(ctx context.Context, share *Share) error
+	UnshareCalendar(ctx context.Context, calendarID uuid.UUID, userID int64) error
+	GetShares(ctx context.Context, calendarID uuid.UUID) ([]*Share, error)
+	GetUserShares(ctx context.Context, userID int64) ([]*Share, error)
+}
+
+// OutboxStorage keeps notifications deferred by sender. TakePendingNotifications removes and returns
+// notifications with DeliverAt up to till ordered by DeliverAt; every notification is returned only once,
+// even to concurrent callers.
+type OutboxStorage interface {
+	AddPendingNotification(ctx context.Context, pending *PendingNotification) error
+	TakePendingNotifications(ctx context.Context, till time.Time) ([]*PendingNotification, error)
+}
+
+// AgendaJournal remembers daily agendas that are already sent, so they are not duplicated after restart
+// or by several scheduler instances. ClaimAgenda records the agenda atomically and reports whether
+// the caller is the only one that claimed it, ReleaseAgenda forgets the claim of the agenda that is not sent.
+// Only the date of day in its location matters.
+type AgendaJournal interface {
+	ClaimAgenda(ctx context.Context, userID int64, day time.Time) (bool, error)
+	ReleaseAgenda(ctx context.Context, userID int64, day time.Time) error
+}
+
+// Storage is implemented by every storage driver.
+type Storage interface {
+	EventStorage
+	CalendarStorage
+	PreferencesStorage
+	TagStorage
+	OutboxStorage
+	AgendaJournal
+}
+
+// NotificationClaimer is implemented by storages that can be shared by several scheduler instances.
+// Claimed events are hidden from other owners until the lease expires, so if the owner dies
+// before marking them as notified, another instance picks them up later.
+type NotificationClaimer interface {
+	ClaimEventsForNotifications(ctx context.Context, owner string, lease time.Duration, limit int) ([]*Event, error)
+}
+
+// EventWatcher is implemented by storages that report changed events, including changes made by other processes.
+// uuid.Nil in the channel means that some changes may be lost and everything should be reloaded.
+// The channel is closed when ctx is done.
+type EventWatcher interface {
+	WatchEvents(ctx context.Context) (<-chan uuid.UUID, error)
+}
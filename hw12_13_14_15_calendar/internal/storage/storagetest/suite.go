@@ -0,0 +1,939 @@
+// Package storagetest contains conformance tests that every storage.Storage
+// implementation has to pass.
+package storagetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+// Factory returns ready to use (connected and empty) storage.
+// Releasing of resources should be registered with t.Cleanup.
+type Factory func(t *testing.T) storage.Storage
+
+type Suite struct {
+	suite.Suite
+	factory Factory
+	ctx     context.Context
+	st      storage.Storage
+	// calendar is the default calendar of user 1, events of tests belong to it.
+	calendar uuid.UUID
+}
+
+// Run executes conformance suite for the storage built by factory.
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+	suite.Run(t, &Suite{factory: factory})
+}
+
+func (s *Suite) SetupTest() {
+	s.ctx = context.Background()
+	s.st = s.factory(s.T())
+
+	calendar, err := s.st.DefaultCalendar(s.ctx, 1)
+	s.Require().NoError(err)
+	s.calendar = calendar.ID
+}
+
+// Postgres keeps only microseconds, so all test dates are rounded.
+func (s *Suite) date(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+func (s *Suite) newEvent(title string, dateTime time.Time) *storage.Event {
+	return &storage.Event{
+		ID:          uuid.New(),
+		CalendarID:  s.calendar,
+		Title:       title,
+		DateTime:    s.date(dateTime),
+		EndTime:     s.date(dateTime).Add(time.Hour),
+		Description: "Description of " + title,
+		UserID:      1,
+	}
+}
+
+func (s *Suite) create(events ...*storage.Event) {
+	for _, event := range events {
+		s.Require().NoError(s.st.CreateEvent(s.ctx, event))
+	}
+}
+
+func (s *Suite) titles(events []*storage.Event) []string {
+	res := make([]string, 0, len(events))
+	for _, event := range events {
+		res = append(res, event.Title)
+	}
+	return res
+}
+
+func (s *Suite) TestCreateAndGet() {
+	event := s.newEvent("Event", time.Now())
+	event.TimeNotification = s.date(time.Now().Add(-time.Hour))
+	s.create(event)
+
+	stored, err := s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal(event.ID, stored.ID)
+	s.Equal(event.CalendarID, stored.CalendarID)
+	s.Equal(event.Title, stored.Title)
+	s.Equal(event.Description, stored.Description)
+	s.True(event.EndTime.Equal(stored.EndTime))
+	s.False(stored.AllDay)
+	s.Equal(event.UserID, stored.UserID)
+	s.True(event.DateTime.Equal(stored.DateTime))
+	s.True(event.TimeNotification.Equal(stored.TimeNotification))
+	s.True(stored.NotifyAt.IsZero())
+	s.Empty(stored.Tags)
+}
+
+func (s *Suite) TestColorLocationAndTags() {
+	event := s.newEvent("Event", time.Now())
+	event.Color = "#ff8800"
+	event.Location = "Room 42"
+	event.Tags = []string{"work", "meeting"}
+	s.create(event)
+
+	stored, err := s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal("#ff8800", stored.Color)
+	s.Equal("Room 42", stored.Location)
+	s.Equal([]string{"meeting", "work"}, stored.Tags)
+
+	// update replaces tags
+	stored.Tags = []string{"work"}
+	stored.Location = ""
+	s.Require().NoError(s.st.UpdateEvent(s.ctx, event.ID, stored))
+
+	stored, err = s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"work"}, stored.Tags)
+	s.Empty(stored.Location)
+
+	// returned tags are copies too
+	stored.Tags[0] = "changed"
+	stored, err = s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"work"}, stored.Tags)
+}
+
+func (s *Suite) TestCreateGeneratesID() {
+	event := s.newEvent("Event", time.Now())
+	event.ID = uuid.Nil
+	s.create(event)
+	s.NotEqual(uuid.Nil, event.ID)
+
+	_, err := s.st.GetEvent(s.ctx, event.ID)
+	s.NoError(err)
+}
+
+func (s *Suite) TestCreateDuplicateID() {
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+
+	duplicate := s.newEvent("Duplicate", time.Now().Add(time.Hour))
+	duplicate.ID = event.ID
+	s.ErrorIs(s.st.CreateEvent(s.ctx, duplicate), storage.ErrEventAlreadyExists)
+}
+
+func (s *Suite) TestCreateBusyTime() {
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+
+	s.ErrorIs(s.st.CreateEvent(s.ctx, s.newEvent("Busy", event.DateTime)), storage.ErrEventDateTimeIsBusy)
+
+	// same time of another user is fine
+	other := s.newEvent("Other user", event.DateTime)
+	other.UserID = 2
+	s.NoError(s.st.CreateEvent(s.ctx, other))
+}
+
+func (s *Suite) TestUpdate() {
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+
+	update := s.newEvent("Event after update", time.Now().Add(time.Hour))
+	s.Require().NoError(s.st.UpdateEvent(s.ctx, event.ID, update))
+
+	stored, err := s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal("Event after update", stored.Title)
+	s.True(update.DateTime.Equal(stored.DateTime))
+
+	// keep the same time -- it is not busy by itself
+	update.NotifyAt = s.date(time.Now())
+	s.Require().NoError(s.st.UpdateEvent(s.ctx, event.ID, update))
+
+	stored, err = s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.True(update.NotifyAt.Equal(stored.NotifyAt))
+}
+
+func (s *Suite) TestUpdateNotFound() {
+	err := s.st.UpdateEvent(s.ctx, uuid.New(), s.newEvent("Event", time.Now()))
+	s.ErrorIs(err, storage.ErrEventNotFound)
+}
+
+func (s *Suite) TestUpdateBusyTime() {
+	first := s.newEvent("First", time.Now())
+	second := s.newEvent("Second", time.Now().Add(time.Hour))
+	s.create(first, second)
+
+	err := s.st.UpdateEvent(s.ctx, second.ID, s.newEvent("Second", first.DateTime))
+	s.ErrorIs(err, storage.ErrEventDateTimeIsBusy)
+}
+
+func (s *Suite) TestReturnedEventIsCopy() {
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+
+	stored, err := s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	stored.Title = "Changed outside"
+
+	stored, err = s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal("Event", stored.Title)
+}
+
+func (s *Suite) TestDelete() {
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+
+	s.Require().NoError(s.st.DeleteEvent(s.ctx, event.ID))
+
+	_, err := s.st.GetEvent(s.ctx, event.ID)
+	s.ErrorIs(err, storage.ErrEventNotFound)
+
+	s.ErrorIs(s.st.DeleteEvent(s.ctx, event.ID), storage.ErrEventNotFound)
+}
+
+func (s *Suite) TestGetEvents() {
+	now := time.Now()
+	s.create(
+		s.newEvent("Second", now.Add(time.Hour)),
+		s.newEvent("First", now),
+		s.newEvent("Third", now.Add(2*time.Hour)),
+	)
+
+	events, err := s.st.GetEvents(s.ctx, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{"First", "Second", "Third"}, s.titles(events))
+}
+
+func (s *Suite) TestFilterByTag() {
+	start := time.Date(2023, 12, 4, 0, 0, 0, 0, time.UTC)
+	work := s.newEvent("Work", start.Add(time.Hour))
+	work.Tags = []string{"work"}
+	both := s.newEvent("Both", start.Add(2*time.Hour))
+	both.Tags = []string{"home", "work"}
+	untagged := s.newEvent("Untagged", start.Add(3*time.Hour))
+	s.create(work, both, untagged)
+
+	filter := storage.EventFilter{Tag: "work"}
+
+	events, err := s.st.GetEvents(s.ctx, filter)
+	s.Require().NoError(err)
+	s.Equal([]string{"Work", "Both"}, s.titles(events))
+
+	events, err = s.st.GetEventsForDay(s.ctx, start, filter)
+	s.Require().NoError(err)
+	s.Equal([]string{"Work", "Both"}, s.titles(events))
+
+	events, err = s.st.GetEventsForWeek(s.ctx, start, storage.EventFilter{Tag: "home"})
+	s.Require().NoError(err)
+	s.Equal([]string{"Both"}, s.titles(events))
+
+	events, err = s.st.GetEventsForMonth(s.ctx, start, storage.EventFilter{Tag: "unknown"})
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *Suite) TestGetEventByDate() {
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+
+	stored, err := s.st.GetEventByDate(s.ctx, event.DateTime, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal(event.ID, stored.ID)
+
+	_, err = s.st.GetEventByDate(s.ctx, event.DateTime.Add(time.Second), storage.EventFilter{})
+	s.ErrorIs(err, storage.ErrEventNotFound)
+
+	// event of another user at the same time does not hide the event of the user
+	private := &storage.Calendar{OwnerID: 2, Name: "Private"}
+	s.Require().NoError(s.st.CreateCalendar(s.ctx, private))
+	for _, userID := range []int64{2, 4} {
+		other := s.newEvent("Other", event.DateTime)
+		other.CalendarID = private.ID
+		other.UserID = userID
+		s.create(other)
+	}
+
+	stored, err = s.st.GetEventByDate(s.ctx, event.DateTime, storage.EventFilter{UserID: 1})
+	s.Require().NoError(err)
+	s.Equal(event.ID, stored.ID)
+
+	stored, err = s.st.GetEventByDate(s.ctx, event.DateTime, storage.EventFilter{UserID: 2})
+	s.Require().NoError(err)
+	s.Equal("Other", stored.Title)
+
+	_, err = s.st.GetEventByDate(s.ctx, event.DateTime, storage.EventFilter{UserID: 3})
+	s.ErrorIs(err, storage.ErrEventNotFound)
+}
+
+func (s *Suite) TestRanges() {
+	start := time.Date(2023, 12, 4, 0, 0, 0, 0, time.UTC)
+	events := []*storage.Event{
+		s.newEvent("Before", start.Add(-time.Second)),
+		s.newEvent("Start of day", start),
+		s.newEvent("End of day", start.Add(24*time.Hour-time.Second)),
+		s.newEvent("Next day", start.Add(24*time.Hour)),
+		s.newEvent("End of week", start.AddDate(0, 0, 7).Add(-time.Second)),
+		s.newEvent("Next week", start.AddDate(0, 0, 7)),
+		s.newEvent("End of month", start.AddDate(0, 1, 0).Add(-time.Second)),
+		s.newEvent("Next month", start.AddDate(0, 1, 0)),
+	}
+	// events without duration are matched by their start.
+	for _, event := range events {
+		event.EndTime = event.DateTime
+	}
+	s.create(events...)
+
+	events, err := s.st.GetEventsForDay(s.ctx, start, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Start of day", "End of day"}, s.titles(events))
+
+	events, err = s.st.GetEventsForWeek(s.ctx, start, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Start of day", "End of day", "Next day", "End of week"}, s.titles(events))
+
+	events, err = s.st.GetEventsForMonth(s.ctx, start, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal(
+		[]string{"Start of day", "End of day", "Next day", "End of week", "Next week", "End of month"},
+		s.titles(events),
+	)
+
+	events, err = s.st.GetEventsForDay(s.ctx, start.AddDate(1, 0, 0), storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *Suite) TestOverlappingRanges() {
+	start := time.Date(2023, 12, 4, 0, 0, 0, 0, time.UTC)
+
+	ended := s.newEvent("Ended at start", start.Add(-time.Hour))
+	running := s.newEvent("Running at start", start.Add(-time.Minute))
+	trip := s.newEvent("Trip", start.AddDate(0, 0, -2))
+	trip.EndTime = start.AddDate(0, 0, 3)
+	late := s.newEvent("Late", start.Add(23*time.Hour+30*time.Minute))
+	s.create(ended, running, trip, late)
+
+	events, err := s.st.GetEventsForDay(s.ctx, start, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Trip", "Running at start", "Late"}, s.titles(events))
+
+	events, err = s.st.GetEventsForDay(s.ctx, start.AddDate(0, 0, 1), storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Trip", "Late"}, s.titles(events))
+
+	events, err = s.st.GetEventsForDay(s.ctx, start.AddDate(0, 0, 3), storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *Suite) TestAllDayEvents() {
+	day := time.Date(2023, 12, 4, 0, 0, 0, 0, time.UTC)
+
+	holiday := s.newEvent("Holiday", day)
+	holiday.AllDay = true
+	holiday.EndTime = day.AddDate(0, 0, 1)
+	vacation := s.newEvent("Vacation", day.AddDate(0, 0, 1))
+	vacation.AllDay = true
+	vacation.EndTime = day.AddDate(0, 0, 8)
+	s.create(holiday, vacation)
+
+	stored, err := s.st.GetEvent(s.ctx, holiday.ID)
+	s.Require().NoError(err)
+	s.True(stored.AllDay)
+	s.True(day.Equal(stored.DateTime))
+	s.True(day.AddDate(0, 0, 1).Equal(stored.EndTime))
+
+	// the days are the same in every time zone.
+	for _, name := range []string{"UTC", "Asia/Tokyo", "America/Los_Angeles"} {
+		location, err := time.LoadLocation(name)
+		s.Require().NoError(err)
+		start := time.Date(2023, 12, 4, 0, 0, 0, 0, location)
+
+		events, err := s.st.GetEventsForDay(s.ctx, start, storage.EventFilter{})
+		s.Require().NoError(err)
+		s.Equal([]string{"Holiday"}, s.titles(events), name)
+
+		events, err = s.st.GetEventsForDay(s.ctx, start.AddDate(0, 0, 7), storage.EventFilter{})
+		s.Require().NoError(err)
+		s.Equal([]string{"Vacation"}, s.titles(events), name)
+
+		events, err = s.st.GetEventsForDay(s.ctx, start.AddDate(0, 0, 8), storage.EventFilter{})
+		s.Require().NoError(err)
+		s.Empty(events, name)
+
+		events, err = s.st.GetEventsForWeek(s.ctx, start, storage.EventFilter{})
+		s.Require().NoError(err)
+		s.Equal([]string{"Holiday", "Vacation"}, s.titles(events), name)
+	}
+}
+
+func (s *Suite) TestGetEventsForNotifications() {
+	now := time.Now()
+
+	due := s.newEvent("Due", now.Add(time.Hour))
+	due.TimeNotification = s.date(now.Add(-time.Minute))
+
+	notified := s.newEvent("Already notified", now.Add(2*time.Hour))
+	notified.TimeNotification = s.date(now.Add(-time.Minute))
+	notified.NotifyAt = s.date(now)
+
+	future := s.newEvent("Future", now.Add(3*time.Hour))
+	future.TimeNotification = s.date(now.Add(time.Hour))
+
+	without := s.newEvent("Without notification", now.Add(4*time.Hour))
+
+	s.create(due, notified, future, without)
+
+	events, err := s.st.GetEventsForNotifications(s.ctx)
+	s.Require().NoError(err)
+	s.Equal([]string{"Due"}, s.titles(events))
+
+	// mark as notified, just like scheduler does
+	event := events[0]
+	event.NotifyAt = s.date(time.Now())
+	s.Require().NoError(s.st.UpdateEvent(s.ctx, event.ID, event))
+
+	events, err = s.st.GetEventsForNotifications(s.ctx)
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *Suite) TestGetUpcomingNotifications() {
+	now := time.Now()
+
+	later := s.newEvent("Later", now.Add(time.Hour))
+	later.TimeNotification = s.date(now.Add(30 * time.Minute))
+
+	soon := s.newEvent("Soon", now.Add(2*time.Hour))
+	soon.TimeNotification = s.date(now.Add(10 * time.Minute))
+
+	due := s.newEvent("Due", now.Add(3*time.Hour))
+	due.TimeNotification = s.date(now.Add(-time.Minute))
+
+	notified := s.newEvent("Already notified", now.Add(4*time.Hour))
+	notified.TimeNotification = s.date(now.Add(-time.Minute))
+	notified.NotifyAt = s.date(now)
+
+	tomorrow := s.newEvent("Tomorrow", now.Add(24*time.Hour))
+	tomorrow.TimeNotification = s.date(now.Add(23 * time.Hour))
+
+	s.create(later, soon, due, notified, tomorrow, s.newEvent("Without notification", now))
+
+	events, err := s.st.GetUpcomingNotifications(s.ctx, now.Add(time.Hour))
+	s.Require().NoError(err)
+	s.Equal([]string{"Due", "Soon", "Later"}, s.titles(events))
+}
+
+func (s *Suite) TestWatchEvents() {
+	// scheduler reacts to changes at once only with storages that report them.
+	watcher, ok := s.st.(storage.EventWatcher)
+	s.Require().True(ok, "storage must implement storage.EventWatcher")
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	changes, err := watcher.WatchEvents(ctx)
+	s.Require().NoError(err)
+
+	event := s.newEvent("Event", time.Now())
+	s.create(event)
+	s.Equal(event.ID, s.nextChange(changes))
+
+	event.TimeNotification = s.date(time.Now())
+	s.Require().NoError(s.st.UpdateEvent(s.ctx, event.ID, event))
+	s.Equal(event.ID, s.nextChange(changes))
+
+	// failed writes are not reported
+	busy := s.newEvent("Busy", event.DateTime)
+	s.ErrorIs(s.st.CreateEvent(s.ctx, busy), storage.ErrEventDateTimeIsBusy)
+
+	s.Require().NoError(s.st.DeleteEvent(s.ctx, event.ID))
+	s.Equal(event.ID, s.nextChange(changes))
+
+	// events of the deleted calendar are reported too
+	calendar := &storage.Calendar{OwnerID: 1, Name: "Work"}
+	s.Require().NoError(s.st.CreateCalendar(s.ctx, calendar))
+	event = s.newEvent("Event", time.Now())
+	event.CalendarID = calendar.ID
+	s.create(event)
+	s.Equal(event.ID, s.nextChange(changes))
+	s.Require().NoError(s.st.DeleteCalendar(s.ctx, calendar.ID))
+	s.Equal(event.ID, s.nextChange(changes))
+
+	cancel()
+	s.Eventually(func() bool {
+		_, open := <-changes
+		return !open
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (s *Suite) nextChange(changes <-chan uuid.UUID) uuid.UUID {
+	select {
+	case eventID := <-changes:
+		return eventID
+	case <-time.After(5 * time.Second):
+		s.FailNow("no change notification")
+		return uuid.Nil
+	}
+}
+
+func (s *Suite) TestClaimEventsForNotifications() {
+	claimer, ok := s.st.(storage.NotificationClaimer)
+	s.Require().True(ok, "storage must implement storage.NotificationClaimer")
+
+	now := time.Now()
+	for i, title := range []string{"First", "Second", "Third"} {
+		event := s.newEvent(title, now.Add(time.Duration(i+1)*time.Hour))
+		event.TimeNotification = s.date(now.Add(-time.Minute))
+		s.create(event)
+	}
+
+	future := s.newEvent("Future", now.Add(5*time.Hour))
+	future.TimeNotification = s.date(now.Add(time.Hour))
+	s.create(future)
+
+	// batch is limited and instances never get the same events
+	events, err := claimer.ClaimEventsForNotifications(s.ctx, "first", time.Minute, 2)
+	s.Require().NoError(err)
+	s.Equal([]string{"First", "Second"}, s.titles(events))
+
+	events, err = claimer.ClaimEventsForNotifications(s.ctx, "second", time.Minute, 10)
+	s.Require().NoError(err)
+	s.Equal([]string{"Third"}, s.titles(events))
+
+	events, err = claimer.ClaimEventsForNotifications(s.ctx, "second", time.Minute, 10)
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *Suite) TestClaimExpiredLease() {
+	claimer, ok := s.st.(storage.NotificationClaimer)
+	s.Require().True(ok, "storage must implement storage.NotificationClaimer")
+
+	now := time.Now()
+	due := s.newEvent("Due", now.Add(time.Hour))
+	due.TimeNotification = s.date(now.Add(-time.Minute))
+	s.create(due)
+
+	events, err := claimer.ClaimEventsForNotifications(s.ctx, "dead", time.Millisecond, 10)
+	s.Require().NoError(err)
+	s.Len(events, 1)
+
+	// owner has died without marking event as notified, so it is picked up again
+	time.Sleep(50 * time.Millisecond)
+
+	events, err = claimer.ClaimEventsForNotifications(s.ctx, "alive", time.Minute, 10)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"Due"}, s.titles(events))
+
+	event := events[0]
+	event.NotifyAt = s.date(time.Now())
+	s.Require().NoError(s.st.UpdateEvent(s.ctx, event.ID, event))
+
+	time.Sleep(50 * time.Millisecond)
+
+	events, err = claimer.ClaimEventsForNotifications(s.ctx, "other", time.Millisecond, 10)
+	s.Require().NoError(err)
+	s.Empty(events)
+}
+
+func (s *Suite) TestPreferences() {
+	_, err := s.st.GetPreferences(s.ctx, 1)
+	s.ErrorIs(err, storage.ErrPreferencesNotFound)
+
+	preferences := &storage.Preferences{
+		UserID:     1,
+		Channel:    "email",
+		Address:    "user@example.com",
+		Locale:     "en",
+		TimeZone:   "Europe/Moscow",
+		QuietStart: "22:00",
+		QuietEnd:   "08:00",
+		Digest:     storage.DigestDaily,
+	}
+	s.Require().NoError(s.st.SavePreferences(s.ctx, preferences))
+
+	got, err := s.st.GetPreferences(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal(preferences, got)
+
+	// saving again replaces preferences
+	preferences.Channel = "sms"
+	preferences.Address = "+70000000000"
+	preferences.Digest = storage.DigestOff
+	s.Require().NoError(s.st.SavePreferences(s.ctx, preferences))
+
+	got, err = s.st.GetPreferences(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal(preferences, got)
+
+	s.Require().NoError(s.st.DeletePreferences(s.ctx, 1))
+	s.ErrorIs(s.st.DeletePreferences(s.ctx, 1), storage.ErrPreferencesNotFound)
+
+	_, err = s.st.GetPreferences(s.ctx, 1)
+	s.ErrorIs(err, storage.ErrPreferencesNotFound)
+}
+
+func (s *Suite) TestTags() {
+	tags, err := s.st.GetTags(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Empty(tags)
+
+	s.Require().NoError(s.st.SaveTag(s.ctx, &storage.Tag{UserID: 1, Name: "work", Color: "#0000ff"}))
+	s.Require().NoError(s.st.SaveTag(s.ctx, &storage.Tag{UserID: 2, Name: "sport"}))
+
+	// tags of the event are created, existing ones keep color
+	event := s.newEvent("Event", time.Now())
+	event.Tags = []string{"work", "home"}
+	s.create(event)
+
+	tags, err = s.st.GetTags(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal([]*storage.Tag{
+		{UserID: 1, Name: "home"},
+		{UserID: 1, Name: "work", Color: "#0000ff"},
+	}, tags)
+
+	// saving again changes color
+	s.Require().NoError(s.st.SaveTag(s.ctx, &storage.Tag{UserID: 1, Name: "home", Color: "#00ff00"}))
+	tags, err = s.st.GetTags(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal("#00ff00", tags[0].Color)
+
+	// deleted tag is removed from events
+	s.Require().NoError(s.st.DeleteTag(s.ctx, 1, "work"))
+	s.ErrorIs(s.st.DeleteTag(s.ctx, 1, "work"), storage.ErrTagNotFound)
+	s.ErrorIs(s.st.DeleteTag(s.ctx, 1, "sport"), storage.ErrTagNotFound)
+
+	stored, err := s.st.GetEvent(s.ctx, event.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"home"}, stored.Tags)
+
+	tags, err = s.st.GetTags(s.ctx, 2)
+	s.Require().NoError(err)
+	s.Equal([]*storage.Tag{{UserID: 2, Name: "sport"}}, tags)
+}
+
+func (s *Suite) TestCalendars() {
+	// the default calendar is created once
+	def, err := s.st.DefaultCalendar(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal(s.calendar, def.ID)
+	s.True(def.Default)
+	s.Equal(storage.DefaultCalendarName, def.Name)
+
+	work := &storage.Calendar{OwnerID: 1, Name: "Work", TimeZone: "Europe/Moscow", Color: "#0000ff", Default: true}
+	s.Require().NoError(s.st.CreateCalendar(s.ctx, work))
+	s.NotEqual(uuid.Nil, work.ID)
+
+	stored, err := s.st.GetCalendar(s.ctx, work.ID)
+	s.Require().NoError(err)
+	s.Equal("Europe/Moscow", stored.TimeZone)
+	s.False(stored.Default, "only DefaultCalendar creates default calendar")
+
+	// update keeps the owner
+	stored.Name = "Job"
+	stored.OwnerID = 2
+	s.Require().NoError(s.st.UpdateCalendar(s.ctx, stored))
+	stored, err = s.st.GetCalendar(s.ctx, work.ID)
+	s.Require().NoError(err)
+	s.Equal("Job", stored.Name)
+	s.Equal(int64(1), stored.OwnerID)
+
+	s.ErrorIs(s.st.UpdateCalendar(s.ctx, &storage.Calendar{ID: uuid.New(), Name: "Unknown"}), storage.ErrCalendarNotFound)
+	_, err = s.st.GetCalendar(s.ctx, uuid.New())
+	s.ErrorIs(err, storage.ErrCalendarNotFound)
+
+	calendars, err := s.st.GetCalendars(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Len(calendars, 2)
+	s.Equal(storage.DefaultCalendarName, calendars[0].Name)
+	s.Equal("Job", calendars[1].Name)
+
+	// deleted calendar takes its events with it
+	event := s.newEvent("Event", time.Now())
+	event.CalendarID = work.ID
+	s.create(event)
+
+	s.Require().NoError(s.st.DeleteCalendar(s.ctx, work.ID))
+	s.ErrorIs(s.st.DeleteCalendar(s.ctx, work.ID), storage.ErrCalendarNotFound)
+
+	_, err = s.st.GetEvent(s.ctx, event.ID)
+	s.ErrorIs(err, storage.ErrEventNotFound)
+}
+
+func (s *Suite) TestShares() {
+	family := &storage.Calendar{OwnerID: 2, Name: "Family"}
+	s.Require().NoError(s.st.CreateCalendar(s.ctx, family))
+
+	s.Require().NoError(s.st.ShareCalendar(s.ctx, &storage.Share{CalendarID: family.ID, UserID: 3, Role: storage.RoleRead}))
+	s.Require().NoError(s.st.ShareCalendar(s.ctx, &storage.Share{CalendarID: family.ID, UserID: 1, Role: storage.RoleRead}))
+	// sharing again changes the role
+	s.Require().NoError(s.st.ShareCalendar(s.ctx, &storage.Share{CalendarID: family.ID, UserID: 1, Role: storage.RoleWrite}))
+
+	s.ErrorIs(
+		s.st.ShareCalendar(s.ctx, &storage.Share{CalendarID: uuid.New(), UserID: 1, Role: storage.RoleRead}),
+		storage.ErrCalendarNotFound,
+	)
+
+	shares, err := s.st.GetShares(s.ctx, family.ID)
+	s.Require().NoError(err)
+	s.Equal([]*storage.Share{
+		{CalendarID: family.ID, UserID: 1, Role: storage.RoleWrite},
+		{CalendarID: family.ID, UserID: 3, Role: storage.RoleRead},
+	}, shares)
+
+	shares, err = s.st.GetUserShares(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal([]*storage.Share{{CalendarID: family.ID, UserID: 1, Role: storage.RoleWrite}}, shares)
+
+	calendars, err := s.st.GetCalendars(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Equal([]string{storage.DefaultCalendarName, "Family"}, []string{calendars[0].Name, calendars[1].Name})
+
+	s.Require().NoError(s.st.UnshareCalendar(s.ctx, family.ID, 1))
+	s.ErrorIs(s.st.UnshareCalendar(s.ctx, family.ID, 1), storage.ErrShareNotFound)
+
+	shares, err = s.st.GetUserShares(s.ctx, 1)
+	s.Require().NoError(err)
+	s.Empty(shares)
+
+	// shares go away with the calendar
+	s.Require().NoError(s.st.DeleteCalendar(s.ctx, family.ID))
+	shares, err = s.st.GetUserShares(s.ctx, 3)
+	s.Require().NoError(err)
+	s.Empty(shares)
+}
+
+func (s *Suite) TestVisibleCalendars() {
+	start := time.Date(2023, 12, 4, 0, 0, 0, 0, time.UTC)
+
+	shared := &storage.Calendar{OwnerID: 2, Name: "Shared"}
+	private := &storage.Calendar{OwnerID: 2, Name: "Private"}
+	s.Require().NoError(s.st.CreateCalendar(s.ctx, shared))
+	s.Require().NoError(s.st.CreateCalendar(s.ctx, private))
+	s.Require().NoError(s.st.ShareCalendar(s.ctx, &storage.Share{CalendarID: shared.ID, UserID: 1, Role: storage.RoleFreeBusy}))
+
+	own := s.newEvent("Own", start.Add(time.Hour))
+	fromShared := s.newEvent("Shared", start.Add(2*time.Hour))
+	fromShared.CalendarID = shared.ID
+	fromShared.UserID = 2
+	fromPrivate := s.newEvent("Private", start.Add(3*time.Hour))
+	fromPrivate.CalendarID = private.ID
+	fromPrivate.UserID = 2
+	s.create(own, fromShared, fromPrivate)
+
+	user1 := storage.EventFilter{UserID: 1}
+
+	events, err := s.st.GetEventsForDay(s.ctx, start, user1)
+	s.Require().NoError(err)
+	s.Equal([]string{"Own", "Shared"}, s.titles(events))
+
+	events, err = s.st.GetEventsForWeek(s.ctx, start, storage.EventFilter{UserID: 2})
+	s.Require().NoError(err)
+	s.Equal([]string{"Shared", "Private"}, s.titles(events))
+
+	events, err = s.st.GetEventsForMonth(s.ctx, start, storage.EventFilter{UserID: 3})
+	s.Require().NoError(err)
+	s.Empty(events)
+
+	events, err = s.st.GetEvents(s.ctx, user1)
+	s.Require().NoError(err)
+	s.Equal([]string{"Own", "Shared"}, s.titles(events))
+
+	// without user events of all calendars are returned
+	events, err = s.st.GetEvents(s.ctx, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Len(events, 3)
+}
+
+func (s *Suite) TestPendingNotifications() {
+	now := time.Now()
+	for i, title := range []string{"Later", "Second", "First"} {
+		s.Require().NoError(s.st.AddPendingNotification(s.ctx, &storage.PendingNotification{
+			Notification: storage.Notification{
+				EventID:  uuid.NewString(),
+				Title:    title,
+				DateTime: s.date(now.Add(time.Hour)),
+				UserID:   1,
+			},
+			DeliverAt: s.date(now.Add(time.Duration(1-i) * time.Hour)),
+		}))
+	}
+
+	pending, err := s.st.TakePendingNotifications(s.ctx, now)
+	s.Require().NoError(err)
+	s.Require().Len(pending, 2)
+	s.Equal("First", pending[0].Notification.Title)
+	s.Equal("Second", pending[1].Notification.Title)
+	s.Equal(int64(1), pending[0].Notification.UserID)
+	s.True(s.date(now.Add(time.Hour)).Equal(pending[0].Notification.DateTime))
+	s.NotEqual(uuid.Nil, pending[0].ID)
+
+	// taken notifications are removed
+	pending, err = s.st.TakePendingNotifications(s.ctx, now)
+	s.Require().NoError(err)
+	s.Empty(pending)
+
+	pending, err = s.st.TakePendingNotifications(s.ctx, now.Add(2*time.Hour))
+	s.Require().NoError(err)
+	s.Require().Len(pending, 1)
+	s.Equal("Later", pending[0].Notification.Title)
+}
+
+func (s *Suite) TestAgendaJournal() {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	s.Require().NoError(err)
+
+	// 10 January in Moscow, but still 9 January in UTC
+	day := time.Date(2024, 1, 10, 1, 0, 0, 0, moscow)
+
+	claimed, err := s.st.ClaimAgenda(s.ctx, 1, day)
+	s.Require().NoError(err)
+	s.True(claimed)
+
+	// the agenda is claimed only once for the date
+	claimed, err = s.st.ClaimAgenda(s.ctx, 1, time.Date(2024, 1, 10, 23, 0, 0, 0, moscow))
+	s.Require().NoError(err)
+	s.False(claimed)
+
+	claimed, err = s.st.ClaimAgenda(s.ctx, 1, day.UTC())
+	s.Require().NoError(err)
+	s.True(claimed)
+
+	claimed, err = s.st.ClaimAgenda(s.ctx, 2, day)
+	s.Require().NoError(err)
+	s.True(claimed)
+
+	// released agenda may be claimed again
+	s.Require().NoError(s.st.ReleaseAgenda(s.ctx, 1, day))
+	claimed, err = s.st.ClaimAgenda(s.ctx, 1, day)
+	s.Require().NoError(err)
+	s.True(claimed)
+}
+
+func (s *Suite) TestConcurrentAgendaClaims() {
+	const workers = 20
+
+	day := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	// schedulers that run together: exactly one claims the agenda
+	var claims int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := s.st.ClaimAgenda(s.ctx, 1, day)
+			s.NoError(err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if claimed {
+				claims++
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(1, claims)
+}
+
+func (s *Suite) TestDeleteOldEvents() {
+	now := time.Now()
+	s.create(
+		s.newEvent("Two years ago", now.AddDate(-2, 0, 0)),
+		s.newEvent("More than year ago", now.AddDate(-1, -1, 0)),
+		s.newEvent("Month ago", now.AddDate(0, -1, 0)),
+		s.newEvent("Now", now),
+	)
+	project := s.newEvent("Project", now.AddDate(-2, 0, 1))
+	project.EndTime = s.date(now.AddDate(0, 1, 0))
+	s.create(project)
+
+	count, err := s.st.DeleteOldEvents(s.ctx, 365*24*time.Hour)
+	s.Require().NoError(err)
+	s.Equal(2, count)
+
+	// the project started long ago, but it is not over yet.
+	events, err := s.st.GetEvents(s.ctx, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Project", "Month ago", "Now"}, s.titles(events))
+}
+
+func (s *Suite) TestConcurrentAccess() {
+	const workers = 20
+
+	start := time.Now()
+	ids := make(chan uuid.UUID, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			event := s.newEvent("Event", start.Add(time.Duration(i)*time.Hour))
+			if err := s.st.CreateEvent(s.ctx, event); err != nil {
+				s.Fail("cannot create event", err.Error())
+				return
+			}
+			ids <- event.ID
+
+			if _, err := s.st.GetEvents(s.ctx, storage.EventFilter{}); err != nil {
+				s.Fail("cannot get events", err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	events, err := s.st.GetEvents(s.ctx, storage.EventFilter{})
+	s.Require().NoError(err)
+	s.Require().Len(events, workers)
+
+	// concurrent deletion of the same event: exactly one succeeds
+	var deleted, notFound int
+	var mu sync.Mutex
+	eventID := <-ids
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.st.DeleteEvent(s.ctx, eventID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				deleted++
+			case s.ErrorIs(err, storage.ErrEventNotFound):
+				notFound++
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(1, deleted)
+	s.Equal(workers-1, notFound)
+}
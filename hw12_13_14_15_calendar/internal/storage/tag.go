@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrTagNotFound = errors.New("tag not found")
+
+// Tag is a category of events of the user, the name is unique for the user.
+// Color is "#RRGGBB" or empty.
+type Tag struct {
+	UserID int64  `json:"user_id"` //nolint:tagliatelle
+	Name   string `json:"name"`
+	Color  string `json:"color"`
+}
+
+// EventFilter narrows lists of events, the zero value matches all events.
+type EventFilter struct {
+	// Tag matches events that have the tag.
+	Tag string
+	// UserID matches events of calendars owned by or shared with the user, 0 matches all calendars.
+	UserID int64
+}
+
+// Match checks the tag of the event, calendars of UserID are checked by storages.
+func (f EventFilter) Match(event *Event) bool {
+	if f.Tag == "" {
+		return true
+	}
+
+	for _, tag := range event.Tags {
+		if tag == f.Tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SortTags returns sorted copy of tags without duplicates.
+func SortTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	res := append([]string(nil), tags...)
+	sort.Strings(res)
+
+	unique := res[:1]
+	for _, tag := range res[1:] {
+		if tag != unique[len(unique)-1] {
+			unique = append(unique, tag)
+		}
+	}
+
+	return unique
+}
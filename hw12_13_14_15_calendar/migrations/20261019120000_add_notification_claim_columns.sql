@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE event
+ADD COLUMN notify_claimed_by VARCHAR(255),
+ADD COLUMN notify_claimed_until TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX event_pending_notification_idx ON event (notification_time) WHERE notify_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS event_pending_notification_idx;
+
+ALTER TABLE event
+DROP COLUMN notify_claimed_by,
+DROP COLUMN notify_claimed_until;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_event_changes() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('event_changes', OLD.id::text);
+        RETURN OLD;
+    END IF;
+
+    PERFORM pg_notify('event_changes', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER event_changes_trigger
+AFTER INSERT OR DELETE OR UPDATE OF date_time, notification_time, notify_at ON event
+FOR EACH ROW EXECUTE FUNCTION notify_event_changes();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS event_changes_trigger ON event;
+DROP FUNCTION IF EXISTS notify_event_changes();
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_preferences (
+    user_id     BIGINT PRIMARY KEY,
+    channel     VARCHAR(32) NOT NULL,
+    address     VARCHAR(255) NOT NULL,
+    locale      VARCHAR(16) NOT NULL DEFAULT '',
+    time_zone   VARCHAR(64) NOT NULL DEFAULT '',
+    quiet_start VARCHAR(5) NOT NULL DEFAULT '',
+    quiet_end   VARCHAR(5) NOT NULL DEFAULT '',
+    digest      VARCHAR(16) NOT NULL DEFAULT '',
+    updated_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE pending_notification (
+    id         UUID PRIMARY KEY,
+    user_id    BIGINT NOT NULL,
+    payload    JSONB NOT NULL,
+    deliver_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX pending_notification_deliver_at_idx ON pending_notification (deliver_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pending_notification;
+DROP TABLE IF EXISTS user_preferences;
+-- +goose StatementEnd
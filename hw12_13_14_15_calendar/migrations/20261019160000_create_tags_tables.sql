@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE event
+    ADD COLUMN color    VARCHAR(7) NOT NULL DEFAULT '',
+    ADD COLUMN location TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE tag (
+    id      BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name    VARCHAR(64) NOT NULL,
+    color   VARCHAR(7) NOT NULL DEFAULT '',
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE event_tag (
+    event_id UUID NOT NULL REFERENCES event (id) ON DELETE CASCADE,
+    tag_id   BIGINT NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+    PRIMARY KEY (event_id, tag_id)
+);
+
+CREATE INDEX event_tag_tag_id_idx ON event_tag (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS event_tag;
+DROP TABLE IF EXISTS tag;
+ALTER TABLE event DROP COLUMN IF EXISTS color, DROP COLUMN IF EXISTS location;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE calendar (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id   BIGINT NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    time_zone  VARCHAR(64) NOT NULL DEFAULT '',
+    color      VARCHAR(7) NOT NULL DEFAULT '',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX calendar_owner_id_idx ON calendar (owner_id);
+CREATE UNIQUE INDEX calendar_default_idx ON calendar (owner_id) WHERE is_default;
+
+CREATE TABLE calendar_share (
+    calendar_id UUID NOT NULL REFERENCES calendar (id) ON DELETE CASCADE,
+    user_id     BIGINT NOT NULL,
+    role        VARCHAR(16) NOT NULL,
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+CREATE INDEX calendar_share_user_id_idx ON calendar_share (user_id);
+
+-- existing events go to default calendars of their users
+INSERT INTO calendar (owner_id, name, is_default)
+SELECT DISTINCT user_id, 'Default', TRUE FROM event;
+
+ALTER TABLE event ADD COLUMN calendar_id UUID REFERENCES calendar (id) ON DELETE CASCADE;
+
+UPDATE event SET calendar_id = calendar.id
+FROM calendar
+WHERE calendar.owner_id = event.user_id AND calendar.is_default;
+
+ALTER TABLE event ALTER COLUMN calendar_id SET NOT NULL;
+
+CREATE INDEX event_calendar_id_idx ON event (calendar_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE event DROP COLUMN IF EXISTS calendar_id;
+DROP TABLE IF EXISTS calendar_share;
+DROP TABLE IF EXISTS calendar;
+-- +goose StatementEnd
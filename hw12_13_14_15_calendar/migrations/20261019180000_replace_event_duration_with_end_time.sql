@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE event
+    ADD COLUMN end_time TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN all_day  BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- duration was kept in seconds, though some clients wrote Unix timestamps there:
+-- events with implausible durations take no time instead of lasting for decades
+UPDATE event SET end_time = CASE
+    WHEN duration > 0 AND duration < 366 * 86400 THEN date_time + duration * INTERVAL '1 second'
+    ELSE date_time
+END;
+
+ALTER TABLE event
+    ALTER COLUMN end_time SET NOT NULL,
+    DROP COLUMN duration;
+
+-- range queries look for events that overlap the range
+CREATE INDEX event_date_time_end_time_idx ON event (date_time, end_time);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS event_date_time_end_time_idx;
+
+ALTER TABLE event ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;
+
+UPDATE event SET duration = EXTRACT(EPOCH FROM end_time - date_time)::INTEGER;
+
+ALTER TABLE event
+    DROP COLUMN end_time,
+    DROP COLUMN all_day;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+// Package migrations embeds SQL migrations of the database, so the binaries do not need
+// the directory on disk.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,46 @@
+// Package broker hides message queue behind small interfaces, so scheduler and sender
+// do not depend on AMQP and can be tested without running RabbitMQ.
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrClosed = errors.New("broker is closed")
+	// ErrNotConfirmed means that broker has not confirmed the message, it may be lost.
+	ErrNotConfirmed = errors.New("message is not confirmed by broker")
+	// ErrUnroutable means that there is no queue for the message, so nobody will receive it.
+	ErrUnroutable = errors.New("message is not routed to any queue")
+	// ErrReject is returned (possibly wrapped) by handler for messages that can never be handled.
+	// Such messages are dropped instead of delivering them again.
+	ErrReject = errors.New("message is rejected by handler")
+)
+
+type Message struct {
+	ContentType string `json:"content_type"` //nolint:tagliatelle
+	Body        []byte `json:"body"`
+}
+
+type Publisher interface {
+	// Publish returns nil only when message is safely stored by broker.
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Handler processes a single message. Message is acknowledged when handler returns nil,
+// dropped when error wraps ErrReject, otherwise it is returned to the queue and delivered again later.
+type Handler func(ctx context.Context, msg Message) error
+
+type Consumer interface {
+	// Consume runs threads workers and blocks until ctx is done and all workers are stopped.
+	// Messages that are received but not handled yet are returned to the queue on stop.
+	Consume(ctx context.Context, handler Handler, threads int) error
+}
+
+type Broker interface {
+	Publisher
+	Consumer
+	Connect(ctx context.Context) error
+	Close() error
+}
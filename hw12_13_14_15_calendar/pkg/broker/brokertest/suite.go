@@ -0,0 +1,220 @@
+// Package brokertest contains conformance tests that every broker.Broker implementation has to pass.
+package brokertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	"github.com/stretchr/testify/suite"
+)
+
+const waitTimeout = 5 * time.Second
+
+// Factory returns connected and empty broker.
+// Releasing of resources should be registered with t.Cleanup.
+type Factory func(t *testing.T) broker.Broker
+
+type Suite struct {
+	suite.Suite
+	factory Factory
+	ctx     context.Context
+	b       broker.Broker
+}
+
+// Run executes conformance suite for the broker built by factory.
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+	suite.Run(t, &Suite{factory: factory})
+}
+
+func (s *Suite) SetupTest() {
+	s.ctx = context.Background()
+	s.b = s.factory(s.T())
+}
+
+func (s *Suite) publish(bodies ...string) {
+	for _, body := range bodies {
+		s.Require().NoError(s.b.Publish(s.ctx, broker.Message{ContentType: "text/plain", Body: []byte(body)}))
+	}
+}
+
+// consume runs consumer in background and stops it on test cleanup.
+func (s *Suite) consume(handler broker.Handler, threads int) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		s.NoError(s.b.Consume(ctx, handler, threads))
+	}()
+
+	s.T().Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func (s *Suite) TestPublishAndConsume() {
+	received := make(chan broker.Message, 3)
+	s.consume(func(_ context.Context, msg broker.Message) error {
+		received <- msg
+		return nil
+	}, 1)
+
+	s.publish("first", "second", "third")
+
+	for _, body := range []string{"first", "second", "third"} {
+		select {
+		case msg := <-received:
+			s.Equal(body, string(msg.Body))
+			s.Equal("text/plain", msg.ContentType)
+		case <-time.After(waitTimeout):
+			s.FailNow("message is not received")
+		}
+	}
+}
+
+func (s *Suite) TestMessagesPublishedBeforeConsume() {
+	s.publish("early")
+
+	received := make(chan broker.Message, 1)
+	s.consume(func(_ context.Context, msg broker.Message) error {
+		received <- msg
+		return nil
+	}, 1)
+
+	select {
+	case msg := <-received:
+		s.Equal("early", string(msg.Body))
+	case <-time.After(waitTimeout):
+		s.FailNow("message is not received")
+	}
+}
+
+func (s *Suite) TestFailedMessageIsRedelivered() {
+	var mu sync.Mutex
+	attempts := 0
+	received := make(chan broker.Message, 1)
+
+	s.consume(func(_ context.Context, msg broker.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			return errors.New("temporary failure")
+		}
+
+		received <- msg
+		return nil
+	}, 1)
+
+	s.publish("retry me")
+
+	select {
+	case msg := <-received:
+		s.Equal("retry me", string(msg.Body))
+	case <-time.After(waitTimeout):
+		s.FailNow("message is not redelivered")
+	}
+}
+
+func (s *Suite) TestRejectedMessageIsDropped() {
+	var mu sync.Mutex
+	rejected := 0
+	received := make(chan broker.Message, 1)
+
+	s.consume(func(_ context.Context, msg broker.Message) error {
+		if string(msg.Body) == "broken" {
+			mu.Lock()
+			rejected++
+			mu.Unlock()
+			return fmt.Errorf("cannot parse: %w", broker.ErrReject)
+		}
+
+		received <- msg
+		return nil
+	}, 1)
+
+	s.publish("broken", "valid")
+
+	select {
+	case msg := <-received:
+		s.Equal("valid", string(msg.Body))
+	case <-time.After(waitTimeout):
+		s.FailNow("message is not received")
+	}
+
+	// give a chance for unexpected redelivery
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.Equal(1, rejected)
+}
+
+func (s *Suite) TestSeveralWorkers() {
+	const count = 50
+
+	var mu sync.Mutex
+	var bodies []string
+	var wg sync.WaitGroup
+	wg.Add(count)
+
+	s.consume(func(_ context.Context, msg broker.Message) error {
+		mu.Lock()
+		bodies = append(bodies, string(msg.Body))
+		mu.Unlock()
+		wg.Done()
+		return nil
+	}, 4)
+
+	expected := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		body := fmt.Sprintf("message %02d", i)
+		expected = append(expected, body)
+		s.publish(body)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(waitTimeout):
+		s.FailNow("not all messages are received")
+	}
+
+	// every message is delivered exactly once
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(bodies)
+	s.Equal(expected, bodies)
+}
+
+func (s *Suite) TestConsumeStopsOnContextDone() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan error)
+
+	go func() {
+		done <- s.b.Consume(ctx, func(context.Context, broker.Message) error { return nil }, 2)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		s.NoError(err)
+	case <-time.After(waitTimeout):
+		s.FailNow("consumer is not stopped")
+	}
+}
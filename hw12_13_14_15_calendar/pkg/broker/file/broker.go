@@ -0,0 +1,272 @@
+package filebroker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+)
+
+const (
+	messageExt    = ".msg"
+	tmpExt        = ".tmp"
+	processingDir = "processing"
+
+	defaultPollInterval = 200 * time.Millisecond
+	// message taken by consumer that died is returned to the queue after this time.
+	defaultVisibilityTimeout = 5 * time.Minute
+)
+
+var ErrNotConnected = errors.New("file broker is not connected")
+
+// Broker keeps every message in a separate file of the directory, so messages survive restarts
+// and several processes on the same host can share the queue. Message is taken by atomic rename
+// into processing directory and removed after successful handling.
+type Broker struct {
+	dir               string
+	PollInterval      time.Duration
+	VisibilityTimeout time.Duration
+
+	mu        sync.Mutex
+	connected bool
+}
+
+func New(dir string) *Broker {
+	return &Broker{
+		dir:               dir,
+		PollInterval:      defaultPollInterval,
+		VisibilityTimeout: defaultVisibilityTimeout,
+	}
+}
+
+func (b *Broker) Connect(_ context.Context) error {
+	if err := os.MkdirAll(filepath.Join(b.dir, processingDir), 0o755); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.connected = true
+	b.mu.Unlock()
+
+	return nil
+}
+
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.connected = false
+	b.mu.Unlock()
+
+	return nil
+}
+
+func (b *Broker) isConnected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.connected
+}
+
+// Publish writes message into temporary file and renames it, so consumers never see partially written messages.
+func (b *Broker) Publish(_ context.Context, msg broker.Message) error {
+	if !b.isConnected() {
+		return ErrNotConnected
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	name, err := messageName()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(b.dir, name+tmpExt)
+	if err := writeFile(tmp, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(b.dir, name+messageExt))
+}
+
+func (b *Broker) Consume(ctx context.Context, handler broker.Handler, threads int) error {
+	if !b.isConnected() {
+		return ErrNotConnected
+	}
+
+	jobs := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				b.handle(ctx, handler, path)
+			}
+		}()
+	}
+
+	err := b.dispatch(ctx, jobs)
+	close(jobs)
+	wg.Wait()
+
+	return err
+}
+
+// dispatch takes messages one by one and passes them to free workers.
+func (b *Broker) dispatch(ctx context.Context, jobs chan<- string) error {
+	ticker := time.NewTicker(b.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.restoreExpired(); err != nil {
+			return err
+		}
+
+		names, err := b.pending()
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			path, ok := b.take(name)
+			if !ok {
+				// taken by another consumer
+				continue
+			}
+
+			select {
+			case jobs <- path:
+			case <-ctx.Done():
+				b.release(path)
+				return nil
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *Broker) handle(ctx context.Context, handler broker.Handler, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var msg broker.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		// broken message can not be handled ever, do not return it to the queue.
+		os.Remove(path)
+		return
+	}
+
+	if err := handler(ctx, msg); err != nil && !errors.Is(err, broker.ErrReject) {
+		b.release(path)
+		return
+	}
+
+	os.Remove(path)
+}
+
+// pending returns names of messages in the queue, oldest first.
+func (b *Broker) pending() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), messageExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (b *Broker) take(name string) (string, bool) {
+	path := filepath.Join(b.dir, processingDir, name)
+	if err := os.Rename(filepath.Join(b.dir, name), path); err != nil {
+		return "", false
+	}
+
+	// modification time is the moment of taking, it is checked by restoreExpired.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return path, true
+}
+
+func (b *Broker) release(path string) {
+	os.Rename(path, filepath.Join(b.dir, filepath.Base(path)))
+}
+
+// restoreExpired returns messages of dead consumers back to the queue.
+func (b *Broker) restoreExpired() error {
+	entries, err := os.ReadDir(filepath.Join(b.dir, processingDir))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if time.Since(info.ModTime()) > b.VisibilityTimeout {
+			b.release(filepath.Join(b.dir, processingDir, entry.Name()))
+		}
+	}
+
+	return nil
+}
+
+// names are sorted by publishing time, random suffix makes them unique between processes.
+func messageName() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix)), nil
+}
+
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	// message must be on disk before it becomes visible to consumers.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,100 @@
+package filebroker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/brokertest"
+	"github.com/stretchr/testify/require"
+)
+
+func newBroker(t *testing.T, dir string) *Broker {
+	t.Helper()
+
+	b := New(dir)
+	b.PollInterval = 10 * time.Millisecond
+	require.NoError(t, b.Connect(context.Background()))
+	t.Cleanup(func() {
+		b.Close()
+	})
+
+	return b
+}
+
+func TestConformance(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) broker.Broker {
+		return newBroker(t, t.TempDir())
+	})
+}
+
+func TestMessagesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	b := newBroker(t, dir)
+	require.NoError(t, b.Publish(ctx, broker.Message{Body: []byte("durable")}))
+	require.NoError(t, b.Close())
+
+	received := consumeOne(t, newBroker(t, dir))
+	require.Equal(t, "durable", string(received.Body))
+
+	// handled message is removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1) // processing directory only
+}
+
+func TestMessageOfDeadConsumerIsRestored(t *testing.T) {
+	dir := t.TempDir()
+	b := newBroker(t, dir)
+	b.VisibilityTimeout = time.Minute
+
+	require.NoError(t, b.Publish(context.Background(), broker.Message{Body: []byte("lost")}))
+
+	// consumer has taken the message and died a long time ago
+	names, err := b.pending()
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+
+	path, ok := b.take(names[0])
+	require.True(t, ok)
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, past, past))
+
+	received := consumeOne(t, b)
+	require.Equal(t, "lost", string(received.Body))
+
+	_, err = os.Stat(filepath.Join(dir, processingDir, names[0]))
+	require.True(t, os.IsNotExist(err))
+}
+
+func consumeOne(t *testing.T, b *Broker) broker.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan broker.Message, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Consume(ctx, func(_ context.Context, msg broker.Message) error {
+			received <- msg
+			cancel()
+			return nil
+		}, 1)
+	}()
+
+	select {
+	case msg := <-received:
+		<-done
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not received")
+		return broker.Message{}
+	}
+}
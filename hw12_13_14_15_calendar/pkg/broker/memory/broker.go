@@ -0,0 +1,132 @@
+package memorybroker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+)
+
+// Failed message is delivered again after a delay that is doubled with every failure up to
+// maxRedeliveryDelay, so a handler that always fails does not spin the worker.
+const (
+	redeliveryDelay    = 100 * time.Millisecond
+	maxRedeliveryDelay = 30 * time.Second
+)
+
+// Broker passes messages through a channel inside the process. Messages are lost on restart,
+// so it is suitable only for tests and for running everything in a single binary.
+type Broker struct {
+	queue chan delivery
+	done  chan struct{}
+	once  sync.Once
+
+	redeliveryDelay    time.Duration
+	maxRedeliveryDelay time.Duration
+}
+
+// delivery counts failed attempts of the message.
+type delivery struct {
+	msg      broker.Message
+	failures int
+}
+
+func New(size int) *Broker {
+	return &Broker{
+		queue:              make(chan delivery, size),
+		done:               make(chan struct{}),
+		redeliveryDelay:    redeliveryDelay,
+		maxRedeliveryDelay: maxRedeliveryDelay,
+	}
+}
+
+func (b *Broker) Connect(_ context.Context) error {
+	return nil
+}
+
+func (b *Broker) Close() error {
+	b.once.Do(func() {
+		close(b.done)
+	})
+
+	return nil
+}
+
+// Publish blocks while the queue is full.
+func (b *Broker) Publish(ctx context.Context, msg broker.Message) error {
+	select {
+	case <-b.done:
+		return broker.ErrClosed
+	default:
+	}
+
+	select {
+	case b.queue <- delivery{msg: msg}:
+		return nil
+	case <-b.done:
+		return broker.ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Broker) Consume(ctx context.Context, handler broker.Handler, threads int) error {
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.worker(ctx, handler)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (b *Broker) worker(ctx context.Context, handler broker.Handler) {
+	for {
+		select {
+		case d := <-b.queue:
+			if err := handler(ctx, d.msg); err != nil && !errors.Is(err, broker.ErrReject) {
+				d.failures++
+				// queue may be full, do not block the worker.
+				go b.requeue(d)
+			}
+		case <-b.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Broker) requeue(d delivery) {
+	timer := time.NewTimer(b.delay(d.failures))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-b.done:
+		return
+	}
+
+	select {
+	case b.queue <- d:
+	case <-b.done:
+	}
+}
+
+func (b *Broker) delay(failures int) time.Duration {
+	delay := b.redeliveryDelay
+	for i := 1; i < failures && delay < b.maxRedeliveryDelay; i++ {
+		delay *= 2
+	}
+	if delay > b.maxRedeliveryDelay {
+		delay = b.maxRedeliveryDelay
+	}
+
+	return delay
+}
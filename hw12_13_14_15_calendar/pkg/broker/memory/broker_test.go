@@ -0,0 +1,72 @@
+package memorybroker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker/brokertest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	brokertest.Run(t, func(t *testing.T) broker.Broker {
+		b := New(100)
+		require.NoError(t, b.Connect(context.Background()))
+		t.Cleanup(func() {
+			b.Close()
+		})
+
+		return b
+	})
+}
+
+func TestPublishAfterClose(t *testing.T) {
+	b := New(1)
+	require.NoError(t, b.Close())
+
+	err := b.Publish(context.Background(), broker.Message{Body: []byte("late")})
+	require.ErrorIs(t, err, broker.ErrClosed)
+}
+
+func TestRedeliveryDelay(t *testing.T) {
+	b := New(1)
+	require.Equal(t, redeliveryDelay, b.delay(1))
+	require.Equal(t, 2*redeliveryDelay, b.delay(2))
+	require.Equal(t, 8*redeliveryDelay, b.delay(4))
+	require.Equal(t, maxRedeliveryDelay, b.delay(100))
+}
+
+func TestFailedMessageIsDelayed(t *testing.T) {
+	b := New(1)
+	b.redeliveryDelay = 20 * time.Millisecond
+	t.Cleanup(func() {
+		b.Close()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var attempts []time.Time
+	go b.Consume(ctx, func(context.Context, broker.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts = append(attempts, time.Now())
+		return errors.New("permanent failure")
+	}, 1)
+	require.NoError(t, b.Publish(ctx, broker.Message{Body: []byte("fail")}))
+
+	// message that always fails is not handled in a hot loop
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.GreaterOrEqual(t, len(attempts), 2)
+	require.LessOrEqual(t, len(attempts), 4)
+	require.GreaterOrEqual(t, attempts[1].Sub(attempts[0]), b.redeliveryDelay)
+}
@@ -0,0 +1,64 @@
+package rmqbroker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/broker"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/rmq"
+	"github.com/streadway/amqp"
+)
+
+// Broker adapts rmq client to broker interfaces.
+type Broker struct {
+	rmq *rmq.Rmq
+}
+
+func New(client *rmq.Rmq) *Broker {
+	return &Broker{rmq: client}
+}
+
+func (b *Broker) Connect(_ context.Context) error {
+	return b.rmq.Connect()
+}
+
+func (b *Broker) Close() error {
+	// closed channel is the expected result of shutdown.
+	if err := b.rmq.Shutdown(); err != nil && !errors.Is(err, rmq.ErrChannelClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (b *Broker) Publish(ctx context.Context, msg broker.Message) error {
+	err := b.rmq.Publish(ctx, amqp.Publishing{
+		ContentType:  msg.ContentType,
+		Body:         msg.Body,
+		DeliveryMode: amqp.Persistent,
+	})
+
+	switch {
+	case errors.Is(err, rmq.ErrUnroutable):
+		return errors.Join(broker.ErrUnroutable, err)
+	case errors.Is(err, rmq.ErrPublishNack), errors.Is(err, rmq.ErrPublishTimeout):
+		return errors.Join(broker.ErrNotConfirmed, err)
+	}
+
+	return err
+}
+
+func (b *Broker) Consume(ctx context.Context, handler broker.Handler, threads int) error {
+	return b.rmq.Handle(ctx, func(ctx context.Context, delivery amqp.Delivery) rmq.Decision {
+		err := handler(ctx, broker.Message{ContentType: delivery.ContentType, Body: delivery.Body})
+
+		switch {
+		case err == nil:
+			return rmq.Ack
+		case errors.Is(err, broker.ErrReject):
+			return rmq.Nack
+		default:
+			return rmq.Requeue
+		}
+	}, threads)
+}
@@ -0,0 +1,216 @@
+// Package cron parses schedules in the classic crontab format:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Every field accepts "*", numbers, ranges "1-5", steps "*/15" or "1-30/5" and lists of them "1,15,30".
+// Day of week is 0-6 starting from Sunday, 7 is Sunday as well. Shortcuts @yearly, @monthly, @weekly,
+// @daily and @hourly are supported too. Like in cron, when both day of month and day of week are
+// restricted, a day matching any of them fits.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid cron schedule")
+
+// search is limited, so impossible schedules like "0 0 30 2 *" do not hang.
+const maxYears = 5
+
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule is a parsed cron expression, every set is a bit mask of allowed values.
+type Schedule struct {
+	spec    string
+	minutes uint64
+	hours   uint64
+	days    uint64
+	months  uint64
+	weekday uint64
+
+	anyDay     bool
+	anyWeekday bool
+}
+
+func Parse(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if full, ok := shortcuts[expr]; ok {
+		expr = full
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("%w %q: expected %d fields, got %d", ErrInvalidSchedule, spec, len(fields), len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidSchedule, spec, err)
+		}
+		sets[i] = set
+	}
+
+	// 7 is another name of Sunday.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		spec:       spec,
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekday:    sets[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time matching the schedule strictly after t, in the location of t.
+// Zero time is returned when there is no such time in the next years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxYears, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.months, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(s.hours, t.Hour()) {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			// hour that does not exist because of DST is normalized back, step over it.
+			if !next.After(t) {
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+
+		if !has(s.minutes, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+	day := has(s.days, t.Day())
+	weekday := has(s.weekday, int(t.Weekday()))
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var set uint64
+
+	for _, item := range strings.Split(value, ",") {
+		rng, stepValue, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepValue)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: wrong step %q", f.name, stepValue)
+			}
+		}
+
+		from, to := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			fromValue, toValue, _ := strings.Cut(rng, "-")
+			var err error
+			if from, err = parseNumber(fromValue, f); err != nil {
+				return 0, err
+			}
+			if to, err = parseNumber(toValue, f); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, fmt.Errorf("%s: wrong range %q", f.name, rng)
+			}
+		default:
+			var err error
+			if from, err = parseNumber(rng, f); err != nil {
+				return 0, err
+			}
+			// "5/10" means from 5 till the end with step 10.
+			if !hasStep {
+				to = from
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func parseNumber(value string, f field) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s: %q is not in %d-%d", f.name, value, f.min, f.max)
+	}
+
+	return n, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
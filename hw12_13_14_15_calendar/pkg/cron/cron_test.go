@@ -0,0 +1,80 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	// Wednesday
+	base := time.Date(2024, 1, 10, 10, 30, 15, 0, moscow)
+
+	tests := []struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"* * * * *", base, time.Date(2024, 1, 10, 10, 31, 0, 0, moscow)},
+		{"*/15 * * * *", base, time.Date(2024, 1, 10, 10, 45, 0, 0, moscow)},
+		{"0 8 * * *", base, time.Date(2024, 1, 11, 8, 0, 0, 0, moscow)},
+		{"@daily", base, time.Date(2024, 1, 11, 0, 0, 0, 0, moscow)},
+		{"@hourly", base, time.Date(2024, 1, 10, 11, 0, 0, 0, moscow)},
+		{"30 10 * * *", time.Date(2024, 1, 10, 10, 30, 0, 0, moscow), time.Date(2024, 1, 11, 10, 30, 0, 0, moscow)},
+		{"0 9 * * 1-5", time.Date(2024, 1, 12, 10, 0, 0, 0, moscow), time.Date(2024, 1, 15, 9, 0, 0, 0, moscow)},
+		{"0 9 * * 7", base, time.Date(2024, 1, 14, 9, 0, 0, 0, moscow)},
+		{"0 0 1,15 * *", base, time.Date(2024, 1, 15, 0, 0, 0, 0, moscow)},
+		{"0 0 29 2 *", base, time.Date(2024, 2, 29, 0, 0, 0, 0, moscow)},
+		{"0 0 31 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, moscow), time.Date(2024, 3, 31, 0, 0, 0, 0, moscow)},
+		{"5/20 8-9 * * *", base, time.Date(2024, 1, 11, 8, 5, 0, 0, moscow)},
+		// day of month or day of week
+		{"0 0 20 * 5", base, time.Date(2024, 1, 12, 0, 0, 0, 0, moscow)},
+		{"0 0 30 2 *", base, time.Time{}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.spec, func(t *testing.T) {
+			schedule, err := Parse(tc.spec)
+			require.NoError(t, err)
+
+			next := schedule.Next(tc.from)
+			require.True(t, tc.expected.Equal(next), "got %s", next)
+		})
+	}
+}
+
+func TestNextSkipsMissingHour(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// 02:00-03:00 does not exist on 31 March 2024 in Berlin.
+	schedule, err := Parse("30 2 * * *")
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	require.True(t, time.Date(2024, 4, 1, 2, 30, 0, 0, berlin).Equal(next), "got %s", next)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@weird",
+	} {
+		_, err := Parse(spec)
+		require.ErrorIs(t, err, ErrInvalidSchedule, spec)
+	}
+}
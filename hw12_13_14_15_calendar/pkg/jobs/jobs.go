@@ -0,0 +1,398 @@
+// Package jobs runs named jobs by cron or interval schedules. Every job has its own timeout,
+// limit of simultaneous runs and random delay before start, so one slow job never delays another.
+// Runner keeps recent runs of every job and exposes counters for expvar.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownJob   = errors.New("unknown job")
+	ErrDuplicateJob = errors.New("job is already added")
+	ErrInvalidJob   = errors.New("invalid job")
+)
+
+const defaultHistorySize = 20
+
+type Func func(ctx context.Context) error
+
+// Schedule returns the next time of the job after t. Zero time means the job never runs again.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Every runs the job with the same interval between starts.
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func (e Every) String() string {
+	return "every " + time.Duration(e).String()
+}
+
+// Never is a schedule of jobs that run only on start or by Trigger.
+type Never struct{}
+
+func (Never) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+func (Never) String() string {
+	return "never"
+}
+
+type located struct {
+	schedule Schedule
+	location *time.Location
+}
+
+// In evaluates schedule in the location, e.g. cron expression in the time zone of users.
+func In(schedule Schedule, location *time.Location) Schedule {
+	return located{schedule: schedule, location: location}
+}
+
+func (l located) Next(t time.Time) time.Time {
+	return l.schedule.Next(t.In(l.location))
+}
+
+func (l located) String() string {
+	return fmt.Sprintf("%v %s", l.schedule, l.location)
+}
+
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      Func
+	// Timeout cancels context of the run, 0 means no timeout.
+	Timeout time.Duration
+	// Concurrency limits simultaneous runs, scheduled runs over the limit are skipped. Default is 1.
+	Concurrency int
+	// Jitter is the max random delay before every scheduled run, so replicas do not start at once.
+	Jitter time.Duration
+	// RunOnStart runs the job once when runner starts.
+	RunOnStart bool
+}
+
+// Run is the result of one run of the job.
+type Run struct {
+	Job      string        `json:"job"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	Skipped  bool          `json:"skipped,omitempty"`
+}
+
+// Stats describe the job for monitoring.
+type Stats struct {
+	Name      string    `json:"name"`
+	Schedule  string    `json:"schedule"`
+	Runs      int64     `json:"runs"`
+	Failures  int64     `json:"failures"`
+	Skipped   int64     `json:"skipped"`
+	Running   int       `json:"running"`
+	NextRun   time.Time `json:"next_run"`             //nolint:tagliatelle
+	LastError string    `json:"last_error,omitempty"` //nolint:tagliatelle
+	History   []Run     `json:"history"`
+}
+
+type state struct {
+	job Job
+	// wake makes the loop of the job calculate the next run by the updated schedule.
+	wake chan struct{}
+
+	running  int
+	pending  bool
+	runs     int64
+	failures int64
+	skipped  int64
+	nextRun  time.Time
+	history  []Run
+	lastErr  string
+}
+
+type Runner struct {
+	historySize int
+	report      func(run Run)
+
+	mu     sync.Mutex
+	jobs   map[string]*state
+	order  []string
+	ctx    context.Context
+	wg     sync.WaitGroup
+	random *rand.Rand
+}
+
+// New creates runner that keeps historySize last runs of every job. Report, when set,
+// is called after every run, including skipped ones.
+func New(historySize int, report func(run Run)) *Runner {
+	if historySize <= 0 {
+		historySize = defaultHistorySize
+	}
+
+	return &Runner{
+		historySize: historySize,
+		report:      report,
+		jobs:        make(map[string]*state),
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+}
+
+func (r *Runner) Add(job Job) error {
+	job, err := validate(job)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.jobs[job.Name]; found {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+	}
+
+	r.jobs[job.Name] = &state{job: job, wake: make(chan struct{}, 1)}
+	r.order = append(r.order, job.Name)
+
+	return nil
+}
+
+// Update replaces settings of the added job with the same name, e.g. on reload of config.
+// Stats and history are kept, runs in progress are finished with the old settings,
+// and the next run is calculated by the new schedule at once.
+func (r *Runner) Update(job Job) error {
+	job, err := validate(job)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, found := r.jobs[job.Name]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, job.Name)
+	}
+
+	st.job = job
+	select {
+	case st.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func validate(job Job) (Job, error) {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return job, fmt.Errorf("%w %q: name, schedule and function are required", ErrInvalidJob, job.Name)
+	}
+	if job.Concurrency <= 0 {
+		job.Concurrency = 1
+	}
+
+	return job, nil
+}
+
+// Run starts all jobs and blocks until ctx is done and all started runs are finished.
+func (r *Runner) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	for _, name := range r.order {
+		st := r.jobs[name]
+		if st.job.RunOnStart || st.pending {
+			st.pending = false
+			r.start(st)
+		}
+
+		r.wg.Add(1)
+		go r.loop(st)
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	r.wg.Wait()
+}
+
+// Trigger runs the job now, out of its schedule. When the job is at its concurrency limit,
+// one more run is done right after the current one, so triggers are never lost.
+func (r *Runner) Trigger(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, found := r.jobs[name]
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+
+	if r.ctx == nil || st.running >= st.job.Concurrency {
+		st.pending = true
+		return nil
+	}
+
+	r.start(st)
+	return nil
+}
+
+// History returns last runs of the job, the oldest first.
+func (r *Runner) History(name string) []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, found := r.jobs[name]
+	if !found {
+		return nil
+	}
+
+	return append([]Run(nil), st.history...)
+}
+
+func (r *Runner) Stats() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]Stats, 0, len(r.order))
+	for _, name := range r.order {
+		st := r.jobs[name]
+		res = append(res, Stats{
+			Name:      name,
+			Schedule:  fmt.Sprint(st.job.Schedule),
+			Runs:      st.runs,
+			Failures:  st.failures,
+			Skipped:   st.skipped,
+			Running:   st.running,
+			NextRun:   st.nextRun,
+			LastError: st.lastErr,
+			History:   append([]Run(nil), st.history...),
+		})
+	}
+
+	return res
+}
+
+// Metrics returns stats of jobs as expvar variable, e.g. expvar.Publish("jobs", runner.Metrics()).
+func (r *Runner) Metrics() expvar.Var {
+	return expvar.Func(func() any {
+		return r.Stats()
+	})
+}
+
+func (r *Runner) loop(st *state) {
+	defer r.wg.Done()
+
+	for {
+		r.mu.Lock()
+		next := st.job.Schedule.Next(time.Now())
+		if !next.IsZero() && st.job.Jitter > 0 {
+			next = next.Add(time.Duration(r.random.Int63n(int64(st.job.Jitter))))
+		}
+		st.nextRun = next
+		r.mu.Unlock()
+
+		// job that never runs by schedule still waits for update of its schedule.
+		var timer *time.Timer
+		var due <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			due = timer.C
+		}
+
+		select {
+		case <-due:
+			var skipped *Run
+			r.mu.Lock()
+			if st.running >= st.job.Concurrency {
+				skipped = r.skip(st)
+			} else {
+				r.start(st)
+			}
+			r.mu.Unlock()
+
+			if skipped != nil && r.report != nil {
+				r.report(*skipped)
+			}
+		case <-st.wake:
+			stop(timer)
+		case <-r.ctx.Done():
+			stop(timer)
+			return
+		}
+	}
+}
+
+func stop(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// start runs the job in background. Should be called under lock.
+func (r *Runner) start(st *state) {
+	st.running++
+	r.wg.Add(1)
+
+	// the job may be updated while it runs.
+	job := st.job
+
+	go func() {
+		defer r.wg.Done()
+
+		ctx := r.ctx
+		if job.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+			defer cancel()
+		}
+
+		run := Run{Job: job.Name, Started: time.Now()}
+		err := job.Run(ctx)
+		run.Duration = time.Since(run.Started)
+		if err != nil {
+			run.Error = err.Error()
+		}
+
+		r.mu.Lock()
+		st.running--
+		st.runs++
+		if err != nil {
+			st.failures++
+			st.lastErr = run.Error
+		}
+		r.remember(st, run)
+
+		if st.pending && r.ctx.Err() == nil {
+			st.pending = false
+			r.start(st)
+		}
+		r.mu.Unlock()
+
+		if r.report != nil {
+			r.report(run)
+		}
+	}()
+}
+
+// skip records scheduled run that was not started because of concurrency limit. Should be called under lock.
+func (r *Runner) skip(st *state) *Run {
+	st.skipped++
+	run := Run{Job: st.job.Name, Started: time.Now(), Skipped: true}
+	r.remember(st, run)
+
+	return &run
+}
+
+// remember adds the run to the history of the job. Should be called under lock.
+func (r *Runner) remember(st *state, run Run) {
+	st.history = append(st.history, run)
+	if len(st.history) > r.historySize {
+		st.history = st.history[len(st.history)-r.historySize:]
+	}
+}
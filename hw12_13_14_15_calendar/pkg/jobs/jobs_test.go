@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func start(t *testing.T, r *Runner) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestAdd(t *testing.T) {
+	r := New(10, nil)
+	run := func(context.Context) error { return nil }
+
+	require.NoError(t, r.Add(Job{Name: "job", Schedule: Every(time.Minute), Run: run}))
+	require.ErrorIs(t, r.Add(Job{Name: "job", Schedule: Every(time.Minute), Run: run}), ErrDuplicateJob)
+	require.ErrorIs(t, r.Add(Job{Name: "", Schedule: Every(time.Minute), Run: run}), ErrInvalidJob)
+	require.ErrorIs(t, r.Add(Job{Name: "other", Run: run}), ErrInvalidJob)
+	require.ErrorIs(t, r.Trigger("unknown"), ErrUnknownJob)
+}
+
+func TestIntervalAndHistory(t *testing.T) {
+	r := New(3, nil)
+
+	var calls atomic.Int32
+	require.NoError(t, r.Add(Job{
+		Name:     "tick",
+		Schedule: Every(10 * time.Millisecond),
+		Run: func(context.Context) error {
+			if calls.Add(1)%2 == 0 {
+				return errors.New("even run")
+			}
+			return nil
+		},
+	}))
+	start(t, r)
+
+	require.Eventually(t, func() bool { return calls.Load() >= 5 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return len(r.History("tick")) == 3 }, time.Second, 5*time.Millisecond)
+
+	stats := r.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, "every 10ms", stats[0].Schedule)
+	require.GreaterOrEqual(t, stats[0].Failures, int64(2))
+	require.Equal(t, "even run", stats[0].LastError)
+	require.False(t, stats[0].NextRun.IsZero())
+
+	// metrics are valid JSON for expvar
+	var decoded []Stats
+	require.NoError(t, json.Unmarshal([]byte(r.Metrics().String()), &decoded))
+	require.Equal(t, "tick", decoded[0].Name)
+}
+
+func TestTimeout(t *testing.T) {
+	r := New(10, nil)
+
+	reported := make(chan Run, 1)
+	r.report = func(run Run) { reported <- run }
+
+	require.NoError(t, r.Add(Job{
+		Name:       "slow",
+		Schedule:   Never{},
+		Timeout:    20 * time.Millisecond,
+		RunOnStart: true,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}))
+	start(t, r)
+
+	select {
+	case run := <-reported:
+		require.Equal(t, context.DeadlineExceeded.Error(), run.Error)
+		require.GreaterOrEqual(t, run.Duration, 20*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("job is not stopped by timeout")
+	}
+}
+
+func TestConcurrencyLimitSkipsScheduledRuns(t *testing.T) {
+	r := New(100, nil)
+
+	var running, maxRunning atomic.Int32
+	release := make(chan struct{})
+	require.NoError(t, r.Add(Job{
+		Name:        "limited",
+		Schedule:    Every(5 * time.Millisecond),
+		Concurrency: 2,
+		Run: func(ctx context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				current := maxRunning.Load()
+				if n <= current || maxRunning.CompareAndSwap(current, n) {
+					break
+				}
+			}
+
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+	}))
+	start(t, r)
+
+	require.Eventually(t, func() bool { return r.Stats()[0].Skipped >= 3 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(2), maxRunning.Load())
+	close(release)
+}
+
+func TestTriggerIsNotLost(t *testing.T) {
+	r := New(10, nil)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	require.NoError(t, r.Add(Job{
+		Name:     "triggered",
+		Schedule: Never{},
+		Run: func(context.Context) error {
+			if calls.Add(1) == 1 {
+				<-release
+			}
+			return nil
+		},
+	}))
+
+	// trigger before start is remembered as well
+	require.NoError(t, r.Trigger("triggered"))
+	start(t, r)
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// several triggers during the run are coalesced into one more run
+	require.NoError(t, r.Trigger("triggered"))
+	require.NoError(t, r.Trigger("triggered"))
+	close(release)
+
+	require.Eventually(t, func() bool { return len(r.History("triggered")) == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestUpdate(t *testing.T) {
+	r := New(10, nil)
+
+	var calls atomic.Int32
+	run := func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}
+	require.NoError(t, r.Add(Job{Name: "updated", Schedule: Never{}, Run: run}))
+	require.ErrorIs(t, r.Update(Job{Name: "unknown", Schedule: Never{}, Run: run}), ErrUnknownJob)
+	require.ErrorIs(t, r.Update(Job{Name: "updated", Run: run}), ErrInvalidJob)
+	start(t, r)
+
+	// job that never ran by schedule starts running by the new one
+	require.NoError(t, r.Update(Job{Name: "updated", Schedule: Every(5 * time.Millisecond), Run: run}))
+	require.Eventually(t, func() bool { return calls.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, "every 5ms", r.Stats()[0].Schedule)
+
+	// and stops when it is disabled again
+	require.NoError(t, r.Update(Job{Name: "updated", Schedule: Never{}, Run: run}))
+	require.Eventually(t, func() bool { return r.Stats()[0].NextRun.IsZero() }, time.Second, 5*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	stopped := calls.Load()
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, stopped, calls.Load())
+}
+
+func TestJitter(t *testing.T) {
+	r := New(10, nil)
+	require.NoError(t, r.Add(Job{
+		Name:     "jitter",
+		Schedule: Every(time.Hour),
+		Jitter:   time.Minute,
+		Run:      func(context.Context) error { return nil },
+	}))
+
+	before := time.Now()
+	start(t, r)
+
+	require.Eventually(t, func() bool { return !r.Stats()[0].NextRun.IsZero() }, time.Second, 5*time.Millisecond)
+	next := r.Stats()[0].NextRun
+	require.False(t, next.Before(before.Add(time.Hour)))
+	require.True(t, next.Before(time.Now().Add(time.Hour+time.Minute)))
+}
+
+func TestIn(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	// next midnight in Moscow, whatever location of the argument is
+	schedule := In(daily{}, moscow)
+	next := schedule.Next(time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC))
+	require.True(t, time.Date(2024, 1, 12, 0, 0, 0, 0, moscow).Equal(next), "got %s", next)
+	require.Equal(t, "daily Europe/Moscow", fmt.Sprint(schedule))
+}
+
+type daily struct{}
+
+func (daily) Next(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+func (daily) String() string {
+	return "daily"
+}
@@ -0,0 +1,200 @@
+// Package lifecycle runs components of a service as a group and stops them together:
+// on SIGINT/SIGTERM or when any component stops, all components are asked to drain
+// within the shutdown timeout, then resources are closed in reverse order of adding.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrTimeout     = errors.New("shutdown timeout is exceeded")
+	ErrInterrupted = errors.New("shutdown is interrupted by signal")
+)
+
+// Component is a long running part of the service, e.g. server or consumer.
+type Component struct {
+	Name string
+	// Run blocks until the component is stopped. Its ctx is cancelled on shutdown,
+	// so components without Stop should return when ctx is done.
+	Run func(ctx context.Context) error
+	// Stop drains the component, e.g. waits for in-flight requests. ctx is done at the deadline
+	// of shutdown, after that the component should stop at once. Optional.
+	Stop func(ctx context.Context) error
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Group is not reusable, Run is called once.
+type Group struct {
+	timeout    time.Duration
+	report     func(name string, err error)
+	signals    []os.Signal
+	components []Component
+	closers    []closer
+}
+
+// New creates group with the time given to components to drain. report is called when component
+// is stopped or resource is closed, err is nil on success.
+func New(timeout time.Duration, report func(name string, err error)) *Group {
+	return &Group{
+		timeout: timeout,
+		report:  report,
+		signals: []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+}
+
+func (g *Group) Add(c Component) {
+	g.components = append(g.components, c)
+}
+
+// AddCloser adds resource that is closed after all components are stopped. Resources are closed
+// in reverse order, so storage that is added first is closed last.
+func (g *Group) AddCloser(name string, close func() error) {
+	g.closers = append(g.closers, closer{name: name, close: close})
+}
+
+type result struct {
+	name string
+	err  error
+}
+
+// Run starts components and blocks until shutdown is finished. It returns error of the component
+// that failed and errors of shutdown, nil means the service is stopped by signal or ctx without problems.
+func (g *Group) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, g.signals...)
+	defer signal.Stop(signals)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(g.components))
+	for _, c := range g.components {
+		go func(c Component) {
+			results <- result{name: c.Name, err: c.Run(runCtx)}
+		}(c)
+	}
+
+	// names of running components for the timeout error.
+	names := make(map[string]bool, len(g.components))
+	for _, c := range g.components {
+		names[c.Name] = true
+	}
+
+	var errs []error
+	running := len(g.components)
+
+	// wait for the reason of shutdown
+	select {
+	case <-signals:
+	case <-ctx.Done():
+	case res := <-results:
+		running--
+		delete(names, res.name)
+		g.report(res.name, res.err)
+		if res.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.name, res.err))
+		}
+	}
+
+	deadline, stop := context.WithTimeout(context.Background(), g.timeout)
+	defer stop()
+
+	cancel()
+	stopped := make(chan error, len(g.components))
+	stopping := 0
+	for _, c := range g.components {
+		if c.Stop == nil {
+			continue
+		}
+		stopping++
+		go func(c Component) {
+			if err := c.Stop(deadline); err != nil {
+				stopped <- fmt.Errorf("stop %s: %w", c.Name, err)
+				return
+			}
+			stopped <- nil
+		}(c)
+	}
+
+	for running > 0 || stopping > 0 {
+		select {
+		case res := <-results:
+			running--
+			delete(names, res.name)
+			g.report(res.name, res.err)
+			if res.err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", res.name, res.err))
+			}
+		case err := <-stopped:
+			stopping--
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-deadline.Done():
+			errs = append(errs, g.abort(ErrTimeout, names, running))
+			running, stopping = 0, 0
+		case <-signals:
+			// the second signal does not wait for components.
+			errs = append(errs, g.abort(ErrInterrupted, names, running))
+			running, stopping = 0, 0
+		}
+	}
+
+	if err := g.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes resources in reverse order. It is called by Run, and by the service
+// when it fails to start after some resources are opened.
+func (g *Group) Close() error {
+	var errs []error
+	for i := len(g.closers) - 1; i >= 0; i-- {
+		err := g.closers[i].close()
+		g.report(g.closers[i].name, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", g.closers[i].name, err))
+		}
+	}
+	g.closers = nil
+
+	return errors.Join(errs...)
+}
+
+// abort reports components that are not stopped in time, they are left running until exit.
+func (g *Group) abort(reason error, names map[string]bool, running int) error {
+	if running == 0 {
+		return reason
+	}
+
+	list := make([]string, 0, len(names))
+	for _, c := range g.components {
+		if names[c.Name] {
+			list = append(list, c.Name)
+		}
+	}
+
+	return fmt.Errorf("%w, still running: %s", reason, strings.Join(list, ", "))
+}
+
+// Wait returns Run for component that only waits for shutdown, e.g. goroutine that is stopped by ctx.
+func Wait(run func(ctx context.Context)) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		run(ctx)
+		return nil
+	}
+}
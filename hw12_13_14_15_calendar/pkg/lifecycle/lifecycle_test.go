@@ -0,0 +1,138 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// journal records stops and closes in their order.
+type journal struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (j *journal) add(event string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.events = append(j.events, event)
+}
+
+func (j *journal) list() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]string(nil), j.events...)
+}
+
+// server imitates server that drains in-flight request on Stop.
+func server(j *journal, name string, drain time.Duration) Component {
+	stopped := make(chan struct{})
+	return Component{
+		Name: name,
+		Run: func(context.Context) error {
+			<-stopped
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			defer close(stopped)
+
+			select {
+			case <-time.After(drain):
+				j.add(name + " drained")
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+func newGroup(j *journal, timeout time.Duration) *Group {
+	return New(timeout, func(name string, err error) {
+		if err != nil {
+			j.add(name + " failed")
+			return
+		}
+		j.add(name + " stopped")
+	})
+}
+
+func TestShutdownByContext(t *testing.T) {
+	j := &journal{}
+	g := newGroup(j, time.Second)
+	g.AddCloser("storage", func() error {
+		j.add("storage closed")
+		return nil
+	})
+	g.AddCloser("broker", func() error {
+		j.add("broker closed")
+		return nil
+	})
+	g.Add(server(j, "http", 20*time.Millisecond))
+	g.Add(Component{Name: "watcher", Run: Wait(func(ctx context.Context) { <-ctx.Done() })})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	require.NoError(t, g.Run(ctx))
+
+	events := j.list()
+	require.ElementsMatch(t, []string{"http drained", "http stopped", "watcher stopped"}, events[:3])
+	// resources are closed after components, in reverse order
+	require.Equal(t, []string{"broker closed", "broker stopped", "storage closed", "storage stopped"}, events[3:])
+}
+
+func TestShutdownBySignal(t *testing.T) {
+	j := &journal{}
+	g := newGroup(j, time.Second)
+	g.Add(server(j, "grpc", 0))
+
+	time.AfterFunc(20*time.Millisecond, func() {
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	})
+
+	require.NoError(t, g.Run(context.Background()))
+	require.Contains(t, j.list(), "grpc drained")
+}
+
+func TestFailedComponentStopsGroup(t *testing.T) {
+	j := &journal{}
+	g := newGroup(j, time.Second)
+	errBind := errors.New("address already in use")
+	g.Add(Component{Name: "http", Run: func(context.Context) error { return errBind }})
+	g.Add(server(j, "grpc", 0))
+
+	err := g.Run(context.Background())
+	require.ErrorIs(t, err, errBind)
+	require.ErrorContains(t, err, "http")
+	require.Contains(t, j.list(), "grpc drained")
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	j := &journal{}
+	g := newGroup(j, 30*time.Millisecond)
+	g.AddCloser("storage", func() error {
+		j.add("storage closed")
+		return nil
+	})
+	g.Add(server(j, "http", time.Hour))
+	// component that ignores ctx is left running
+	g.Add(Component{Name: "stuck", Run: func(context.Context) error {
+		select {}
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := g.Run(ctx)
+	require.ErrorIs(t, err, ErrTimeout)
+	require.ErrorContains(t, err, "stuck")
+	require.Contains(t, j.list(), "storage closed")
+}
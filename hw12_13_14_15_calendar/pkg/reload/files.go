@@ -0,0 +1,89 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Files returns fingerprint of files: it changes when size or modification time of any file is changed.
+// Empty paths are skipped, so optional files may be passed as is.
+func Files(paths ...string) (string, error) {
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano()))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Poller remembers fingerprint of files and tells when it is changed.
+type Poller struct {
+	scan func() (string, error)
+	last string
+}
+
+// NewPoller starts with the fingerprint of files that are already read, scan returns the current one.
+func NewPoller(last string, scan func() (string, error)) *Poller {
+	return &Poller{scan: scan, last: last}
+}
+
+// Changed scans files and remembers the new fingerprint. It is remembered even if the caller fails
+// to read the files, so the same broken version is not reported on every check.
+func (p *Poller) Changed() (bool, error) {
+	current, err := p.scan()
+	if err != nil {
+		return false, err
+	}
+	if current == p.last {
+		return false, nil
+	}
+
+	p.last = current
+
+	return true, nil
+}
+
+// Reset remembers the current fingerprint, e.g. when files are read for another reason.
+func (p *Poller) Reset() {
+	if current, err := p.scan(); err == nil {
+		p.last = current
+	}
+}
+
+// Poll calls onChange when files are changed, they are checked every interval until ctx is done.
+// Errors of scanning and of onChange are reported with onError.
+func (p *Poller) Poll(ctx context.Context, interval time.Duration, onChange func() error, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := p.Changed()
+			if err != nil {
+				onError(err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			if err := onChange(); err != nil {
+				onError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "cert.pem")
+	second := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(first, []byte("cert"), 0o600))
+	require.NoError(t, os.WriteFile(second, []byte("key"), 0o600))
+
+	before, err := Files(first, "", second)
+	require.NoError(t, err)
+
+	// file system may keep modification time with low precision, so size is changed as well
+	require.NoError(t, os.WriteFile(second, []byte("new key"), 0o600))
+	after, err := Files(first, "", second)
+	require.NoError(t, err)
+	require.NotEqual(t, before, after)
+
+	_, err = Files(filepath.Join(dir, "missing.pem"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+
+	scan := func() (string, error) { return Files(path) }
+	last, err := scan()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	errs := make(chan error, 10)
+	done := make(chan struct{})
+	errBroken := errors.New("broken")
+	go func() {
+		NewPoller(last, scan).Poll(ctx, 5*time.Millisecond, func() error {
+			changes <- struct{}{}
+			return errBroken
+		}, func(err error) { errs <- err })
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change of the file is not noticed")
+	}
+	require.ErrorIs(t, <-errs, errBroken)
+
+	// broken version is reported once
+	select {
+	case <-changes:
+		t.Fatal("the same version is reported again")
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
@@ -0,0 +1,132 @@
+// Package reload tells services when their configuration file should be read again:
+// on SIGHUP or when the file is changed. It does not parse the file itself, so it works
+// with any config loader.
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type Watcher struct {
+	interval time.Duration
+	signals  chan os.Signal
+	poller   *Poller
+}
+
+// New subscribes to SIGHUP at once, so the signal is not lost and does not stop the process
+// even if it comes before Run. The file is checked for changes every interval, 0 disables it.
+func New(path string, interval time.Duration) *Watcher {
+	scan := func() (string, error) { return Files(path) }
+	last, _ := scan()
+
+	w := &Watcher{
+		interval: interval,
+		signals:  make(chan os.Signal, 1),
+		poller:   NewPoller(last, scan),
+	}
+	signal.Notify(w.signals, syscall.SIGHUP)
+
+	return w
+}
+
+// Run calls onChange on SIGHUP and when the file is changed, until ctx is done.
+// Errors of checking the file are reported with onError, the file is checked again on the next tick.
+func (w *Watcher) Run(ctx context.Context, onChange func(), onError func(err error)) {
+	defer signal.Stop(w.signals)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.signals:
+			// the file is read anyway, so its current version should not be reported once more.
+			w.poller.Reset()
+			onChange()
+		case <-tick:
+			changed, err := w.poller.Changed()
+			if err != nil {
+				onError(err)
+				continue
+			}
+			if changed {
+				onChange()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Changed returns keys of fields that differ in two configs of the same struct type, e.g. "logger.level".
+// Keys are built from mapstructure tags, so they match names in the config file.
+func Changed(old, new any) []string { //nolint:predeclared
+	return changed("", reflect.ValueOf(old), reflect.ValueOf(new))
+}
+
+func changed(prefix string, old, new reflect.Value) []string { //nolint:predeclared
+	for old.Kind() == reflect.Pointer {
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				return []string{prefix}
+			}
+			return nil
+		}
+		old, new = old.Elem(), new.Elem()
+	}
+
+	// structs without exported fields like time.Time are compared as values.
+	if old.Kind() != reflect.Struct || !hasExportedFields(old.Type()) {
+		if reflect.DeepEqual(old.Interface(), new.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var keys []string
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")
+		name := tag[0]
+		switch {
+		case len(tag) > 1 && tag[1] == "squash":
+			// fields of embedded section are in the parent section.
+			name = prefix
+		case name == "":
+			name = strings.ToLower(field.Name)
+			fallthrough
+		default:
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+		}
+
+		keys = append(keys, changed(name, old.Field(i), new.Field(i))...)
+	}
+
+	return keys
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func run(t *testing.T, w *Watcher) <-chan struct{} {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, func() { changes <- struct{}{} }, func(err error) { t.Error(err) })
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return changes
+}
+
+func TestFileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("level = \"info\"\n"), 0o600))
+
+	changes := run(t, New(path, 5*time.Millisecond))
+
+	// nothing is changed yet
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	require.NoError(t, os.WriteFile(path, []byte("level = \"debug\"\n"), 0o600))
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change of the file is not noticed")
+	}
+}
+
+func TestSighup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	// signal that comes before Run is not lost and does not kill the test
+	w := New(path, 0)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	changes := run(t, w)
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("SIGHUP is not noticed")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	type section struct {
+		Level    string        `mapstructure:"level"`
+		Interval time.Duration `mapstructure:"reloadInterval"`
+	}
+	type server struct {
+		Port    int     `mapstructure:"port"`
+		Section section `mapstructure:",squash"`
+	}
+	type config struct {
+		Logger section `mapstructure:"logger"`
+		Other  section
+		Server server    `mapstructure:"server"`
+		Since  time.Time `mapstructure:"since"`
+		secret string
+	}
+
+	old := &config{Logger: section{Level: "info"}, secret: "a"}
+	require.Empty(t, Changed(old, &config{Logger: section{Level: "info"}, secret: "b"}))
+
+	changed := &config{
+		Logger: section{Level: "debug", Interval: time.Second},
+		Other:  section{Level: "error"},
+		Server: server{Section: section{Level: "info"}},
+		Since:  time.Now(),
+	}
+	require.Equal(t,
+		[]string{"logger.level", "logger.reloadInterval", "other.level", "server.level", "since"},
+		Changed(old, changed),
+	)
+}
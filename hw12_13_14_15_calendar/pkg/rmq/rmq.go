@@ -4,27 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
+	"github.com/google/uuid"
 	"github.com/streadway/amqp"
 )
 
+const (
+	defaultPublishTimeout = 5 * time.Second
+	confirmsBufferSize    = 64
+)
+
 var (
-	ErrChannelClosed = errors.New("channel Closed")
-	ErrChannel       = errors.New("channel error")
-	ErrWithQueue     = errors.New("errors with Queue")
-	ErrGeneralError  = errors.New("there is a some problem with RMQ server")
-	ErrReconnection  = errors.New("there is a some problem with reconnect to RMQ server")
-	ErrClose         = errors.New("AMQP connection close error")
-	ErrConnections   = errors.New("can't connect to the RMQ server")
-	ErrPublish       = errors.New("AMQP publish error")
+	ErrChannelClosed  = errors.New("channel Closed")
+	ErrChannel        = errors.New("channel error")
+	ErrWithQueue      = errors.New("errors with Queue")
+	ErrGeneralError   = errors.New("there is a some problem with RMQ server")
+	ErrReconnection   = errors.New("there is a some problem with reconnect to RMQ server")
+	ErrClose          = errors.New("AMQP connection close error")
+	ErrConnections    = errors.New("can't connect to the RMQ server")
+	ErrPublish        = errors.New("AMQP publish error")
+	ErrNotConnected   = errors.New("not connected to the RMQ server")
+	ErrPublishNack    = errors.New("message is rejected by the RMQ server")
+	ErrPublishTimeout = errors.New("message is not confirmed by the RMQ server in time")
+	ErrUnroutable     = errors.New("message is not routed to any queue")
 )
 
 // Consumer ...
 type Rmq struct {
+	// mu guards connection, channel and publisher confirms state.
+	mu          sync.Mutex
 	conn        *amqp.Connection
 	channel     *amqp.Channel
+	confirms    chan amqp.Confirmation
+	returns     chan amqp.Return
+	publishTag  uint64
 	done        chan error
 	consumerTag string
 
@@ -39,6 +55,11 @@ type Rmq struct {
 	InitialInterval time.Duration
 	Multiplier      float64
 	MaxInterval     time.Duration
+
+	// PublishTimeout limits waiting for confirmation of published message.
+	PublishTimeout time.Duration
+	// Mandatory makes the server return messages that are not routed to any queue.
+	Mandatory bool
 }
 
 func NewRmq(
@@ -57,14 +78,30 @@ func NewRmq(
 		exchangeType: exchangeType,
 		queue:        queue,
 		bindingKey:   bindingKey,
-		done:         make(chan error),
+		done:         make(chan error, 1),
 		maxInterval:  maxInterval,
 	}
 }
 
-type Worker func(context.Context, <-chan amqp.Delivery)
+// Decision tells what to do with the delivery after it has been handled.
+type Decision int
+
+const (
+	// Ack removes handled message from the queue.
+	Ack Decision = iota
+	// Requeue returns message to the queue for another attempt.
+	Requeue
+	// Nack rejects message that can never be handled, it is dropped or dead-lettered by the server.
+	Nack
+)
+
+// Worker handles a single delivery. Acknowledgement is sent by Handle according to returned decision.
+type Worker func(ctx context.Context, delivery amqp.Delivery) Decision
 
 func (r *Rmq) Connect() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 	if err = r.connect(); err != nil {
 		return errors.Join(ErrGeneralError, err)
@@ -78,30 +115,95 @@ func (r *Rmq) Connect() error {
 	return nil
 }
 
+// Handle runs pool of threads workers and blocks until ctx is done. When connection is lost,
+// workers finish current deliveries, connection is restored and consuming is started again.
+// On shutdown consuming is cancelled, deliveries that are already received but not handled
+// are returned to the queue, and Handle waits for the workers.
 func (r *Rmq) Handle(ctx context.Context, fn Worker, threads int) error {
-	msgs, err := r.consume()
-	if err != nil {
-		return errors.Join(ErrGeneralError, err)
-	}
-
 	for {
-		for i := 0; i < threads; i++ {
-			go fn(ctx, msgs)
+		msgs, err := r.consume()
+		if err != nil {
+			return errors.Join(ErrGeneralError, err)
 		}
 
+		done := r.runWorkers(ctx, msgs, fn, threads)
+
 		select {
 		case <-ctx.Done():
+			r.cancelConsume()
+			<-done
 			return nil
-		case <-r.done:
-			err = r.reConnect(ctx)
-			if err != nil {
-				return errors.Join(ErrReconnection, err)
-			}
+		case <-done:
+			// deliveries channel is closed: connection or channel is lost.
 		}
+
+		if err := r.reConnect(ctx); err != nil {
+			return errors.Join(ErrReconnection, err)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// runWorkers returns channel that is closed when all workers are stopped.
+func (r *Rmq) runWorkers(ctx context.Context, msgs <-chan amqp.Delivery, fn Worker, threads int) <-chan struct{} {
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range msgs {
+				if ctx.Err() != nil {
+					// shutting down: leave the message for another consumer.
+					delivery.Nack(false, true)
+					continue
+				}
+
+				acknowledge(delivery, fn(ctx, delivery))
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	return done
+}
+
+func acknowledge(delivery amqp.Delivery, decision Decision) {
+	switch decision {
+	case Ack:
+		delivery.Ack(false)
+	case Requeue:
+		delivery.Nack(false, true)
+	case Nack:
+		delivery.Nack(false, false)
+	}
+}
+
+// cancelConsume stops deliveries from the server, deliveries channel is closed after that.
+func (r *Rmq) cancelConsume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channel != nil {
+		r.channel.Cancel(r.consumerTag, false)
 	}
 }
 
 func (r *Rmq) Shutdown() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil || r.conn.IsClosed() {
+		return nil
+	}
+
 	// will close() the deliveries channel
 	if err := r.channel.Cancel(r.consumerTag, true); err != nil {
 		return errors.Join(ErrGeneralError, err)
@@ -114,17 +216,102 @@ func (r *Rmq) Shutdown() error {
 	return <-r.done
 }
 
-func (r *Rmq) Publish(msg amqp.Publishing) error {
-	if r.channel == nil {
-		return nil
+// Publish sends message and waits until the server confirms it. Lost connection is restored first.
+// Any error means that message may be not delivered, so it has to be published again.
+func (r *Rmq) Publish(ctx context.Context, msg amqp.Publishing) error {
+	if err := r.ensureConnected(ctx); err != nil {
+		return err
 	}
-	if err := r.channel.Publish(r.exchangeName, r.queue, false, false, msg); err != nil {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn.IsClosed() {
+		return ErrNotConnected
+	}
+
+	// returned message is recognized by its ID.
+	if msg.MessageId == "" {
+		msg.MessageId = uuid.NewString()
+	}
+
+	if err := r.channel.Publish(r.exchangeName, r.queue, r.Mandatory, false, msg); err != nil {
 		return errors.Join(ErrPublish, err)
 	}
+	r.publishTag++
+
+	return r.waitConfirm(ctx, r.publishTag, msg.MessageId)
+}
+
+func (r *Rmq) ensureConnected(ctx context.Context) error {
+	r.mu.Lock()
+	conn := r.conn
+	r.mu.Unlock()
+
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	if !conn.IsClosed() {
+		return nil
+	}
+
+	if err := r.reConnect(ctx); err != nil {
+		return errors.Join(ErrReconnection, err)
+	}
 
 	return nil
 }
 
+// waitConfirm should be called under lock.
+func (r *Rmq) waitConfirm(ctx context.Context, tag uint64, messageID string) error {
+	timeout := r.PublishTimeout
+	if timeout <= 0 {
+		timeout = defaultPublishTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case confirm, ok := <-r.confirms:
+			if !ok {
+				return errors.Join(ErrPublish, ErrChannelClosed)
+			}
+
+			// late confirmation of the message that has timed out before.
+			if confirm.DeliveryTag < tag {
+				continue
+			}
+
+			if !confirm.Ack {
+				return ErrPublishNack
+			}
+
+			// server sends basic.return before basic.ack, so it is already here.
+			return r.checkReturned(messageID)
+		case <-timer.C:
+			return ErrPublishTimeout
+		case <-ctx.Done():
+			return errors.Join(ErrPublish, ctx.Err())
+		}
+	}
+}
+
+func (r *Rmq) checkReturned(messageID string) error {
+	for {
+		select {
+		case ret := <-r.returns:
+			if ret.MessageId == messageID {
+				return fmt.Errorf("%w: %s", ErrUnroutable, ret.ReplyText)
+			}
+		default:
+			return nil
+		}
+	}
+}
+
 func (r *Rmq) connect() error {
 	var err error
 
@@ -138,10 +325,22 @@ func (r *Rmq) connect() error {
 		return errors.Join(ErrChannel, err)
 	}
 
+	// Режим подтверждений: сервер сообщает о каждом опубликованном сообщении.
+	if err = r.channel.Confirm(false); err != nil {
+		return errors.Join(ErrChannel, err)
+	}
+	r.publishTag = 0
+	r.confirms = r.channel.NotifyPublish(make(chan amqp.Confirmation, confirmsBufferSize))
+	r.returns = r.channel.NotifyReturn(make(chan amqp.Return, confirmsBufferSize))
+
+	closed := r.conn.NotifyClose(make(chan *amqp.Error))
 	go func() {
-		<-r.conn.NotifyClose(make(chan *amqp.Error))
+		<-closed
 		// Понимаем, что канал сообщений закрыт, надо пересоздать соединение.
-		r.done <- ErrChannelClosed
+		select {
+		case r.done <- ErrChannelClosed:
+		default:
+		}
 	}()
 
 	if err = r.channel.ExchangeDeclare(
@@ -194,6 +393,13 @@ func (r *Rmq) announceQueue() error {
 }
 
 func (r *Rmq) consume() (<-chan amqp.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channel == nil {
+		return nil, ErrNotConnected
+	}
+
 	msgs, err := r.channel.Consume(
 		r.queue,
 		r.consumerTag,
@@ -212,10 +418,18 @@ func (r *Rmq) consume() (<-chan amqp.Delivery, error) {
 
 func (r *Rmq) reConnect(ctx context.Context) error {
 	be := backoff.NewExponentialBackOff()
-	be.MaxElapsedTime = r.MaxElapsedTime
-	be.InitialInterval = r.InitialInterval
-	be.Multiplier = r.Multiplier
-	be.MaxInterval = r.maxInterval
+	if r.MaxElapsedTime > 0 {
+		be.MaxElapsedTime = r.MaxElapsedTime
+	}
+	if r.InitialInterval > 0 {
+		be.InitialInterval = r.InitialInterval
+	}
+	if r.Multiplier > 0 {
+		be.Multiplier = r.Multiplier
+	}
+	if r.maxInterval > 0 {
+		be.MaxInterval = r.maxInterval
+	}
 
 	b := backoff.WithContext(be, ctx)
 	for {
@@ -228,17 +442,28 @@ func (r *Rmq) reConnect(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		case <-time.After(d):
-			if err := r.connect(); err != nil {
+			if err := r.reConnectOnce(); err != nil {
 				fmt.Printf("could not connect in reconnect call: %+v", err)
 				continue
 			}
-			err := r.announceQueue()
-			if err != nil {
-				fmt.Printf("Couldn't connect: %+v", err)
-				continue
-			}
 
 			return nil
 		}
 	}
 }
+
+func (r *Rmq) reConnectOnce() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// already restored by another caller.
+	if r.conn != nil && !r.conn.IsClosed() {
+		return nil
+	}
+
+	if err := r.connect(); err != nil {
+		return err
+	}
+
+	return r.announceQueue()
+}
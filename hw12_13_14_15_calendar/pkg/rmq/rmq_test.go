@@ -0,0 +1,146 @@
+package rmq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfirmedRmq() *Rmq {
+	return &Rmq{
+		confirms:       make(chan amqp.Confirmation, confirmsBufferSize),
+		returns:        make(chan amqp.Return, confirmsBufferSize),
+		PublishTimeout: 50 * time.Millisecond,
+	}
+}
+
+func TestWaitConfirm(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ack", func(t *testing.T) {
+		r := newConfirmedRmq()
+		r.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		require.NoError(t, r.waitConfirm(ctx, 1, "id"))
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		r := newConfirmedRmq()
+		r.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+		require.ErrorIs(t, r.waitConfirm(ctx, 1, "id"), ErrPublishNack)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		r := newConfirmedRmq()
+		require.ErrorIs(t, r.waitConfirm(ctx, 1, "id"), ErrPublishTimeout)
+	})
+
+	t.Run("late confirmation of previous message is skipped", func(t *testing.T) {
+		r := newConfirmedRmq()
+		r.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+		r.confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+		require.NoError(t, r.waitConfirm(ctx, 2, "id"))
+	})
+
+	t.Run("unroutable", func(t *testing.T) {
+		r := newConfirmedRmq()
+		r.returns <- amqp.Return{MessageId: "previous", ReplyText: "NO_ROUTE"}
+		r.returns <- amqp.Return{MessageId: "id", ReplyText: "NO_ROUTE"}
+		r.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+		require.ErrorIs(t, r.waitConfirm(ctx, 1, "id"), ErrUnroutable)
+	})
+
+	t.Run("closed channel", func(t *testing.T) {
+		r := newConfirmedRmq()
+		close(r.confirms)
+		require.ErrorIs(t, r.waitConfirm(ctx, 1, "id"), ErrChannelClosed)
+	})
+}
+
+func TestPublishWithoutConnection(t *testing.T) {
+	r := NewRmq("tag", "amqp://localhost", "events", "fanout", "notifications", "", time.Second)
+	require.ErrorIs(t, r.Publish(context.Background(), amqp.Publishing{}), ErrNotConnected)
+}
+
+type ackResult struct {
+	ack     bool
+	requeue bool
+}
+
+// fakeAcknowledger records acknowledgements by delivery tag.
+type fakeAcknowledger struct {
+	mu      sync.Mutex
+	results map[uint64]ackResult
+}
+
+func (a *fakeAcknowledger) set(tag uint64, res ackResult) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.results[tag] = res
+	return nil
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, _ bool) error {
+	return a.set(tag, ackResult{ack: true})
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, _ bool, requeue bool) error {
+	return a.set(tag, ackResult{requeue: requeue})
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.set(tag, ackResult{requeue: requeue})
+}
+
+func TestRunWorkers(t *testing.T) {
+	acknowledger := &fakeAcknowledger{results: make(map[uint64]ackResult)}
+	msgs := make(chan amqp.Delivery, 3)
+	for tag, body := range map[uint64]string{1: "ack", 2: "requeue", 3: "nack"} {
+		msgs <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: tag, Body: []byte(body)}
+	}
+	close(msgs)
+
+	decisions := map[string]Decision{"ack": Ack, "requeue": Requeue, "nack": Nack}
+
+	r := &Rmq{}
+	done := r.runWorkers(context.Background(), msgs, func(_ context.Context, delivery amqp.Delivery) Decision {
+		return decisions[string(delivery.Body)]
+	}, 2)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("workers are not stopped after deliveries channel is closed")
+	}
+
+	require.Equal(t, map[uint64]ackResult{
+		1: {ack: true},
+		2: {requeue: true},
+		3: {requeue: false},
+	}, acknowledger.results)
+}
+
+func TestRunWorkersRequeueOnShutdown(t *testing.T) {
+	acknowledger := &fakeAcknowledger{results: make(map[uint64]ackResult)}
+	msgs := make(chan amqp.Delivery, 2)
+	msgs <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 1}
+	msgs <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 2}
+	close(msgs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := &Rmq{}
+	<-r.runWorkers(ctx, msgs, func(context.Context, amqp.Delivery) Decision {
+		t.Error("worker must not be called on shutdown")
+		return Ack
+	}, 1)
+
+	require.Equal(t, map[uint64]ackResult{
+		1: {requeue: true},
+		2: {requeue: true},
+	}, acknowledger.results)
+}
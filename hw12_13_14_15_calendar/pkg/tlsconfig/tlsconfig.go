@@ -0,0 +1,135 @@
+// Package tlsconfig builds server TLS config with certificates that are reloaded from files
+// without restart, e.g. when they are renewed by cert-manager. With client CA the config
+// requires client certificates signed by it (mutual TLS).
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/pkg/reload"
+)
+
+var (
+	ErrNoCertificate = errors.New("certificate and key files are required")
+	ErrInvalidCA     = errors.New("no certificates in client CA file")
+)
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS, empty means client certificates are not requested.
+	ClientCAFile string
+}
+
+// Reloader keeps the current certificate and client CA pool for new connections.
+type Reloader struct {
+	conf Config
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	fingerprint string
+}
+
+// New loads files at once, so wrong files are found on start.
+func New(conf Config) (*Reloader, error) {
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, ErrNoCertificate
+	}
+
+	r := &Reloader{conf: conf}
+	if err := r.Load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Load reads files again. Current certificates are replaced only if all new files are valid.
+func (r *Reloader) Load() error {
+	current, err := r.scan()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.conf.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("cannot load client CA: %w", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: %s", ErrInvalidCA, r.conf.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.fingerprint = current
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads files when they are changed, until ctx is done. Invalid files are reported
+// with onError and ignored, so connections keep using the last valid certificate.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onError func(err error)) {
+	r.mu.RLock()
+	last := r.fingerprint
+	r.mu.RUnlock()
+
+	reload.NewPoller(last, r.scan).Poll(ctx, interval, r.Load, onError)
+}
+
+// TLSConfig returns config for the server, every new connection gets the current certificate and CA.
+// Protocols are passed explicitly because servers add them to their own copy of the config,
+// that is not used for connections, e.g. "h2" for gRPC.
+func (r *Reloader) TLSConfig(protocols ...string) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     protocols,
+		GetCertificate: r.getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   protocols,
+				Certificates: []tls.Certificate{*r.certificate},
+			}
+			if r.clientCAs != nil {
+				config.ClientCAs = r.clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return config, nil
+		},
+	}
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.certificate, nil
+}
+
+func (r *Reloader) scan() (string, error) {
+	return reload.Files(r.conf.CertFile, r.conf.KeyFile, r.conf.ClientCAFile)
+}
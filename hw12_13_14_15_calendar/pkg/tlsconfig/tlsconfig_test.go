@@ -0,0 +1,216 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var serial int64
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key.
+func (a *authority) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	// rename is atomic, so watcher never reads half-written file.
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, data, 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+// serve accepts connections and reports common names of verified clients.
+func serve(t *testing.T, config *tls.Config) (string, <-chan string) {
+	t.Helper()
+
+	lsn, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { lsn.Close() })
+
+	clients := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := lsn.Accept()
+			if err != nil {
+				return
+			}
+
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				state := tlsConn.ConnectionState()
+				if len(state.VerifiedChains) > 0 {
+					clients <- state.VerifiedChains[0][0].Subject.CommonName
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	return lsn.Addr().String(), clients
+}
+
+func dial(addr string, roots *x509.CertPool, certificates ...tls.Certificate) (string, error) {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      roots,
+		Certificates: certificates,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// with TLS 1.3 client certificate is checked after the client handshake, so read the answer.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestMutualTLSAndReload(t *testing.T) {
+	ca := newAuthority(t)
+	dir := t.TempDir()
+	conf := Config{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+
+	cert, key := ca.issue(t, "server-1", x509.ExtKeyUsageServerAuth)
+	writeFile(t, conf.CertFile, cert)
+	writeFile(t, conf.KeyFile, key)
+	writeFile(t, conf.ClientCAFile, ca.pem)
+
+	reloader, err := New(conf)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go reloader.Watch(ctx, 5*time.Millisecond, func(err error) { errs <- err })
+
+	addr, clients := serve(t, reloader.TLSConfig())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	clientCert, clientKey := ca.issue(t, "calendarctl", x509.ExtKeyUsageClientAuth)
+	client, err := tls.X509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+
+	// verified client is known to the server
+	server, err := dial(addr, roots, client)
+	require.NoError(t, err)
+	require.Equal(t, "server-1", server)
+	require.Equal(t, "calendarctl", <-clients)
+
+	// client without certificate is rejected
+	_, err = dial(addr, roots)
+	require.Error(t, err)
+
+	// renewed certificate is used by new connections
+	cert, key = ca.issue(t, "server-2", x509.ExtKeyUsageServerAuth)
+	writeFile(t, conf.KeyFile, key)
+	writeFile(t, conf.CertFile, cert)
+	require.Eventually(t, func() bool {
+		server, err := dial(addr, roots, client)
+		return err == nil && server == "server-2"
+	}, time.Second, 10*time.Millisecond)
+
+	// broken certificate is reported, the last valid one keeps working
+	for len(errs) > 0 {
+		<-errs
+	}
+	writeFile(t, conf.CertFile, []byte("broken"))
+	select {
+	case err := <-errs:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("broken certificate is not reported")
+	}
+
+	server, err = dial(addr, roots, client)
+	require.NoError(t, err)
+	require.Equal(t, "server-2", server)
+}
+
+func TestNewRequiresFiles(t *testing.T) {
+	_, err := New(Config{})
+	require.ErrorIs(t, err, ErrNoCertificate)
+
+	_, err = New(Config{CertFile: "missing.crt", KeyFile: "missing.key"})
+	require.Error(t, err)
+}
@@ -6,19 +6,24 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/identity"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/server/pb"
 	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/internal/storage"
+	"github.com/XanderKon/hw-otus/hw12_13_14_15_calendar/migrations"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq" // PG
-	"github.com/pressly/goose"
+	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type CalendarSuite struct {
@@ -30,15 +35,16 @@ type CalendarSuite struct {
 
 var testEvent = &storage.Event{
 	Title:            "Test Event Title",
-	DateTime:         time.Now(),
-	Duration:         time.Now().Add(time.Hour).Unix(),
+	DateTime:         time.Now().Add(time.Hour),
+	EndTime:          time.Now().Add(2 * time.Hour),
 	Description:      "Test Description",
 	UserID:           123,
-	TimeNotification: time.Now().Add(time.Hour),
+	TimeNotification: time.Now(),
 }
 
 func (cs *CalendarSuite) SetupSuite() {
-	cs.ctx = context.Background()
+	// requests are made by the user of the test event, the test server trusts the header (grpc.trustUserHeader)
+	cs.ctx = cs.as(testEvent.UserID)
 
 	host, ok := os.LookupEnv("GRPC_HOST")
 	if !ok {
@@ -61,10 +67,11 @@ func (cs *CalendarSuite) SetupSuite() {
 	db, err := sql.Open("postgres", DSN)
 	cs.NoError(err)
 
-	// run migrations
+	// run migrations, embedded into the binary unless MIGRATIONS_PATH is set
 	mPath, ok := os.LookupEnv("MIGRATIONS_PATH")
 	if !ok {
-		mPath = "../migrations"
+		goose.SetBaseFS(migrations.FS)
+		mPath = "."
 	}
 
 	goose.SetDialect("postgres")
@@ -75,7 +82,7 @@ func (cs *CalendarSuite) SetupSuite() {
 }
 
 func (cs *CalendarSuite) TearDownTest() {
-	cs.db.Exec(`TRUNCATE event`)
+	cs.db.Exec(`TRUNCATE event, event_tag, tag, calendar, calendar_share`)
 }
 
 func (cs *CalendarSuite) TearDownSuite() {
@@ -86,10 +93,20 @@ func TestCalendarPost(t *testing.T) {
 	suite.Run(t, new(CalendarSuite))
 }
 
+func (cs *CalendarSuite) as(userID int64) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), identity.UserHeader, strconv.FormatInt(userID, 10))
+}
+
 func (cs *CalendarSuite) insertTestEvent(ev *storage.Event) uuid.UUID {
+	// the event goes to the default calendar of the user, the calendar is created if needed
 	const query = `
-		INSERT INTO event (title, date_time, duration, description, user_id, notification_time)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		WITH default_calendar AS (
+			INSERT INTO calendar (owner_id, name, is_default) VALUES ($5, 'Default', TRUE)
+			ON CONFLICT (owner_id) WHERE is_default DO UPDATE SET name = calendar.name
+			RETURNING id
+		)
+		INSERT INTO event (calendar_id, title, date_time, end_time, description, user_id, notification_time)
+		SELECT id, $1, $2, $3, $4, $5, $6 FROM default_calendar
 		RETURNING id;
 	`
 	event := testEvent
@@ -102,7 +119,7 @@ func (cs *CalendarSuite) insertTestEvent(ev *storage.Event) uuid.UUID {
 		query,
 		event.Title,
 		event.DateTime,
-		event.Duration,
+		event.EndTime,
 		event.Description,
 		event.UserID,
 		event.TimeNotification,
@@ -123,7 +140,7 @@ func (cs *CalendarSuite) TestGetEvent() {
 	cs.Require().Equal(res.Event.Description, testEvent.Description)
 	cs.Require().Equal(res.Event.Title, testEvent.Title)
 	cs.Require().Equal(res.Event.UserId, testEvent.UserID)
-	cs.Require().Equal(res.Event.Duration, testEvent.Duration)
+	cs.Require().Equal(testEvent.EndTime.Unix(), res.Event.EndTime.AsTime().Unix())
 }
 
 func (cs *CalendarSuite) TestGetEventWithError() {
@@ -136,7 +153,7 @@ func (cs *CalendarSuite) TestCreateEvent() {
 		Event: &pb.Event{
 			Title:       "Test Event Title",
 			DateTime:    &timestamp.Timestamp{Seconds: time.Now().Unix()},
-			Duration:    time.Now().Add(time.Hour).Unix(),
+			EndTime:     &timestamp.Timestamp{Seconds: time.Now().Add(time.Hour).Unix()},
 			Description: "Test Description",
 			UserId:      123,
 		},
@@ -211,7 +228,7 @@ func (cs *CalendarSuite) TestGetEvents() {
 		eventIds = append(eventIds, cs.insertTestEvent(nil))
 	}
 
-	res, err := cs.client.GetEvents(cs.ctx, &emptypb.Empty{})
+	res, err := cs.client.GetEvents(cs.ctx, &pb.EventsRequest{})
 	cs.Require().NoError(err)
 	cs.Require().Len(res.Events, 3)
 
@@ -230,7 +247,7 @@ func (cs *CalendarSuite) TestGetEventsForDay() {
 	ev1 := &storage.Event{
 		Title:            "First Event Title",
 		DateTime:         now.Add(time.Minute),
-		Duration:         now.Add(time.Hour).Unix(),
+		EndTime:          now.Add(time.Hour),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -239,7 +256,7 @@ func (cs *CalendarSuite) TestGetEventsForDay() {
 	ev2 := &storage.Event{
 		Title:            "Second Event Title",
 		DateTime:         now.Add(time.Hour),
-		Duration:         now.Add(time.Hour * 2).Unix(),
+		EndTime:          now.Add(time.Hour * 2),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -248,7 +265,7 @@ func (cs *CalendarSuite) TestGetEventsForDay() {
 	ev3 := &storage.Event{
 		Title:            "Third Event Title",
 		DateTime:         now.Add(time.Hour * 30),
-		Duration:         now.Add(time.Hour * 31).Unix(),
+		EndTime:          now.Add(time.Hour * 31),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -274,7 +291,7 @@ func (cs *CalendarSuite) TestGetEventsForWeek() {
 	ev1 := &storage.Event{
 		Title:            "First Event Title",
 		DateTime:         now.Add(time.Hour),
-		Duration:         now.Add(time.Hour * 2).Unix(),
+		EndTime:          now.Add(time.Hour * 2),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -283,7 +300,7 @@ func (cs *CalendarSuite) TestGetEventsForWeek() {
 	ev2 := &storage.Event{
 		Title:            "Second Event Title",
 		DateTime:         now.Add(4 * 24 * time.Hour),
-		Duration:         now.Add(8 * 24 * time.Hour).Unix(),
+		EndTime:          now.Add(8 * 24 * time.Hour),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -292,7 +309,7 @@ func (cs *CalendarSuite) TestGetEventsForWeek() {
 	ev3 := &storage.Event{
 		Title:            "Third Event Title",
 		DateTime:         now.Add(10 * 24 * time.Hour),
-		Duration:         now.Add(11 * 24 * time.Hour).Unix(),
+		EndTime:          now.Add(11 * 24 * time.Hour),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -318,7 +335,7 @@ func (cs *CalendarSuite) TestGetEventsForMonth() {
 	ev1 := &storage.Event{
 		Title:            "First Event Title",
 		DateTime:         now.Add(time.Hour),
-		Duration:         now.Add(time.Hour * 2).Unix(),
+		EndTime:          now.Add(time.Hour * 2),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -327,7 +344,7 @@ func (cs *CalendarSuite) TestGetEventsForMonth() {
 	ev2 := &storage.Event{
 		Title:            "Second Event Title",
 		DateTime:         now.AddDate(0, 0, 15),
-		Duration:         now.AddDate(0, 0, 16).Unix(),
+		EndTime:          now.AddDate(0, 0, 16),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -336,7 +353,7 @@ func (cs *CalendarSuite) TestGetEventsForMonth() {
 	ev3 := &storage.Event{
 		Title:            "Third Event Title",
 		DateTime:         now.AddDate(0, 0, 31),
-		Duration:         now.AddDate(0, 0, 32).Unix(),
+		EndTime:          now.AddDate(0, 0, 32),
 		Description:      "Test Description",
 		UserID:           123,
 		TimeNotification: now.Add(time.Hour),
@@ -356,3 +373,187 @@ func (cs *CalendarSuite) TestGetEventsForMonth() {
 		cs.WithinRange(event.DateTime.AsTime(), now, endOfMonth)
 	}
 }
+
+func (cs *CalendarSuite) TestTags() {
+	now := time.Now().UTC()
+	for i, tags := range [][]string{{"work"}, {"work", "home"}, nil} {
+		_, err := cs.client.CreateEvent(cs.ctx, &pb.EventRequest{Event: &pb.Event{
+			Title:    fmt.Sprintf("Event %d", i),
+			DateTime: &timestamp.Timestamp{Seconds: now.Add(time.Duration(i+1) * time.Minute).Unix()},
+			UserId:   123,
+			Color:    "#ff8800",
+			Location: "Office",
+			Tags:     tags,
+		}})
+		cs.Require().NoError(err)
+	}
+
+	res, err := cs.client.GetEvents(cs.ctx, &pb.EventsRequest{Tag: "work"})
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 2)
+	cs.Require().Equal("#ff8800", res.Events[0].Color)
+	cs.Require().Equal("Office", res.Events[0].Location)
+	cs.Require().Equal([]string{"home", "work"}, res.Events[1].Tags)
+
+	req := &pb.RangeRequest{
+		DateTime: &timestamp.Timestamp{Seconds: now.Unix()},
+		Tag:      "home",
+	}
+	res, err = cs.client.GetEventsForDay(cs.ctx, req)
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 1)
+
+	_, err = cs.client.SaveTag(cs.ctx, &pb.TagRequest{UserId: 123, Name: "work", Tag: &pb.Tag{Color: "#0000ff"}})
+	cs.Require().NoError(err)
+
+	tags, err := cs.client.GetTags(cs.ctx, &pb.UserIdRequest{UserId: 123})
+	cs.Require().NoError(err)
+	cs.Require().Len(tags.Tags, 2)
+	cs.Require().Equal("work", tags.Tags[1].Name)
+	cs.Require().Equal("#0000ff", tags.Tags[1].Color)
+
+	_, err = cs.client.DeleteTag(cs.ctx, &pb.TagNameRequest{UserId: 123, Name: "work"})
+	cs.Require().NoError(err)
+
+	res, err = cs.client.GetEvents(cs.ctx, &pb.EventsRequest{Tag: "work"})
+	cs.Require().NoError(err)
+	cs.Require().Empty(res.Events)
+
+	_, err = cs.client.DeleteTag(cs.ctx, &pb.TagNameRequest{UserId: 123, Name: "work"})
+	cs.Require().ErrorContains(err, storage.ErrTagNotFound.Error())
+}
+
+func (cs *CalendarSuite) TestSharedCalendar() {
+	calendar, err := cs.client.CreateCalendar(cs.ctx, &pb.CalendarRequest{Calendar: &pb.Calendar{
+		OwnerId: 123,
+		Name:    "Team",
+	}})
+	cs.Require().NoError(err)
+	cs.Require().False(calendar.Calendar.Default)
+
+	now := time.Now().UTC()
+	planning, err := cs.client.CreateEvent(cs.ctx, &pb.EventRequest{Event: &pb.Event{
+		CalendarId: calendar.Calendar.Id,
+		Title:      "Planning",
+		DateTime:   &timestamp.Timestamp{Seconds: now.Add(time.Minute).Unix()},
+		UserId:     123,
+		Tags:       []string{"work"},
+	}})
+	cs.Require().NoError(err)
+
+	// user 456 can not see or change the calendar until it is shared
+	res, err := cs.client.GetEvents(cs.as(456), &pb.EventsRequest{UserId: 456})
+	cs.Require().NoError(err)
+	cs.Require().Empty(res.Events)
+
+	_, err = cs.client.CreateEvent(cs.as(456), &pb.EventRequest{Event: &pb.Event{
+		CalendarId: calendar.Calendar.Id,
+		Title:      "Lunch",
+		DateTime:   &timestamp.Timestamp{Seconds: now.Add(2 * time.Minute).Unix()},
+		UserId:     456,
+	}})
+	cs.Require().ErrorContains(err, "user can not change events of the calendar")
+
+	share := &pb.ShareRequest{
+		CalendarId: calendar.Calendar.Id,
+		UserId:     456,
+		Share:      &pb.Share{Role: pb.Role_ROLE_FREE_BUSY},
+	}
+	_, err = cs.client.ShareCalendar(cs.ctx, share)
+	cs.Require().NoError(err)
+
+	res, err = cs.client.GetEventsForDay(cs.as(456), &pb.RangeRequest{
+		DateTime: &timestamp.Timestamp{Seconds: now.Unix()},
+		UserId:   456,
+	})
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 1)
+	cs.Require().Equal("Busy", res.Events[0].Title)
+	cs.Require().Empty(res.Events[0].Tags)
+
+	share.Share.Role = pb.Role_ROLE_READ
+	_, err = cs.client.ShareCalendar(cs.ctx, share)
+	cs.Require().NoError(err)
+
+	res, err = cs.client.GetEvents(cs.as(456), &pb.EventsRequest{UserId: 456, Tag: "work"})
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 1)
+	cs.Require().Equal("Planning", res.Events[0].Title)
+
+	// the reader can not grant itself more access or delete events of the calendar
+	share.Share.Role = pb.Role_ROLE_WRITE
+	_, err = cs.client.ShareCalendar(cs.as(456), share)
+	cs.Require().Equal(codes.PermissionDenied, status.Code(err))
+
+	_, err = cs.client.DeleteEvent(cs.as(456), &pb.EventIdRequest{Id: planning.Event.Id})
+	cs.Require().Equal(codes.PermissionDenied, status.Code(err))
+
+	calendars, err := cs.client.GetCalendars(cs.as(456), &pb.UserIdRequest{UserId: 456})
+	cs.Require().NoError(err)
+	cs.Require().Len(calendars.Calendars, 2) // the default one of the user and the shared one
+
+	_, err = cs.client.DeleteCalendar(cs.ctx, &pb.CalendarIdRequest{Id: calendar.Calendar.Id})
+	cs.Require().NoError(err)
+
+	res, err = cs.client.GetEvents(cs.ctx, &pb.EventsRequest{})
+	cs.Require().NoError(err)
+	cs.Require().Empty(res.Events)
+}
+
+func (cs *CalendarSuite) TestAllDayAndMultiDayEvents() {
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+	conference, err := cs.client.CreateEvent(cs.ctx, &pb.EventRequest{Event: &pb.Event{
+		Title:     "Conference",
+		AllDay:    true,
+		StartDate: day.Format(storage.DayLayout),
+		EndDate:   day.AddDate(0, 0, 2).Format(storage.DayLayout),
+		UserId:    123,
+	}})
+	cs.Require().NoError(err)
+	cs.Require().Equal(day.Unix(), conference.Event.DateTime.AsTime().Unix())
+	cs.Require().Equal(day.AddDate(0, 0, 3).Unix(), conference.Event.EndTime.AsTime().Unix())
+	cs.Require().Equal(day.AddDate(0, 0, 2).Format(storage.DayLayout), conference.Event.EndDate)
+
+	// the flight starts the day before and ends inside the day
+	_, err = cs.client.CreateEvent(cs.ctx, &pb.EventRequest{Event: &pb.Event{
+		Title:    "Flight",
+		DateTime: &timestamp.Timestamp{Seconds: day.Add(-4 * time.Hour).Unix()},
+		EndTime:  &timestamp.Timestamp{Seconds: day.Add(2 * time.Hour).Unix()},
+		UserId:   123,
+	}})
+	cs.Require().NoError(err)
+
+	res, err := cs.client.GetEventsForDay(cs.ctx, &pb.RangeRequest{DateTime: &timestamp.Timestamp{Seconds: day.Unix()}})
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 2)
+	cs.Require().Equal("Flight", res.Events[0].Title)
+	cs.Require().Equal("Conference", res.Events[1].Title)
+
+	// the last day of the conference is the same in New York, though it starts later there
+	newYork, err := time.LoadLocation("America/New_York")
+	cs.Require().NoError(err)
+	lastDay := time.Date(day.Year(), day.Month(), day.Day()+2, 0, 0, 0, 0, newYork)
+
+	res, err = cs.client.GetEventsForDay(cs.ctx, &pb.RangeRequest{
+		DateTime: &timestamp.Timestamp{Seconds: lastDay.Unix()},
+		TimeZone: "America/New_York",
+	})
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 1)
+	cs.Require().Equal("Conference", res.Events[0].Title)
+	cs.Require().True(res.Events[0].AllDay)
+
+	// the day before in Tokyo ends after the conference has started in UTC, but it is still the day before
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	cs.Require().NoError(err)
+	dayBefore := time.Date(day.Year(), day.Month(), day.Day()-1, 0, 0, 0, 0, tokyo)
+
+	res, err = cs.client.GetEventsForDay(cs.ctx, &pb.RangeRequest{
+		DateTime: &timestamp.Timestamp{Seconds: dayBefore.Unix()},
+		TimeZone: "Asia/Tokyo",
+	})
+	cs.Require().NoError(err)
+	cs.Require().Len(res.Events, 1)
+	cs.Require().Equal("Flight", res.Events[0].Title)
+}